@@ -0,0 +1,74 @@
+package block
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrBlocked = errors.New("blocked")
+
+// ErrNoOwner is returned by CheckOwner when a guard is configured without the lookup of the owners of the items.
+var ErrNoOwner = errors.New("the owner lookup is required by the guard")
+
+// Guard is consulted by the services before a user acts on a user or on the items of a user.
+type Guard interface {
+	// IsBlocked returns true if id blocked userId or userId blocked id.
+	IsBlocked(ctx context.Context, id string, userId string) (bool, error)
+	// Blocked returns the users of userIds who blocked id or were blocked by id.
+	Blocked(ctx context.Context, id string, userIds []string) (map[string]bool, error)
+	// Muted returns the users of userIds muted by id.
+	Muted(ctx context.Context, id string, userIds []string) (map[string]bool, error)
+}
+
+// Check returns ErrBlocked if id and userId are blocked. A nil guard blocks nobody.
+func Check(ctx context.Context, guard Guard, id string, userId string) error {
+	if guard == nil || len(id) == 0 || len(userId) == 0 || id == userId {
+		return nil
+	}
+	blocked, err := guard.IsBlocked(ctx, id, userId)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
+
+// CheckOwner returns ErrBlocked if the owner of the item id and userId are blocked, owner returning the author of the item.
+// A nil guard blocks nobody, but a guard without owner returns ErrNoOwner, so that it is never skipped.
+func CheckOwner(ctx context.Context, guard Guard, owner func(ctx context.Context, id string) (string, error), id string, userId string) error {
+	if guard == nil {
+		return nil
+	}
+	if owner == nil {
+		return ErrNoOwner
+	}
+	o, err := owner(ctx, id)
+	if err != nil {
+		return err
+	}
+	return Check(ctx, guard, o, userId)
+}
+
+// Blocked returns the users of userIds who blocked id or were blocked by id. A nil guard blocks nobody.
+func Blocked(ctx context.Context, guard Guard, id string, userIds []string) (map[string]bool, error) {
+	if guard == nil || len(id) == 0 || len(userIds) == 0 {
+		return nil, nil
+	}
+	return guard.Blocked(ctx, id, userIds)
+}
+
+// Muted returns the users of userIds muted by id. A nil guard mutes nobody.
+func Muted(ctx context.Context, guard Guard, id string, userIds []string) (map[string]bool, error) {
+	if guard == nil || len(id) == 0 || len(userIds) == 0 {
+		return nil, nil
+	}
+	return guard.Muted(ctx, id, userIds)
+}
+
+// Follows removes the follow relationships of the users who are blocked. follow.FollowService implements it.
+type Follows interface {
+	// Disconnect removes the follow edges and the follow requests between id and target, in both directions.
+	Disconnect(ctx context.Context, id string, target string) (int64, error)
+}
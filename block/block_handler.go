@@ -0,0 +1,71 @@
+package block
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+func NewBlockHandler(service BlockService, targetIndex int, idIndex int) BlockHandler {
+	return BlockHandler{service: service, idIndex: idIndex, targetIndex: targetIndex}
+}
+
+type BlockHandler struct {
+	service     BlockService
+	targetIndex int
+	idIndex     int
+}
+
+func (h *BlockHandler) Block(w http.ResponseWriter, r *http.Request) {
+	h.exec(w, r, h.service.Block)
+}
+
+func (h *BlockHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	h.exec(w, r, h.service.Unblock)
+}
+
+func (h *BlockHandler) Mute(w http.ResponseWriter, r *http.Request) {
+	h.exec(w, r, h.service.Mute)
+}
+
+func (h *BlockHandler) Unmute(w http.ResponseWriter, r *http.Request) {
+	h.exec(w, r, h.service.Unmute)
+}
+
+func (h *BlockHandler) exec(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, id string, target string) (int64, error)) {
+	target := GetRequiredParam(w, r, h.targetIndex)
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) > 0 && len(target) > 0 {
+		result, err := action(r.Context(), id, target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		json.NewEncoder(w).Encode(result)
+	}
+}
+func GetParam(r *http.Request, options ...int) string {
+	offset := 0
+	if len(options) > 0 && options[0] > 0 {
+		offset = options[0]
+	}
+	s := r.URL.Path
+	params := strings.Split(s, "/")
+	i := len(params) - 1 - offset
+	if i >= 0 {
+		return params[i]
+	} else {
+		return ""
+	}
+}
+func GetRequiredParam(w http.ResponseWriter, r *http.Request, options ...int) string {
+	p := GetParam(r, options...)
+	if len(p) == 0 {
+		http.Error(w, "parameter is required", http.StatusBadRequest)
+		return ""
+	}
+	return p
+}
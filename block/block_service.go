@@ -0,0 +1,169 @@
+package block
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/core-go/reaction/dialect"
+)
+
+type BlockService interface {
+	Guard
+	// Block blocks target, and removes the follow relationships and the follow requests between id and target.
+	Block(ctx context.Context, id string, target string) (int64, error)
+	Unblock(ctx context.Context, id string, target string) (int64, error)
+	// Mute hides the comments of target from id.
+	Mute(ctx context.Context, id string, target string) (int64, error)
+	Unmute(ctx context.Context, id string, target string) (int64, error)
+}
+
+func NewBlockService(
+	db *sql.DB,
+	blockTable string,
+	muteTable string,
+	idCol string,
+	targetCol string,
+	timeCol string,
+	follows Follows,
+	toArray func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	},
+) BlockService {
+	return &blockService{
+		db:         db,
+		blockTable: blockTable,
+		muteTable:  muteTable,
+		idCol:      idCol,
+		targetCol:  targetCol,
+		timeCol:    timeCol,
+		follows:    follows,
+		toArray:    toArray,
+		dialect:    dialect.New(db),
+	}
+}
+
+type blockService struct {
+	db         *sql.DB
+	blockTable string
+	muteTable  string
+	idCol      string
+	targetCol  string
+	timeCol    string
+	follows    Follows
+	toArray    func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	}
+	dialect dialect.Dialect
+}
+
+func (s *blockService) Block(ctx context.Context, id string, target string) (int64, error) {
+	r, err := s.insert(ctx, s.blockTable, id, target)
+	if err != nil || s.follows == nil {
+		return r, err
+	}
+	// The follow relationships are removed after the block is stored, so that Block can be called again if it fails here.
+	if _, err = s.follows.Disconnect(ctx, id, target); err != nil {
+		return -1, err
+	}
+	return r, nil
+}
+
+func (s *blockService) Unblock(ctx context.Context, id string, target string) (int64, error) {
+	return s.delete(ctx, s.blockTable, id, target)
+}
+
+func (s *blockService) Mute(ctx context.Context, id string, target string) (int64, error) {
+	return s.insert(ctx, s.muteTable, id, target)
+}
+
+func (s *blockService) Unmute(ctx context.Context, id string, target string) (int64, error) {
+	return s.delete(ctx, s.muteTable, id, target)
+}
+
+func (s *blockService) IsBlocked(ctx context.Context, id string, userId string) (bool, error) {
+	query := s.dialect.Rebind(fmt.Sprintf("select count(*) from %s where (%s = ? and %s = ?) or (%s = ? and %s = ?)",
+		s.blockTable, s.idCol, s.targetCol, s.idCol, s.targetCol))
+	var count int64
+	err := s.db.QueryRowContext(ctx, query, id, userId, userId, id).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *blockService) Blocked(ctx context.Context, id string, userIds []string) (map[string]bool, error) {
+	blocked := make(map[string]bool)
+	if len(userIds) == 0 {
+		return blocked, nil
+	}
+	in1, params1 := s.dialect.InArray(s.targetCol, userIds, s.toArray)
+	in2, params2 := s.dialect.InArray(s.idCol, userIds, s.toArray)
+	query := s.dialect.Rebind(fmt.Sprintf("select %s, %s from %s where (%s = ? and %s) or (%s = ? and %s)",
+		s.idCol, s.targetCol, s.blockTable, s.idCol, in1, s.targetCol, in2))
+	params := append([]interface{}{id}, params1...)
+	params = append(params, id)
+	params = append(params, params2...)
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var blocker, target string
+		if err = rows.Scan(&blocker, &target); err != nil {
+			return nil, err
+		}
+		if blocker == id {
+			blocked[target] = true
+		} else {
+			blocked[blocker] = true
+		}
+	}
+	return blocked, rows.Err()
+}
+
+func (s *blockService) Muted(ctx context.Context, id string, userIds []string) (map[string]bool, error) {
+	muted := make(map[string]bool)
+	if len(userIds) == 0 {
+		return muted, nil
+	}
+	in, params := s.dialect.InArray(s.targetCol, userIds, s.toArray)
+	query := s.dialect.Rebind(fmt.Sprintf("select %s from %s where %s = ? and %s", s.targetCol, s.muteTable, s.idCol, in))
+	rows, err := s.db.QueryContext(ctx, query, append([]interface{}{id}, params...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var target string
+		if err = rows.Scan(&target); err != nil {
+			return nil, err
+		}
+		muted[target] = true
+	}
+	return muted, rows.Err()
+}
+
+func (s *blockService) insert(ctx context.Context, table string, id string, target string) (int64, error) {
+	query := s.dialect.Rebind(s.dialect.Upsert(table,
+		[]string{s.idCol, s.targetCol, s.timeCol}, []string{"?", "?", "?"}, []string{s.idCol, s.targetCol}, nil))
+	res, err := s.db.ExecContext(ctx, query, id, target, time.Now())
+	if err != nil {
+		return -1, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *blockService) delete(ctx context.Context, table string, id string, target string) (int64, error) {
+	query := s.dialect.Rebind(fmt.Sprintf("delete from %s where %s = ? and %s = ?", table, s.idCol, s.targetCol))
+	res, err := s.db.ExecContext(ctx, query, id, target)
+	if err != nil {
+		return -1, err
+	}
+	return res.RowsAffected()
+}
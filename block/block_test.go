@@ -0,0 +1,50 @@
+package block
+
+import (
+	"context"
+	"testing"
+)
+
+// pairs blocks the pairs of users, in both directions.
+type pairs map[[2]string]bool
+
+func (p pairs) IsBlocked(ctx context.Context, id string, userId string) (bool, error) {
+	return p[[2]string{id, userId}] || p[[2]string{userId, id}], nil
+}
+
+func (p pairs) Blocked(ctx context.Context, id string, userIds []string) (map[string]bool, error) {
+	blocked := make(map[string]bool)
+	for _, userId := range userIds {
+		blocked[userId], _ = p.IsBlocked(ctx, id, userId)
+	}
+	return blocked, nil
+}
+
+func (p pairs) Muted(ctx context.Context, id string, userIds []string) (map[string]bool, error) {
+	return nil, nil
+}
+
+func TestCheckOwner(t *testing.T) {
+	ctx := context.Background()
+	guard := pairs{{"owner", "blocked"}: true}
+	owner := func(ctx context.Context, id string) (string, error) {
+		return "owner", nil
+	}
+	tests := []struct {
+		name   string
+		guard  Guard
+		owner  func(ctx context.Context, id string) (string, error)
+		userId string
+		want   error
+	}{
+		{"blocked by the owner", guard, owner, "blocked", ErrBlocked},
+		{"not blocked", guard, owner, "user", nil},
+		{"no guard", nil, nil, "blocked", nil},
+		{"guard without owner", guard, nil, "user", ErrNoOwner},
+	}
+	for _, tt := range tests {
+		if err := CheckOwner(ctx, tt.guard, tt.owner, "item", tt.userId); err != tt.want {
+			t.Errorf("%s: CheckOwner() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -6,10 +6,15 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"time"
+
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
 )
 
 type CommentService interface {
-	Load(ctx context.Context, id string, author string) ([]Response, error)
+	// Load returns the comments on (id, author), without the comments of the users muted by userId.
+	Load(ctx context.Context, id string, author string, userId string) ([]Response, error)
 	Create(ctx context.Context, id string, commentId string, userId string, author string, comment Request) (int64, error)
 	Update(ctx context.Context, id string, commentId string, userId string, author string, comment Request) (int64, error)
 	Delete(ctx context.Context, id string, commentId string, author string) (int64, error)
@@ -18,7 +23,7 @@ type CommentService interface {
 func NewCommentService(db *sql.DB, commentTable string, commentIdCol string, idCol string, authorCol string, userIdCol string, commentCol string, anonymousCol string, timeCol string, updatedAtCol string, rateTable string, rateIdCol string, rateAuthorCol string, commentCountCol string, userTable string, userIdUserCol string, imageUrlUserCol string, UsernameUserCol string, queryInfo func(ids []string) ([]Info, error), toArray func(interface{}) interface {
 	driver.Valuer
 	sql.Scanner
-}) CommentService {
+}, guard block.Guard, publishers ...outbox.Publisher) CommentService {
 	return &commentService{
 		DB:              db,
 		CommentTable:    commentTable,
@@ -40,6 +45,9 @@ func NewCommentService(db *sql.DB, commentTable string, commentIdCol string, idC
 		ToArray:         toArray,
 		QueryInfo:       queryInfo,
 		UsernameUserCol: UsernameUserCol,
+		Guard:           guard,
+		Dialect:         dialect.New(db),
+		Publishers:      publishers,
 	}
 }
 
@@ -67,14 +75,17 @@ type commentService struct {
 		driver.Valuer
 		sql.Scanner
 	}
+	Guard      block.Guard
+	Dialect    dialect.Dialect
+	Publishers []outbox.Publisher
 }
 
-func (s *commentService) Load(ctx context.Context, id string, author string) ([]Response, error) {
+func (s *commentService) Load(ctx context.Context, id string, author string, userId string) ([]Response, error) {
 	var comments []Comment
 	var rs []Response
-	query := fmt.Sprintf(
-		"select s.%s, s.%s, s.%s, s.%s, s.%s, s.%s, s.%s, s.%s, s.histories from %s s where s.%s = $1 and s.%s = $2",
-		s.CommentIdCol, s.IdCol, s.AuthorCol, s.UserIdCol, s.CommentCol, s.AnonymousCol, s.TimeCol, s.UpdatedAtCol, s.CommentTable, s.IdCol, s.AuthorCol)
+	query := s.Dialect.Rebind(fmt.Sprintf(
+		"select s.%s, s.%s, s.%s, s.%s, s.%s, s.%s, s.%s, s.%s, s.histories from %s s where s.%s = ? and s.%s = ?",
+		s.CommentIdCol, s.IdCol, s.AuthorCol, s.UserIdCol, s.CommentCol, s.AnonymousCol, s.TimeCol, s.UpdatedAtCol, s.CommentTable, s.IdCol, s.AuthorCol))
 	rows, err := s.DB.QueryContext(ctx, query, id, author)
 	if err != nil {
 		return nil, err
@@ -95,6 +106,19 @@ func (s *commentService) Load(ctx context.Context, id string, author string) ([]
 	for _, r := range comments {
 		ids = append(ids, r.UserId)
 	}
+	muted, err := block.Muted(ctx, s.Guard, userId, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(muted) > 0 {
+		visible := make([]Comment, 0, len(comments))
+		for _, c := range comments {
+			if !muted[c.UserId] {
+				visible = append(visible, c)
+			}
+		}
+		comments = visible
+	}
 	if s.QueryInfo == nil {
 		for k, _ := range comments {
 			c := comments[k]
@@ -123,30 +147,44 @@ func (s *commentService) Load(ctx context.Context, id string, author string) ([]
 }
 
 func (s *commentService) Create(ctx context.Context, id string, commentId string, userId string, author string, rq Request) (int64, error) {
+	if err := block.Check(ctx, s.Guard, author, userId); err != nil {
+		return -1, err
+	}
 	var t = time.Now()
 	comment := Comment{Id: id, CommentId: commentId, Author: author, UserId: userId, Comment: rq.Comment, Anonymous: rq.Anonymous, Time: &t}
-	query1 := fmt.Sprintf(
-		"insert into %s(%s, %s, %s, %s, %s, %s, %s) values ($1, $2, $3, $4, $5, $6, $7)",
-		s.CommentTable, s.CommentIdCol, s.IdCol, s.AuthorCol, s.UserIdCol, s.CommentCol, s.AnonymousCol, s.TimeCol)
-	stmt1, err := s.DB.Prepare(query1)
+	query1 := s.Dialect.Rebind(fmt.Sprintf(
+		"insert into %s(%s, %s, %s, %s, %s, %s, %s) values (?, ?, ?, ?, ?, ?, ?)",
+		s.CommentTable, s.CommentIdCol, s.IdCol, s.AuthorCol, s.UserIdCol, s.CommentCol, s.AnonymousCol, s.TimeCol))
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return -1, err
 	}
-	res1, err := stmt1.ExecContext(ctx, comment.CommentId, comment.Id, comment.Author, comment.UserId, comment.Comment, comment.Anonymous, comment.Time)
+	defer tx.Rollback()
+	res1, err := tx.ExecContext(ctx, query1, comment.CommentId, comment.Id, comment.Author, comment.UserId, comment.Comment, comment.Anonymous, comment.Time)
 	if err != nil {
 		return -1, err
 	}
 
-	query2 := fmt.Sprintf(
-		"update %s set %s = %s.%s + 1 where %s = $1 and %s = $2",
-		s.RateTable, s.CommentCountCol, s.RateTable, s.CommentCountCol, s.RateIdCol, s.RateAuthorCol)
-	stmt2, err := s.DB.Prepare(query2)
+	query2 := s.Dialect.Rebind(fmt.Sprintf(
+		"update %s set %s = %s.%s + 1 where %s = ? and %s = ?",
+		s.RateTable, s.CommentCountCol, s.RateTable, s.CommentCountCol, s.RateIdCol, s.RateAuthorCol))
+	_, err = tx.ExecContext(ctx, query2, comment.Id, comment.Author)
 	if err != nil {
 		return -1, err
 	}
-	stmt2.ExecContext(ctx, comment.Id, comment.Author)
-
-	return res1.RowsAffected()
+	r, err := res1.RowsAffected()
+	if err != nil {
+		return -1, err
+	}
+	err = outbox.Publish(ctx, tx, s.Publishers, outbox.CommentCreated{CommentId: comment.CommentId, Id: comment.Id, Author: comment.Author,
+		UserId: comment.UserId, Comment: comment.Comment, Anonymous: comment.Anonymous, Time: t})
+	if err != nil {
+		return -1, err
+	}
+	if err = tx.Commit(); err != nil {
+		return -1, err
+	}
+	return r, nil
 }
 
 func (s *commentService) Update(ctx context.Context, id string, commentId string, userId string, author string, req Request) (int64, error) {
@@ -154,11 +192,15 @@ func (s *commentService) Update(ctx context.Context, id string, commentId string
 	var comment = Comment{Id: id, CommentId: commentId, UserId: userId, Author: author,
 		Comment: req.Comment, Anonymous: req.Anonymous, UpdatedAt: &t}
 	var oldComment Comment
-	query1 := fmt.Sprintf("select %s, %s, %s, histories from %s where %s = $1 limit 1", s.TimeCol, s.UpdatedAtCol, s.CommentCol, s.CommentTable, s.CommentIdCol)
-	rows, _ := s.DB.QueryContext(ctx, query1, comment.CommentId)
+	query1 := s.Dialect.Rebind(fmt.Sprintf("select %s, %s, %s, histories from %s where %s = ?", s.TimeCol, s.UpdatedAtCol, s.CommentCol, s.CommentTable, s.CommentIdCol))
+	rows, err := s.DB.QueryContext(ctx, query1, comment.CommentId)
+	if err != nil {
+		return -1, err
+	}
 	for rows.Next() {
 		err := rows.Scan(&oldComment.Time, &oldComment.UpdatedAt, &oldComment.Comment, s.ToArray(&oldComment.Histories))
 		if err != nil {
+			rows.Close()
 			return 0, err
 		}
 	}
@@ -170,19 +212,33 @@ func (s *commentService) Update(ctx context.Context, id string, commentId string
 		comment.Histories = append(oldComment.Histories, Histories{Time: oldComment.Time, Comment: oldComment.Comment})
 	}
 
-	query := fmt.Sprintf(
-		"update %s set %s = $1, %s = $2, histories = $3 where %s = $4;",
-		s.CommentTable, s.CommentCol, s.UpdatedAtCol, s.CommentIdCol)
-	stmt, err := s.DB.Prepare(query)
+	query := s.Dialect.Rebind(fmt.Sprintf(
+		"update %s set %s = ?, %s = ?, histories = ? where %s = ?",
+		s.CommentTable, s.CommentCol, s.UpdatedAtCol, s.CommentIdCol))
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return -1, err
 	}
-	res, err := stmt.ExecContext(ctx, comment.Comment, time.Now(), s.ToArray(comment.Histories), comment.CommentId)
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, query, comment.Comment, time.Now(), s.ToArray(comment.Histories), comment.CommentId)
 	if err != nil {
 		return -1, err
 	}
-
-	return res.RowsAffected()
+	r, err := res.RowsAffected()
+	if err != nil {
+		return -1, err
+	}
+	if r > 0 {
+		err = outbox.Publish(ctx, tx, s.Publishers, outbox.CommentUpdated{CommentId: comment.CommentId, Id: comment.Id, Author: comment.Author,
+			Old: oldComment.Comment, Comment: comment.Comment, Time: t})
+		if err != nil {
+			return -1, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return -1, err
+	}
+	return r, nil
 }
 
 func (s *commentService) Delete(ctx context.Context, id string, commentId string, author string) (int64, error) {
@@ -191,28 +247,38 @@ func (s *commentService) Delete(ctx context.Context, id string, commentId string
 		return -1, err
 	}
 	defer tx.Rollback()
-	query1 := fmt.Sprintf("delete from %s where %s = $1", s.CommentTable, s.CommentIdCol)
+	query1 := s.Dialect.Rebind(fmt.Sprintf("delete from %s where %s = ?", s.CommentTable, s.CommentIdCol))
 	stmt1, er0 := tx.Prepare(query1)
 	if er0 != nil {
-		return -1, nil
+		return -1, er0
 	}
 	res1, er1 := stmt1.ExecContext(ctx, commentId)
 	if er1 != nil {
 		return -1, er1
 	}
+	r, err := res1.RowsAffected()
+	if err != nil {
+		return -1, err
+	}
+	// The counter is decreased only if the comment was deleted, so that deleting it twice does not decrease it twice.
+	if r == 0 {
+		return 0, nil
+	}
 
-	query2 := fmt.Sprintf(
-		"update %s set %s = %s.%s - 1 where %s = $1 and %s = $2",
-		s.RateTable, s.CommentCountCol, s.RateTable, s.CommentCountCol, s.RateIdCol, s.RateAuthorCol)
+	query2 := s.Dialect.Rebind(fmt.Sprintf(
+		"update %s set %s = %s.%s - 1 where %s = ? and %s = ?",
+		s.RateTable, s.CommentCountCol, s.RateTable, s.CommentCountCol, s.RateIdCol, s.RateAuthorCol))
 	stmt2, err := tx.Prepare(query2)
 	if err != nil {
 		return -1, err
 	}
-	_, err1 := stmt2.ExecContext(ctx, id, author)
-	if err1 != nil {
+	if _, err = stmt2.ExecContext(ctx, id, author); err != nil {
+		return -1, err
+	}
+	err = outbox.Publish(ctx, tx, s.Publishers, outbox.CommentDeleted{CommentId: commentId, Id: id, Author: author, Time: time.Now()})
+	if err != nil {
 		return -1, err
 	}
-	r, _ := res1.RowsAffected()
 	err = tx.Commit()
 	if err != nil {
 		return -1, err
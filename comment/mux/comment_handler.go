@@ -3,6 +3,7 @@ package mux
 import (
 	"context"
 	"encoding/json"
+	"github.com/core-go/reaction/block"
 	"github.com/core-go/reaction/comment"
 	"net/http"
 	"strings"
@@ -33,7 +34,7 @@ func (h *CommentHandler) Load(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)[h.idField]
 	author := mux.Vars(r)[h.authorField]
 	if len(id) > 0 && len(author) > 0 {
-		res, err := h.service.Load(r.Context(), id, author)
+		res, err := h.service.Load(r.Context(), id, author, mux.Vars(r)[h.userIdField])
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -58,6 +59,10 @@ func (h *CommentHandler) Create(w http.ResponseWriter, r *http.Request) {
 	userId := mux.Vars(r)[h.userIdField]
 	if len(author) > 0 && len(id) > 0 {
 		res, er3 := h.service.Create(r.Context(), id, commentId, userId, author, comment)
+		if er3 == block.ErrBlocked {
+			http.Error(w, er3.Error(), http.StatusForbidden)
+			return
+		}
 		if er3 != nil {
 			http.Error(w, er3.Error(), 500)
 			return
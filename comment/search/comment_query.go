@@ -2,44 +2,55 @@ package search
 
 import (
 	"fmt"
-	q "github.com/core-go/sql"
 	"strings"
+
+	"github.com/core-go/reaction/dialect"
 )
 
 func BuildCommentQuery(filter interface{}) (query string, params []interface{}) {
+	return NewCommentQueryBuilder(dialect.Postgres)(filter)
+}
+
+func NewCommentQueryBuilder(d dialect.Dialect) func(interface{}) (string, []interface{}) {
+	return func(filter interface{}) (string, []interface{}) {
+		return buildCommentQuery(d, filter)
+	}
+}
+
+func buildCommentQuery(d dialect.Dialect, filter interface{}) (query string, params []interface{}) {
 	query = `select * from ratecomment`
 	s := filter.(*CommentFilter)
 	var where []string
 
 	i := 1
 	if len(s.CommentId) > 0 {
-		where = append(where, fmt.Sprintf(`commentid = %s`, q.BuildDollarParam(i)))
+		where = append(where, fmt.Sprintf(`commentid = %s`, d.Param(i)))
 		params = append(params, s.CommentId)
 		i++
 	}
 	if len(s.Id) > 0 {
-		where = append(where, fmt.Sprintf(`id = %s`, q.BuildDollarParam(i)))
+		where = append(where, fmt.Sprintf(`id = %s`, d.Param(i)))
 		params = append(params, s.Id)
 		i++
 	}
 	if len(s.Author) > 0 {
-		where = append(where, fmt.Sprintf(`author = %s`, q.BuildDollarParam(i)))
+		where = append(where, fmt.Sprintf(`author = %s`, d.Param(i)))
 		params = append(params, s.Author)
 		i++
 	}
 	if len(s.Comment) > 0 {
-		where = append(where, fmt.Sprintf(`comment ilike %s`, q.BuildDollarParam(i)))
+		where = append(where, d.ILike("comment", d.Param(i)))
 		params = append(params, "%"+s.Comment+"%")
 		i++
 	}
 	if s.Time != nil {
 		if s.Time.Min != nil {
-			where = append(where, fmt.Sprintf(`time >= %s`, q.BuildDollarParam(i)))
+			where = append(where, fmt.Sprintf(`time >= %s`, d.Param(i)))
 			params = append(params, s.Time.Min)
 			i++
 		}
 		if s.Time.Max != nil {
-			where = append(where, fmt.Sprintf(`time <= %s`, q.BuildDollarParam(i)))
+			where = append(where, fmt.Sprintf(`time <= %s`, d.Param(i)))
 			params = append(params, s.Time.Max)
 			i++
 		}
@@ -7,9 +7,17 @@ import (
 	"errors"
 	"fmt"
 	"time"
+
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
 )
 
+// ErrNoPermission is returned if a user updates the reply of another author.
+var ErrNoPermission = errors.New("no permission on comment")
+
 type CommentService interface {
+	// GetComments returns the replies of the thread, without the replies of the users muted by userId.
 	GetComments(ctx context.Context, commentThreadId string, userId *string) ([]Response, error)
 	Create(ctx context.Context, id, commentId, commentThreadId, author string, comment Request) (int64, error)
 	Update(ctx context.Context, commentId, author string, comment Request) (int64, error)
@@ -19,7 +27,7 @@ type CommentService interface {
 func NewCommentService(db *sql.DB, replyTable string, commentIdCol string, authorCol string, idCol string, updatedAtCol string, commentCol string, userIdCol string, timeCol string, historiesCol string, commentThreadIdCol string, reactionCol string, commentReactionTable string, commentIdReactionCol string, userTable string, userIdUserCol string, usernameUserCol string, avatarUserCol string, commentInfoTable string, userfulCountInfoCol string, commentIdInfoCol string, commentThreadInfoTable string, commentIdCommentThreadInfoCol string, replyCountCommentThreadInfoCol string, usefulCountCommentThreadInfoCol string, queryInfo func(ids []string) ([]Info, error), toArray func(interface{}) interface {
 	driver.Valuer
 	sql.Scanner
-}) CommentService {
+}, guard block.Guard, owner func(ctx context.Context, id string) (string, error), publishers ...outbox.Publisher) CommentService {
 	return &commentService{
 		db:                              db,
 		ReplyTable:                      replyTable,
@@ -48,6 +56,10 @@ func NewCommentService(db *sql.DB, replyTable string, commentIdCol string, autho
 		usefulCountCommentThreadInfoCol: usefulCountCommentThreadInfoCol,
 		queryInfo:                       queryInfo,
 		toArray:                         toArray,
+		dialect:                         dialect.New(db),
+		guard:                           guard,
+		owner:                           owner,
+		publishers:                      publishers,
 	}
 }
 
@@ -83,10 +95,16 @@ type commentService struct {
 		driver.Valuer
 		sql.Scanner
 	}
+	guard block.Guard
+	// owner returns the author of the item id, to check that the author did not block the users who reply on it.
+	// It is required if guard is not nil.
+	owner      func(ctx context.Context, id string) (string, error)
+	dialect    dialect.Dialect
+	publishers []outbox.Publisher
 }
 
 func (s *commentService) Update(ctx context.Context, commentId, author string, req Request) (int64, error) {
-	qr := fmt.Sprintf("select %s, %s,%s,%s from %s where %s = $1", s.commentIdCol, s.commentCol, s.historiesCol, s.authorCol, s.ReplyTable, s.commentIdCol)
+	qr := s.dialect.Rebind(fmt.Sprintf("select %s, %s,%s,%s from %s where %s = ?", s.commentIdCol, s.commentCol, s.historiesCol, s.authorCol, s.ReplyTable, s.commentIdCol))
 	rows := s.db.QueryRow(qr, commentId)
 	var exist = Comment{}
 	err := rows.Scan(&exist.CommentId, &exist.Comment, s.toArray(&exist.Histories), &exist.Author)
@@ -94,25 +112,41 @@ func (s *commentService) Update(ctx context.Context, commentId, author string, r
 		return -1, err
 	}
 	if exist.Author == "" || exist.Author != author {
-		return -2, errors.New("no permission on comment")
+		return -2, ErrNoPermission
 	}
 	updatedTime := time.Now()
 	exist.Histories = append(exist.Histories, History{
 		Comment: exist.Comment,
 		Time:    updatedTime,
 	})
-	qr1 := fmt.Sprintf("update %s set %s = $1, %s = $2, %s = $3", s.ReplyTable, s.commentCol, s.historiesCol, s.updatedAtCol)
-	rows2, er2 := s.db.ExecContext(ctx, qr1, req.Comment, s.toArray(exist.Histories), updatedTime)
+	qr1 := s.dialect.Rebind(fmt.Sprintf("update %s set %s = ?, %s = ?, %s = ? where %s = ?", s.ReplyTable, s.commentCol, s.historiesCol, s.updatedAtCol, s.commentIdCol))
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+	rows2, er2 := tx.ExecContext(ctx, qr1, req.Comment, s.toArray(exist.Histories), updatedTime, commentId)
 	if er2 != nil {
 		return -1, er2
 	}
-	return rows2.RowsAffected()
+	r, err := rows2.RowsAffected()
+	if err != nil {
+		return -1, err
+	}
+	err = outbox.Publish(ctx, tx, s.publishers, outbox.CommentUpdated{CommentId: commentId, Author: author, Old: exist.Comment, Comment: req.Comment, Time: updatedTime})
+	if err != nil {
+		return -1, err
+	}
+	if err = tx.Commit(); err != nil {
+		return -1, err
+	}
+	return r, nil
 }
 
 // Remove implements CommentThreadReplyService
 func (s *commentService) Remove(ctx context.Context, commentId string, commentThreadId string, author string) (int64, error) {
 	count := 0
-	err := s.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s=$1 and %s=$2", s.ReplyTable, s.commentIdCol, s.authorCol), commentId, author).Scan(&count)
+	err := s.db.QueryRow(s.dialect.Rebind(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s=? and %s=?", s.ReplyTable, s.commentIdCol, s.authorCol)), commentId, author).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -120,11 +154,12 @@ func (s *commentService) Remove(ctx context.Context, commentId string, commentTh
 		return 0, errors.New("user does not have permission to delete the comment")
 	}
 	rowsAffected := int64(0)
-	qr1 := fmt.Sprintf("delete from %s where %s = $1", s.ReplyTable, s.commentIdCol)
+	qr1 := s.dialect.Rebind(fmt.Sprintf("delete from %s where %s = ?", s.ReplyTable, s.commentIdCol))
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return -1, err
 	}
+	defer tx.Rollback()
 	rows, err := tx.ExecContext(ctx, qr1, commentId)
 	if err != nil {
 		return -1, err
@@ -134,7 +169,7 @@ func (s *commentService) Remove(ctx context.Context, commentId string, commentTh
 		return -1, err
 	}
 	rowsAffected += numberRows
-	qr2 := fmt.Sprintf("delete from %s where %s = $1", s.commentInfoTable, s.commentIdInfoCol)
+	qr2 := s.dialect.Rebind(fmt.Sprintf("delete from %s where %s = ?", s.commentInfoTable, s.commentIdInfoCol))
 	rows2, err := tx.ExecContext(ctx, qr2, commentId)
 	if err != nil {
 		return -1, err
@@ -145,7 +180,7 @@ func (s *commentService) Remove(ctx context.Context, commentId string, commentTh
 	}
 	rowsAffected += numberRows
 	if len(s.commentReactionTable) > 0 {
-		qr3 := fmt.Sprintf("delete from %s where %s = $1", s.commentReactionTable, s.commentIdReactionCol)
+		qr3 := s.dialect.Rebind(fmt.Sprintf("delete from %s where %s = ?", s.commentReactionTable, s.commentIdReactionCol))
 		rows3, err := tx.ExecContext(ctx, qr3, commentId)
 		if err != nil {
 			return -1, err
@@ -156,7 +191,7 @@ func (s *commentService) Remove(ctx context.Context, commentId string, commentTh
 		}
 		rowsAffected += numberRows
 	}
-	qr4 := fmt.Sprintf("update %s set %s = %s - 1 where %s = $1", s.commentThreadInfoTable, s.replyCountCommentThreadInfoCol, s.replyCountCommentThreadInfoCol, s.commentIdCommentThreadInfoCol)
+	qr4 := s.dialect.Rebind(fmt.Sprintf("update %s set %s = %s - 1 where %s = ?", s.commentThreadInfoTable, s.replyCountCommentThreadInfoCol, s.replyCountCommentThreadInfoCol, s.commentIdCommentThreadInfoCol))
 	rows4, err := tx.ExecContext(ctx, qr4, commentThreadId)
 	if err != nil {
 		return -1, err
@@ -166,6 +201,10 @@ func (s *commentService) Remove(ctx context.Context, commentId string, commentTh
 		return -1, err
 	}
 	rowsAffected += numberRows
+	err = outbox.Publish(ctx, tx, s.publishers, outbox.CommentDeleted{CommentId: commentId, CommentThreadId: commentThreadId, Author: author, Time: time.Now()})
+	if err != nil {
+		return -1, err
+	}
 	err = tx.Commit()
 	if err != nil {
 		return -1, err
@@ -175,6 +214,9 @@ func (s *commentService) Remove(ctx context.Context, commentId string, commentTh
 
 // Create implements CommentThreadReplyService
 func (s *commentService) Create(ctx context.Context, id, commentId, commentThreadId, author string, req Request) (int64, error) {
+	if err := block.CheckOwner(ctx, s.guard, s.owner, id, author); err != nil {
+		return -1, err
+	}
 	comment := Comment{Id: id, CommentId: commentId, CommentThreadId: commentThreadId, Author: author, Comment: req.Comment, Time: time.Now()}
 	rowsAffected := int64(0)
 	tx, err := s.db.BeginTx(ctx, nil)
@@ -183,8 +225,8 @@ func (s *commentService) Create(ctx context.Context, id, commentId, commentThrea
 	}
 	defer tx.Rollback()
 
-	qr := fmt.Sprintf("insert into %s(%s,%s,%s,%s,%s,%s,%s) values($1,$2,$3,$4,$5,$6,$7)",
-		s.ReplyTable, s.commentIdCol, s.idCol, s.authorCol, s.commentCol, s.timeCol, s.historiesCol, s.commentThreadIdCol)
+	qr := s.dialect.Rebind(fmt.Sprintf("insert into %s(%s,%s,%s,%s,%s,%s,%s) values(?,?,?,?,?,?,?)",
+		s.ReplyTable, s.commentIdCol, s.idCol, s.authorCol, s.commentCol, s.timeCol, s.historiesCol, s.commentThreadIdCol))
 	rows1, err := tx.ExecContext(ctx, qr, comment.CommentId, comment.Id, comment.Author, comment.Comment, time.Now(), s.toArray([]interface{}{}), comment.CommentThreadId)
 	if err != nil {
 		return -1, err
@@ -194,17 +236,11 @@ func (s *commentService) Create(ctx context.Context, id, commentId, commentThrea
 		return -1, err
 	}
 	rowsAffected += numberRows
-	qr2 := fmt.Sprintf("insert into %s(%s,%s,%s) values($1, 1, 0) on conflict(%s) do update set %s = %s.%s + 1 where %s.%s = $1",
-		s.commentThreadInfoTable,
-		s.commentIdCommentThreadInfoCol,
-		s.replyCountCommentThreadInfoCol,
-		s.usefulCountCommentThreadInfoCol,
-		s.commentIdCommentThreadInfoCol,
-		s.replyCountCommentThreadInfoCol,
-		s.commentThreadInfoTable,
-		s.replyCountCommentThreadInfoCol,
-		s.commentThreadInfoTable,
-		s.commentIdCommentThreadInfoCol)
+	qr2 := s.dialect.Rebind(s.dialect.Upsert(s.commentThreadInfoTable,
+		[]string{s.commentIdCommentThreadInfoCol, s.replyCountCommentThreadInfoCol, s.usefulCountCommentThreadInfoCol},
+		[]string{"?", "1", "0"},
+		[]string{s.commentIdCommentThreadInfoCol},
+		[]string{fmt.Sprintf("%s = %s.%s + 1", s.replyCountCommentThreadInfoCol, s.commentThreadInfoTable, s.replyCountCommentThreadInfoCol)}))
 	rows2, err := tx.ExecContext(ctx, qr2, comment.CommentThreadId)
 	if err != nil {
 		return -1, err
@@ -214,6 +250,11 @@ func (s *commentService) Create(ctx context.Context, id, commentId, commentThrea
 		return -1, err
 	}
 	rowsAffected += numberRows
+	err = outbox.Publish(ctx, tx, s.publishers, outbox.CommentReplied{CommentThreadId: comment.CommentThreadId, CommentId: comment.CommentId,
+		Id: comment.Id, Author: comment.Author, Comment: comment.Comment, Time: comment.Time})
+	if err != nil {
+		return -1, err
+	}
 	err = tx.Commit()
 	if err != nil {
 		return -1, err
@@ -229,18 +270,14 @@ func (s *commentService) GetComments(ctx context.Context, commentThreadId string
 	if userId != nil && len(*userId) > 0 {
 		arr = append(arr, userId)
 		qr = fmt.Sprintf(`, case when d.%s = 1 then true else false end as disable`, s.reactionCol)
-		qr2 = fmt.Sprintf(`left join %s d on a.%s = d.%s and d.%s = $1`,
+		qr2 = fmt.Sprintf(`left join %s d on a.%s = d.%s and d.%s = ?`,
 			s.commentReactionTable, s.commentIdCol, s.commentIdReactionCol, s.userIdCol)
 	}
-	param := "1"
-	if userId != nil && len(*userId) > 0 {
-		param = "2"
-	}
-	query := fmt.Sprintf(`select a.*,c.%s%s from %s a
+	query := s.dialect.Rebind(fmt.Sprintf(`select a.*,c.%s%s from %s a
                                   left join %s c on a.%s = c.%s %s 
-                                  where a.%s = $%s`, s.userfulCountInfoCol, qr, s.ReplyTable,
+                                  where a.%s = ?`, s.userfulCountInfoCol, qr, s.ReplyTable,
 		s.commentInfoTable, s.commentIdCol, s.commentIdInfoCol, qr2,
-		s.commentThreadIdCol, param)
+		s.commentThreadIdCol))
 	arr = append(arr, commentThreadId)
 	rows, err := s.db.QueryContext(ctx, query, arr...)
 	if err != nil {
@@ -250,7 +287,7 @@ func (s *commentService) GetComments(ctx context.Context, commentThreadId string
 	comments := []Comment{}
 	for rows.Next() {
 		var comment Comment
-		err := rows.Scan(
+		dest := []interface{}{
 			&comment.CommentId,
 			&comment.CommentThreadId,
 			&comment.Id,
@@ -260,7 +297,11 @@ func (s *commentService) GetComments(ctx context.Context, commentThreadId string
 			&comment.UpdatedAt,
 			s.toArray(&comment.Histories),
 			&comment.UsefulCount,
-		)
+		}
+		if len(qr) > 0 {
+			dest = append(dest, &comment.Disable)
+		}
+		err := rows.Scan(dest...)
 		if err != nil {
 			return nil, err
 		}
@@ -274,6 +315,21 @@ func (s *commentService) GetComments(ctx context.Context, commentThreadId string
 	for _, r := range comments {
 		ids = append(ids, r.Author)
 	}
+	if userId != nil {
+		muted, err := block.Muted(ctx, s.guard, *userId, ids)
+		if err != nil {
+			return nil, err
+		}
+		if len(muted) > 0 {
+			visible := make([]Comment, 0, len(comments))
+			for _, c := range comments {
+				if !muted[c.Author] {
+					visible = append(visible, c)
+				}
+			}
+			comments = visible
+		}
+	}
 	infos, err := s.queryInfo(ids)
 	if err != nil {
 		return nil, err
@@ -3,6 +3,7 @@ package mux
 import (
 	"context"
 	"encoding/json"
+	"github.com/core-go/reaction/block"
 	"github.com/core-go/reaction/commentthread/comment"
 	"net/http"
 
@@ -77,6 +78,10 @@ func (h *CommentHandler) Reply(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	res, err := h.service.Create(r.Context(), id, commentId, commentThreadId, author, obj)
+	if err == block.ErrBlocked {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"sort"
 
+	"github.com/core-go/reaction/dialect"
 	"golang.org/x/text/collate"
 	"golang.org/x/text/language"
 	_ "unicode/utf8"
@@ -25,13 +26,14 @@ type queryInfo struct {
 	id          string
 	name        string
 	displayName string
+	dialect     dialect.Dialect
 }
 
 func NewQueryInfo(db *sql.DB, table string, url string, id string, name string, displayName string, toArray func(interface{}) interface {
 	driver.Valuer
 	sql.Scanner
 }) queryInfo {
-	return queryInfo{db: db, table: table, url: url, id: id, name: name, displayName: displayName, toArray: toArray}
+	return queryInfo{db: db, table: table, url: url, id: id, name: name, displayName: displayName, toArray: toArray, dialect: dialect.New(db)}
 }
 
 func (i queryInfo) Load(ids []string) ([]Info, error) {
@@ -40,10 +42,15 @@ func (i queryInfo) Load(ids []string) ([]Info, error) {
 		return rs, nil
 	}
 	ids = distinct(ids)
-	querysql := fmt.Sprintf(`select %s as id, %s as url, COALESCE(%s,%s) as name from %s where %s = any(%s) and %s is not null order by %s`,
-		i.id, i.url, i.displayName, i.name, i.table, i.id, "$1", i.url, i.id)
+	in, params := i.dialect.InArray(i.id, ids, i.toArray)
+	querysql := i.dialect.Rebind(fmt.Sprintf(`select %s as id, %s as url, COALESCE(%s,%s) as name from %s where %s and %s is not null order by %s`,
+		i.id, i.url, i.displayName, i.name, i.table, in, i.url, i.id))
 	r := make([]Info, 0)
-	rows, err := i.db.Query(querysql, i.toArray(ids))
+	rows, err := i.db.Query(querysql, params...)
+	if err != nil {
+		return rs, err
+	}
+	defer rows.Close()
 	for rows.Next() {
 		var info Info
 		err := rows.Scan(&info.Id, &info.Url, &info.Name)
@@ -52,9 +59,6 @@ func (i queryInfo) Load(ids []string) ([]Info, error) {
 		}
 		r = append(r, info)
 	}
-	if err != nil {
-		return rs, err
-	}
 	return r, nil
 }
 
@@ -7,8 +7,15 @@ import (
 	"errors"
 	"fmt"
 	"time"
+
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
 )
 
+// ErrNoPermission is returned if a user updates the comment thread of another author.
+var ErrNoPermission = errors.New("no permission")
+
 type CommentThreadService interface {
 	Load(ctx context.Context, commentId string) (*CommentThread, error)
 	Comment(ctx context.Context, id string, commentId string, author string, comment Request) (int64, error)
@@ -41,6 +48,9 @@ func NewCommentThreadService(
 	commentIdReactionCol string,
 	reactionReplyTable string,
 	commentIdReactionRelyCol string,
+	guard block.Guard,
+	owner func(ctx context.Context, id string) (string, error),
+	publishers ...outbox.Publisher,
 ) CommentThreadService {
 	return &commentThreadService{
 		db:                          db,
@@ -64,6 +74,10 @@ func NewCommentThreadService(
 		commentIdReactionCol:        commentIdReactionCol,
 		reactionReplyTable:          reactionReplyTable,
 		commentIdReactionRelyCol:    commentIdReactionRelyCol,
+		guard:                       guard,
+		owner:                       owner,
+		dialect:                     dialect.New(db),
+		publishers:                  publishers,
 	}
 }
 
@@ -93,10 +107,16 @@ type commentThreadService struct {
 	commentIdReactionCol        string
 	reactionReplyTable          string
 	commentIdReactionRelyCol    string
+	guard                       block.Guard
+	// owner returns the author of the item id, to check that the author did not block the users who comment on it.
+	// It is required if guard is not nil.
+	owner      func(ctx context.Context, id string) (string, error)
+	dialect    dialect.Dialect
+	publishers []outbox.Publisher
 }
 
 func (s *commentThreadService) Load(ctx context.Context, commentId string) (*CommentThread, error) {
-	qr1 := fmt.Sprintf("Select * from %s where %s = $1", s.threadTable, s.commentIdThreadCol)
+	qr1 := s.dialect.Rebind(fmt.Sprintf("Select * from %s where %s = ?", s.threadTable, s.commentIdThreadCol))
 	res, err := s.db.QueryContext(ctx, qr1, commentId)
 	if err != nil {
 		return nil, err
@@ -121,15 +141,14 @@ func (s *commentThreadService) Load(ctx context.Context, commentId string) (*Com
 }
 
 func (s *commentThreadService) Comment(ctx context.Context, id string, commentId string, author string, crq Request) (int64, error) {
-	comment := CommentThread{Id: id, CommentId: commentId, Time: time.Now(), Author: author, Comment: crq.Comment}
-	qr1 := fmt.Sprintf("insert into %s(%s,%s,%s,%s,%s,%s) values($1, $2, $3, $4, $5, $6)",
-		s.threadTable, s.commentIdThreadCol, s.idThreadCol, s.authorThreadCol, s.commentThreadCol, s.timeThreadCol, s.historiesThreadCol)
-	res, err := s.db.ExecContext(ctx, qr1, comment.CommentId, comment.Id, comment.Author, comment.Comment, comment.Time, s.toArray([]History{}))
-	if err != nil {
+	if err := block.CheckOwner(ctx, s.guard, s.owner, id, author); err != nil {
 		return -1, err
 	}
-	return res.RowsAffected()
-
+	comment := CommentThread{Id: id, CommentId: commentId, Time: time.Now(), Author: author, Comment: crq.Comment}
+	qr1 := s.dialect.Rebind(fmt.Sprintf("insert into %s(%s,%s,%s,%s,%s,%s) values(?, ?, ?, ?, ?, ?)",
+		s.threadTable, s.commentIdThreadCol, s.idThreadCol, s.authorThreadCol, s.commentThreadCol, s.timeThreadCol, s.historiesThreadCol))
+	event := outbox.CommentCreated{CommentId: comment.CommentId, Id: comment.Id, Author: comment.Author, Comment: comment.Comment, Time: comment.Time}
+	return s.exec(ctx, event, qr1, comment.CommentId, comment.Id, comment.Author, comment.Comment, comment.Time, s.toArray([]History{}))
 }
 
 func (s *commentThreadService) Update(ctx context.Context, commentid string, author string, crq Request) (int64, error) {
@@ -140,17 +159,14 @@ func (s *commentThreadService) Update(ctx context.Context, commentid string, aut
 	}
 	if exist != nil {
 		if exist.Author != comment.Author {
-			return -2, errors.New("no permission")
+			return -2, ErrNoPermission
 		}
 		updatedTime := time.Now()
 		exist.Histories = append(exist.Histories, History{Comment: comment.Comment, Time: updatedTime})
-		qr1 := fmt.Sprintf("update %s set %s = $1, %s = $2, %s = $3 where %s = $4",
-			s.threadTable, s.commentThreadCol, s.updatedAtCol, s.historiesThreadCol, s.commentIdThreadCol)
-		res, err := s.db.ExecContext(ctx, qr1, comment.Comment, updatedTime, s.toArray(exist.Histories), comment.CommentId)
-		if err != nil {
-			return -1, err
-		}
-		return res.RowsAffected()
+		qr1 := s.dialect.Rebind(fmt.Sprintf("update %s set %s = ?, %s = ?, %s = ? where %s = ?",
+			s.threadTable, s.commentThreadCol, s.updatedAtCol, s.historiesThreadCol, s.commentIdThreadCol))
+		event := outbox.CommentUpdated{CommentId: comment.CommentId, Id: exist.Id, Author: comment.Author, Old: exist.Comment, Comment: comment.Comment, Time: updatedTime}
+		return s.exec(ctx, event, qr1, comment.Comment, updatedTime, s.toArray(exist.Histories), comment.CommentId)
 	}
 	return -1, nil
 }
@@ -163,7 +179,7 @@ func (s *commentThreadService) Remove(ctx context.Context, commentId string, aut
 		return -1, err
 	}
 	defer tx.Rollback()
-	qr1 := fmt.Sprintf("Delete from %s where %s = $1", s.threadTable, s.commentIdThreadCol)
+	qr1 := s.dialect.Rebind(fmt.Sprintf("Delete from %s where %s = ?", s.threadTable, s.commentIdThreadCol))
 	res, err := tx.ExecContext(ctx, qr1, commentId)
 	if err != nil {
 		return -1, err
@@ -173,9 +189,15 @@ func (s *commentThreadService) Remove(ctx context.Context, commentId string, aut
 		return -1, err
 	}
 	rowResult += rowsAffected
+	if rowsAffected > 0 {
+		err = outbox.Publish(ctx, tx, s.publishers, outbox.CommentDeleted{CommentId: commentId, Author: author, Time: time.Now()})
+		if err != nil {
+			return -1, err
+		}
+	}
 
 	if len(s.threadReplyTable) > 0 && len(s.commentThreadIdReplyCol) > 0 {
-		qr2 := fmt.Sprintf("delete from %s where %s = $1", s.threadReplyTable, s.commentThreadIdReplyCol)
+		qr2 := s.dialect.Rebind(fmt.Sprintf("delete from %s where %s = ?", s.threadReplyTable, s.commentThreadIdReplyCol))
 		res, err = tx.ExecContext(ctx, qr2, commentId)
 		if err != nil {
 			return -1, err
@@ -188,7 +210,7 @@ func (s *commentThreadService) Remove(ctx context.Context, commentId string, aut
 	}
 
 	if len(s.threadInfoTable) > 0 && len(s.commentIdthreadInfo) > 0 {
-		qr3 := fmt.Sprintf("delete from %s where %s = $1", s.threadInfoTable, s.commentIdthreadInfo)
+		qr3 := s.dialect.Rebind(fmt.Sprintf("delete from %s where %s = ?", s.threadInfoTable, s.commentIdthreadInfo))
 		res, err = tx.ExecContext(ctx, qr3, commentId)
 		if err != nil {
 			return -1, err
@@ -202,7 +224,7 @@ func (s *commentThreadService) Remove(ctx context.Context, commentId string, aut
 
 	var ids []string
 	if len(s.commentIdThreadReplyCol) > 0 && len(s.threadReplyInfoTable) > 0 {
-		idQr := fmt.Sprintf("select %s from %s where %s = $1", s.commentIdThreadReplyCol, s.threadReplyTable, s.commentThreadIdReplyCol)
+		idQr := s.dialect.Rebind(fmt.Sprintf("select %s from %s where %s = ?", s.commentIdThreadReplyCol, s.threadReplyTable, s.commentThreadIdReplyCol))
 		rows, err := s.db.QueryContext(ctx, idQr, commentId)
 		if err != nil {
 			return -1, err
@@ -213,10 +235,10 @@ func (s *commentThreadService) Remove(ctx context.Context, commentId string, aut
 			rows.Scan(&id)
 			ids = append(ids, id)
 		}
-		qr4 := fmt.Sprintf("delete from %s a where a.%s = ANY($1)",
-			s.threadReplyInfoTable, s.commentIdThreadReplyInfoCol)
+		cond, args := s.dialect.InArray(s.commentIdThreadReplyInfoCol, ids, s.toArray)
+		qr4 := s.dialect.Rebind(fmt.Sprintf("delete from %s where %s", s.threadReplyInfoTable, cond))
 
-		res, err = tx.ExecContext(ctx, qr4, s.toArray(ids))
+		res, err = tx.ExecContext(ctx, qr4, args...)
 		if err != nil {
 			return -1, err
 		}
@@ -228,8 +250,8 @@ func (s *commentThreadService) Remove(ctx context.Context, commentId string, aut
 	}
 
 	if len(s.reactionTable) > 0 {
-		qr5 := fmt.Sprintf("delete from %s a where %s = $1",
-			s.reactionTable, s.commentIdReactionCol)
+		qr5 := s.dialect.Rebind(fmt.Sprintf("delete from %s where %s = ?",
+			s.reactionTable, s.commentIdReactionCol))
 
 		res, err = tx.ExecContext(ctx, qr5, commentId)
 		if err != nil {
@@ -242,10 +264,10 @@ func (s *commentThreadService) Remove(ctx context.Context, commentId string, aut
 		rowResult += rowsAffected
 	}
 	if len(s.reactionReplyTable) > 0 {
-		qr6 := fmt.Sprintf("delete from %s a where a.%s = ANY($1)",
-			s.reactionReplyTable, s.commentIdReactionRelyCol)
+		cond, args := s.dialect.InArray(s.commentIdReactionRelyCol, ids, s.toArray)
+		qr6 := s.dialect.Rebind(fmt.Sprintf("delete from %s where %s", s.reactionReplyTable, cond))
 
-		res2, err := tx.ExecContext(ctx, qr6, s.toArray(ids))
+		res2, err := tx.ExecContext(ctx, qr6, args...)
 		if err != nil {
 			return -1, err
 		}
@@ -261,3 +283,28 @@ func (s *commentThreadService) Remove(ctx context.Context, commentId string, aut
 	}
 	return rowResult, nil
 }
+
+func (s *commentThreadService) exec(ctx context.Context, event outbox.Event, query string, args ...interface{}) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return -1, err
+	}
+	r, err := res.RowsAffected()
+	if err != nil {
+		return -1, err
+	}
+	if r > 0 {
+		if err = outbox.Publish(ctx, tx, s.publishers, event); err != nil {
+			return -1, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return -1, err
+	}
+	return r, nil
+}
@@ -3,6 +3,7 @@ package mux
 import (
 	"context"
 	"encoding/json"
+	"github.com/core-go/reaction/block"
 	"github.com/core-go/reaction/commentthread"
 	"github.com/gorilla/mux"
 	"net/http"
@@ -63,6 +64,10 @@ func (h *CommentThreadHandler) Comment(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, er1.Error(), http.StatusInternalServerError)
 	}
 	result, er3 := h.service.Comment(r.Context(), id, commentId, author, comment)
+	if er3 == block.ErrBlocked {
+		http.Error(w, er3.Error(), http.StatusForbidden)
+		return
+	}
 	if er3 != nil {
 		http.Error(w, er3.Error(), http.StatusInternalServerError)
 		return
@@ -88,9 +93,10 @@ func (h *CommentThreadHandler) Update(w http.ResponseWriter, r *http.Request) {
 	res, err1 := h.service.Update(r.Context(), commentId, author, comment)
 	if err1 != nil {
 		if res == -2 {
-			http.Error(w, err.Error(), http.StatusForbidden)
+			http.Error(w, err1.Error(), http.StatusForbidden)
+			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err1.Error(), http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
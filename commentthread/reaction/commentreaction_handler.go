@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+
+	"github.com/core-go/reaction/block"
 )
 
 type CommentReactionHandler struct {
@@ -31,6 +33,10 @@ func (h *CommentReactionHandler) SetUseful(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	res, err := h.service.Save(r.Context(), commentId, author, userId, 1)
+	if err == block.ErrBlocked {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
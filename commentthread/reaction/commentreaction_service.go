@@ -5,6 +5,10 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
 )
 
 type CommentReactionService interface {
@@ -23,10 +27,13 @@ type commentReactionService struct {
 	parentTable     string
 	parentIdCol     string
 	parentUsefulCol string
+	guard           block.Guard
+	dialect         dialect.Dialect
+	publishers      []outbox.Publisher
 }
 
 func NewCommentReactionService(db *sql.DB, reactionTable string, commentIdCol string,
-	authorCol string, userIdCol string, timeCol string, reactionCol string, parentTable string, parentIdCol string, parentUsefulCol string) CommentReactionService {
+	authorCol string, userIdCol string, timeCol string, reactionCol string, parentTable string, parentIdCol string, parentUsefulCol string, guard block.Guard, publishers ...outbox.Publisher) CommentReactionService {
 
 	return &commentReactionService{
 		db:              db,
@@ -39,6 +46,9 @@ func NewCommentReactionService(db *sql.DB, reactionTable string, commentIdCol st
 		parentTable:     parentTable,
 		parentIdCol:     parentIdCol,
 		parentUsefulCol: parentUsefulCol,
+		guard:           guard,
+		dialect:         dialect.New(db),
+		publishers:      publishers,
 	}
 }
 
@@ -49,8 +59,8 @@ func (s *commentReactionService) Remove(ctx context.Context, commentId string, a
 	if err != nil {
 		return -1, err
 	}
-	qr := fmt.Sprintf(`delete from %s where  %s= $1 and %s = $2 and %s= $3`,
-		s.reactionTable, s.commentIdCol, s.authorCol, s.userIdCol)
+	qr := s.dialect.Rebind(fmt.Sprintf(`delete from %s where  %s= ? and %s = ? and %s= ?`,
+		s.reactionTable, s.commentIdCol, s.authorCol, s.userIdCol))
 	rows, err := tx.ExecContext(ctx, qr, commentId, author, userId)
 	if err != nil {
 		return -1, err
@@ -59,9 +69,12 @@ func (s *commentReactionService) Remove(ctx context.Context, commentId string, a
 	if err != nil {
 		return -1, err
 	}
+	if numRows == 0 {
+		return 0, nil
+	}
 	result += numRows
-	qr = fmt.Sprintf(`update %s set %s = %s - 1 where %s = $1`,
-		s.parentTable, s.parentUsefulCol, s.parentUsefulCol, s.parentIdCol)
+	qr = s.dialect.Rebind(fmt.Sprintf(`update %s set %s = %s - 1 where %s = ?`,
+		s.parentTable, s.parentUsefulCol, s.parentUsefulCol, s.parentIdCol))
 	rows, err = tx.ExecContext(ctx, qr, commentId)
 	if err != nil {
 		return -1, err
@@ -71,6 +84,10 @@ func (s *commentReactionService) Remove(ctx context.Context, commentId string, a
 		return -1, err
 	}
 	result += numRows
+	err = outbox.Publish(ctx, tx, s.publishers, outbox.ReactionRemoved{Id: commentId, Author: author, UserId: userId, Time: time.Now()})
+	if err != nil {
+		return -1, err
+	}
 	err = tx.Commit()
 	if err != nil {
 		return -1, err
@@ -79,14 +96,17 @@ func (s *commentReactionService) Remove(ctx context.Context, commentId string, a
 }
 
 func (s *commentReactionService) Save(ctx context.Context, commentId string, author string, userId string, reaction int) (int64, error) {
+	if err := block.Check(ctx, s.guard, author, userId); err != nil {
+		return -1, err
+	}
 	result := int64(0)
 	tx, err := s.db.BeginTx(ctx, nil)
 	defer tx.Rollback()
 	if err != nil {
 		return -1, err
 	}
-	qr := fmt.Sprintf("insert into %s(%s,%s,%s,%s,%s) values($1,$2,$3,$4,$5)",
-		s.reactionTable, s.commentIdCol, s.authorCol, s.userIdCol, s.timeCol, s.reactionCol)
+	qr := s.dialect.Rebind(fmt.Sprintf("insert into %s(%s,%s,%s,%s,%s) values(?,?,?,?,?)",
+		s.reactionTable, s.commentIdCol, s.authorCol, s.userIdCol, s.timeCol, s.reactionCol))
 	res, err := tx.ExecContext(ctx, qr, commentId, author, userId, time.Now(), reaction)
 	if err != nil {
 		return -1, err
@@ -96,8 +116,9 @@ func (s *commentReactionService) Save(ctx context.Context, commentId string, aut
 		return -1, err
 	}
 	result += numRows
-	qr2 := fmt.Sprintf("insert into %s(%s,%s) values($1,1) on conflict(%s) do update set %s = %s.%s + 1 where %s.%s = $1",
-		s.parentTable, s.parentIdCol, s.parentUsefulCol, s.parentIdCol, s.parentUsefulCol, s.parentTable, s.parentUsefulCol, s.parentTable, s.parentIdCol)
+	qr2 := s.dialect.Rebind(s.dialect.Upsert(s.parentTable,
+		[]string{s.parentIdCol, s.parentUsefulCol}, []string{"?", "1"}, []string{s.parentIdCol},
+		[]string{fmt.Sprintf("%s = %s.%s + 1", s.parentUsefulCol, s.parentTable, s.parentUsefulCol)}))
 
 	rows, err := tx.ExecContext(ctx, qr2, commentId)
 	if err != nil {
@@ -108,6 +129,10 @@ func (s *commentReactionService) Save(ctx context.Context, commentId string, aut
 		return -1, err
 	}
 	result += numRows
+	err = outbox.Publish(ctx, tx, s.publishers, outbox.ReactionAdded{Id: commentId, Author: author, UserId: userId, Type: int8(reaction), Time: time.Now()})
+	if err != nil {
+		return -1, err
+	}
 	err = tx.Commit()
 	if err != nil {
 		return -1, err
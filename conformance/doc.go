@@ -0,0 +1,3 @@
+// Package conformance runs the same tests against the memory services and the SQL services,
+// so that the memory services can stand in for the SQL services in the tests of their users.
+package conformance
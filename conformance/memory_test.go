@@ -0,0 +1,156 @@
+package conformance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/core-go/reaction"
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/commentthread"
+	"github.com/core-go/reaction/feed"
+	"github.com/core-go/reaction/memory"
+	"github.com/core-go/reaction/rate"
+	"github.com/core-go/reaction/rates"
+)
+
+func TestMemoryReactionService(t *testing.T) {
+	testReactions(t, func(t *testing.T) reaction.ReactionService {
+		return memory.NewReactionService(nil)
+	})
+}
+
+func TestMemoryFollowService(t *testing.T) {
+	testFollows(t, func(t *testing.T, guard block.Guard) Follows {
+		service := memory.NewFollowService(nil)
+		service.Guard = guard
+		return memoryFollows(service)
+	})
+}
+
+func memoryFollows(service *memory.FollowService) Follows {
+	return Follows{
+		Service: service,
+		Count: func(t *testing.T, id string) (int64, int64) {
+			return service.Count(id)
+		},
+		SetApproval: func(t *testing.T, id string, approval bool) {
+			service.SetApproval(id, approval)
+		},
+	}
+}
+
+func TestMemoryRateService(t *testing.T) {
+	testRates(t, func(t *testing.T) Rates {
+		service := memory.NewRateService(rate.NewScale(5))
+		return Rates{
+			Service: service,
+			Info: func(t *testing.T, id string) *rate.RateInfo {
+				return service.Info(id)
+			},
+		}
+	})
+}
+
+func TestMemoryUserReactionService(t *testing.T) {
+	testUserReactions(t, func(t *testing.T) UserReactions {
+		service := memory.NewUserReactionService()
+		return UserReactions{
+			Service: service,
+			Count: func(t *testing.T, id string, level int64) (int64, int64) {
+				return service.Count(id, level)
+			},
+		}
+	})
+}
+
+func TestMemoryRatesService(t *testing.T) {
+	testMultiRates(t, func(t *testing.T) MultiRates {
+		service := memory.NewRatesService(2)
+		return MultiRates{
+			Service: service,
+			Info: func(t *testing.T, id string) *memory.RatesInfo {
+				return service.Info(id)
+			},
+			Load: func(t *testing.T, id string, author string) *rates.Rates {
+				r, err := service.Load(context.Background(), id, author)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return r
+			},
+		}
+	})
+}
+
+func TestMemorySaveService(t *testing.T) {
+	testSaves(t, func(t *testing.T, max int) Saves {
+		service := memory.NewSaveService(max)
+		return Saves{
+			Service: service,
+			Put: func(t *testing.T, item string) {
+				service.Put(item, &target{Id: item})
+			},
+		}
+	})
+}
+
+func TestMemoryCommentService(t *testing.T) {
+	testComments(t, func(t *testing.T, guard block.Guard) Comments {
+		service := memory.NewCommentService(nil)
+		service.Guard = guard
+		return Comments{
+			Service: service,
+			Count: func(t *testing.T, id string, author string) int64 {
+				return service.Count(id, author)
+			},
+		}
+	})
+}
+
+func TestMemoryCommentThreadService(t *testing.T) {
+	testCommentThreads(t, func(t *testing.T) commentthread.CommentThreadService {
+		return memory.NewCommentThreadService()
+	})
+}
+
+func TestMemoryResponseService(t *testing.T) {
+	testResponses(t, func(t *testing.T) Responses {
+		service := memory.NewResponseService()
+		return Responses{
+			Service: service,
+			Count: func(t *testing.T, id string) int64 {
+				return service.Count(id)
+			},
+		}
+	})
+}
+
+func TestMemoryFeedService(t *testing.T) {
+	testFeeds(t, func(t *testing.T, strategy feed.Strategy) Feeds {
+		follows := memory.NewFollowService(nil)
+		return Feeds{
+			Service: memory.NewFeedService(follows, strategy, nil),
+			Follow: func(t *testing.T, id string, target string) {
+				if _, err := follows.Follow(context.Background(), id, target); err != nil {
+					t.Fatal(err)
+				}
+			},
+		}
+	})
+}
+
+func TestMemoryBlockService(t *testing.T) {
+	testBlocks(t, func(t *testing.T) Blocks {
+		follows := memory.NewFollowService(nil)
+		service := memory.NewBlockService(follows)
+		follows.Guard = service
+		comments := memory.NewCommentService(nil)
+		comments.Guard = service
+		replies := memory.NewReplyService(memory.NewCommentThreadService(), nil)
+		replies.Guard = service
+		replies.Owner = func(ctx context.Context, id string) (string, error) {
+			return "author", nil
+		}
+		return Blocks{Service: service, Follows: memoryFollows(follows), Comments: comments, Replies: replies}
+	})
+}
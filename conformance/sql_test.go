@@ -0,0 +1,446 @@
+package conformance
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/core-go/reaction"
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/comment"
+	"github.com/core-go/reaction/commentthread"
+	reply "github.com/core-go/reaction/commentthread/comment"
+	"github.com/core-go/reaction/feed"
+	"github.com/core-go/reaction/follow"
+	"github.com/core-go/reaction/memory"
+	"github.com/core-go/reaction/rate"
+	"github.com/core-go/reaction/rates"
+	"github.com/core-go/reaction/response"
+	"github.com/core-go/reaction/save"
+	ur "github.com/core-go/reaction/user-reaction"
+)
+
+// openSqlite opens a new database file, so that the queries outside the transactions do not wait for them.
+func openSqlite(t *testing.T, stmts ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range stmts {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// jsonArray stores the arrays as JSON, since SQLite has no array type.
+type jsonArray struct {
+	v interface{}
+}
+
+func toArray(v interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+} {
+	return jsonArray{v: v}
+}
+
+func (a jsonArray) Value() (driver.Value, error) {
+	b, err := json.Marshal(a.v)
+	return string(b), err
+}
+
+func (a jsonArray) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), a.v)
+	case []byte:
+		return json.Unmarshal(v, a.v)
+	default:
+		return fmt.Errorf("cannot scan %s into an array", reflect.TypeOf(value))
+	}
+}
+
+func TestSqlReactionService(t *testing.T) {
+	testReactions(t, func(t *testing.T) reaction.ReactionService {
+		db := openSqlite(t,
+			"create table reactions (id varchar(40), author varchar(40), userid varchar(40), time timestamp, reaction integer, primary key (id, author, userid))",
+			"create table rates (id varchar(40), author varchar(40), usefulcount integer default 0, primary key (id, author))",
+			"insert into rates(id, author, usefulcount) values ('item', 'author', 0)")
+		return reaction.NewReactionService(db, "reactions", "id", "author", "userid", "time", "reaction", "rates", "id", "author", "usefulcount")
+	})
+}
+
+func TestSqlFollowService(t *testing.T) {
+	testFollows(t, func(t *testing.T, guard block.Guard) Follows {
+		return sqlFollows(t, openSqlite(t), guard)
+	})
+}
+
+// sqlFollows creates the follow tables in db.
+func sqlFollows(t *testing.T, db *sql.DB, guard block.Guard) Follows {
+	for _, stmt := range []string{
+		"create table follower (id varchar(40), follower varchar(40), time timestamp, primary key (id, follower))",
+		"create table following (id varchar(40), following varchar(40), time timestamp, primary key (id, following))",
+		"create table followrequests (id varchar(40), requester varchar(40), time timestamp, primary key (id, requester))",
+		"create table userinfo (id varchar(40) primary key, followercount integer default 0, followingcount integer default 0, approval boolean)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	service := follow.NewFollowService(db, "follower", "id", "follower", "following", "id", "following", "time",
+		"userinfo", "id", "followercount", "followingcount",
+		follow.WithRequests("followrequests", "id", "requester"), follow.WithApproval("approval"), follow.WithGuard(guard))
+	return Follows{
+		Service: service,
+		Count: func(t *testing.T, id string) (followers int64, following int64) {
+			err := db.QueryRow("select followercount, followingcount from userinfo where id = ?", id).Scan(&followers, &following)
+			if err != nil && err != sql.ErrNoRows {
+				t.Fatal(err)
+			}
+			return followers, following
+		},
+		SetApproval: func(t *testing.T, id string, approval bool) {
+			if _, err := db.Exec("insert into userinfo(id, approval) values (?, ?) on conflict (id) do update set approval = excluded.approval", id, approval); err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+}
+
+func TestSqlRateService(t *testing.T) {
+	testRates(t, func(t *testing.T) Rates {
+		db := openSqlite(t,
+			"create table rates (id varchar(40), author varchar(40), anonymous boolean default false, rate real, review text, time timestamp, usefulcount integer default 0, replycount integer default 0, histories text, primary key (id, author))",
+			"create table rateinfo (id varchar(40) primary key, rate real default 0, rate1 integer default 0, rate2 integer default 0, rate3 integer default 0, rate4 integer default 0, rate5 integer default 0, count integer default 0, score real default 0)")
+		service := rate.NewRateService(db, "rates", "id", "author", "anonymous", "rate", "review", "time", "usefulcount", "replycount",
+			"rateinfo", "id", "rate", "count", "score", toArray)
+		return Rates{
+			Service: service,
+			Info: func(t *testing.T, id string) *rate.RateInfo {
+				info := rate.RateInfo{Id: id, Histogram: make([]int, 5)}
+				h := info.Histogram
+				err := db.QueryRow("select rate, count, score, rate1, rate2, rate3, rate4, rate5 from rateinfo where id = ?", id).
+					Scan(&info.Rate, &info.Count, &info.Score, &h[0], &h[1], &h[2], &h[3], &h[4])
+				if err == sql.ErrNoRows {
+					return nil
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				return &info
+			},
+		}
+	})
+}
+
+func TestSqlUserReactionService(t *testing.T) {
+	testUserReactions(t, func(t *testing.T) UserReactions {
+		db := openSqlite(t,
+			"create table userreactions (id varchar(40), author varchar(40), reaction integer, primary key (id, author))",
+			"create table userinfo (id varchar(40) primary key, reactioncount integer default 0, level1count integer default 0, level2count integer default 0, level3count integer default 0)")
+		service := ur.NewUserReactionService(db, "userreactions", "id", "author", "reaction", "userinfo", "id", "reactioncount", "level", "count", nil, nil)
+		return UserReactions{
+			Service: service,
+			Count: func(t *testing.T, id string, level int64) (total int64, count int64) {
+				query := fmt.Sprintf("select reactioncount, level%dcount from userinfo where id = ?", level)
+				err := db.QueryRow(query, id).Scan(&total, &count)
+				if err != nil && err != sql.ErrNoRows {
+					t.Fatal(err)
+				}
+				return total, count
+			},
+		}
+	})
+}
+
+func TestSqlRatesService(t *testing.T) {
+	testMultiRates(t, func(t *testing.T) MultiRates {
+		return sqlMultiRates(t, openSqlite(t))
+	})
+}
+
+// sqlMultiRates creates the tables of the rates of 2 criteria in db.
+func sqlMultiRates(t *testing.T, db *sql.DB) MultiRates {
+	info := "(id varchar(40) primary key, rate real default 0, rate1 integer default 0, rate2 integer default 0, rate3 integer default 0, rate4 integer default 0, rate5 integer default 0, count integer default 0, score real default 0)"
+	for _, stmt := range []string{
+		"create table rates (id varchar(40), author varchar(40), anonymous boolean default false, rate real, rates text, review text, time timestamp, usefulcount integer default 0, replycount integer default 0, histories text, primary key (id, author))",
+		"create table ratesinfo (id varchar(40) primary key, rate real default 0, count integer default 0, score real default 0, rate1 real default 0, rate2 real default 0)",
+		"create table ratesinfo1 " + info,
+		"create table ratesinfo2 " + info,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	service := rates.NewRatesService(db, 2, "rates", "id", "rate", "rates", "review", "author", "anonymous", "time", "usefulcount", "replycount",
+		"ratesinfo", "id", "score", "count", "rate", []string{"ratesinfo1", "ratesinfo2"}, "id", "rate", "count", "score", toArray)
+	return MultiRates{
+		Service: service,
+		Info: func(t *testing.T, id string) *memory.RatesInfo {
+			info := memory.RatesInfo{Id: id, Rates: make([]float32, 2)}
+			err := db.QueryRow("select rate, count, score, rate1, rate2 from ratesinfo where id = ?", id).
+				Scan(&info.Rate, &info.Count, &info.Score, &info.Rates[0], &info.Rates[1])
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, table := range []string{"ratesinfo1", "ratesinfo2"} {
+				h := make([]int, 5)
+				err = db.QueryRow(fmt.Sprintf("select rate1, rate2, rate3, rate4, rate5 from %s where id = ?", table), id).
+					Scan(&h[0], &h[1], &h[2], &h[3], &h[4])
+				if err != nil {
+					t.Fatal(err)
+				}
+				counts := make(map[int]int)
+				for k, n := range h {
+					counts[k+1] = n
+				}
+				info.Counts = append(info.Counts, counts)
+			}
+			return &info
+		},
+		Load: func(t *testing.T, id string, author string) *rates.Rates {
+			r := rates.Rates{Id: id, Author: author}
+			err := db.QueryRow("select rate, rates, review, histories from rates where id = ? and author = ?", id, author).
+				Scan(&r.Rate, toArray(&r.Rates), &r.Review, toArray(&r.Histories))
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			return &r
+		},
+	}
+}
+
+func TestSqlSaveService(t *testing.T) {
+	testSaves(t, func(t *testing.T, max int) Saves {
+		db := openSqlite(t,
+			"create table saveditems (id varchar(40) primary key, items text)",
+			"create table targets (id varchar(40) primary key)")
+		service := save.NewSaveService(db, reflect.TypeOf(target{}), "saveditems", "id", "items", max, "targets", "id", toArray)
+		return Saves{
+			Service: service,
+			Put: func(t *testing.T, item string) {
+				if _, err := db.Exec("insert into targets(id) values (?)", item); err != nil {
+					t.Fatal(err)
+				}
+			},
+		}
+	})
+}
+
+func TestSqlCommentService(t *testing.T) {
+	testComments(t, func(t *testing.T, guard block.Guard) Comments {
+		db := openSqlite(t,
+			"create table comments (commentid varchar(40) primary key, id varchar(40), author varchar(40), userid varchar(40), comment text, anonymous boolean default false, time timestamp, updatedat timestamp, histories text)",
+			"create table rates (id varchar(40), author varchar(40), commentcount integer default 0, primary key (id, author))",
+			"insert into rates(id, author) values ('item', 'author')")
+		service := comment.NewCommentService(db, "comments", "commentid", "id", "author", "userid", "comment", "anonymous", "time", "updatedat",
+			"rates", "id", "author", "commentcount", "users", "id", "imageurl", "username", nil, toArray, guard)
+		return Comments{
+			Service: service,
+			Count: func(t *testing.T, id string, author string) (count int64) {
+				if err := db.QueryRow("select commentcount from rates where id = ? and author = ?", id, author).Scan(&count); err != nil {
+					t.Fatal(err)
+				}
+				return count
+			},
+		}
+	})
+}
+
+func TestSqlCommentThreadService(t *testing.T) {
+	testCommentThreads(t, func(t *testing.T) commentthread.CommentThreadService {
+		db := openSqlite(t,
+			"create table commentthread (commentid varchar(40) primary key, id varchar(40), author varchar(40), comment text, time timestamp, updatedat timestamp, histories text)")
+		return commentthread.NewCommentThreadService(db, toArray, "commentthread", "commentid", "id", "author", "histories", "comment", "time", "updatedat",
+			"", "", "", "", "", "", "", "", "", "", "", nil, nil)
+	})
+}
+
+func TestSqlResponseService(t *testing.T) {
+	testResponses(t, func(t *testing.T) Responses {
+		return sqlResponses(t, openSqlite(t))
+	})
+}
+
+// sqlResponses creates the response tables in db.
+func sqlResponses(t *testing.T, db *sql.DB) Responses {
+	for _, stmt := range []string{
+		"create table responses (id varchar(40), author varchar(40), description text, time timestamp, usefulcount integer default 0, commentcount integer default 0, histories text, primary key (id, author))",
+		"create table responseinfo (id varchar(40) primary key, responsecount integer default 0)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	service := response.NewResponseService(db, "responses", "id", "author", "description", "time", "usefulcount", "commentcount",
+		"responseinfo", "id", "responsecount", toArray)
+	return Responses{
+		Service: service,
+		Count: func(t *testing.T, id string) (count int64) {
+			err := db.QueryRow("select responsecount from responseinfo where id = ?", id).Scan(&count)
+			if err != nil && err != sql.ErrNoRows {
+				t.Fatal(err)
+			}
+			return count
+		},
+	}
+}
+
+// TestSqlLoadErrors stores histories which cannot be read, so that the rate and the response fail instead of overwriting them as new ones.
+func TestSqlLoadErrors(t *testing.T) {
+	ctx := context.Background()
+	db := openSqlite(t)
+	r := sqlResponses(t, db)
+	if _, err := db.Exec("insert into responses(id, author, description, histories) values ('item', 'author', 'old', 'not json')"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, err := r.Service.Response(ctx, &response.Response{Id: "item", Author: "author", Description: "new", Time: &now}); err == nil {
+		t.Error("Response() over a response which cannot be loaded succeeded, want an error")
+	}
+	if n := r.Count(t, "item"); n != 0 {
+		t.Errorf("response counter = %d, want 0", n)
+	}
+
+	m := sqlMultiRates(t, db)
+	if _, err := db.Exec("insert into rates(id, author, rate, rates, histories) values ('item', 'author', 3, '[3,3]', 'not json')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Service.Rate(ctx, "item", "author", &rates.Request{Rates: []float32{4, 5}}); err == nil {
+		t.Error("Rate() over a rate which cannot be loaded succeeded, want an error")
+	}
+	if info := m.Info(t, "item"); info != nil {
+		t.Errorf("rate info = %+v, want none", *info)
+	}
+}
+
+func TestSqlFeedService(t *testing.T) {
+	testFeeds(t, func(t *testing.T, strategy feed.Strategy) Feeds {
+		db := openSqlite(t,
+			"create table activities (id varchar(40) primary key, type varchar(20), actor varchar(40), item varchar(40), object varchar(40), content text, time timestamp)",
+			"create table feeds (userid varchar(40), activityid varchar(40), time timestamp, primary key (userid, activityid))",
+			"create table following (id varchar(40), following varchar(40), primary key (id, following))",
+			"create table follower (id varchar(40), follower varchar(40), primary key (id, follower))")
+		service := feed.NewFeedService(db, strategy, "activities", "id", "type", "actor", "item", "object", "content", "time",
+			"feeds", "userid", "activityid", "following", "id", "following", "follower", "id", "follower", nil)
+		return Feeds{
+			Service: service,
+			Follow: func(t *testing.T, id string, target string) {
+				if _, err := db.Exec("insert into following(id, following) values (?, ?)", id, target); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := db.Exec("insert into follower(id, follower) values (?, ?)", target, id); err != nil {
+					t.Fatal(err)
+				}
+			},
+		}
+	})
+}
+
+func TestSqlBlockService(t *testing.T) {
+	testBlocks(t, func(t *testing.T) Blocks {
+		db := openSqlite(t,
+			"create table blocks (id varchar(40), target varchar(40), time timestamp, primary key (id, target))",
+			"create table mutes (id varchar(40), target varchar(40), time timestamp, primary key (id, target))",
+			"create table comments (commentid varchar(40) primary key, id varchar(40), author varchar(40), userid varchar(40), comment text, anonymous boolean default false, time timestamp, updatedat timestamp, histories text)",
+			"create table rates (id varchar(40), author varchar(40), commentcount integer default 0, primary key (id, author))",
+			"insert into rates(id, author) values ('item', 'author')",
+			"create table replies (commentid varchar(40) primary key, commentthreadid varchar(40), id varchar(40), author varchar(40), comment text, time timestamp, updatedat timestamp, histories text)",
+			"create table replyinfo (commentid varchar(40) primary key, usefulcount integer default 0)",
+			"create table replyreactions (commentid varchar(40), author varchar(40), userid varchar(40), time timestamp, reaction integer, primary key (commentid, userid))",
+			"create table commentthreadinfo (commentid varchar(40) primary key, replycount integer default 0, usefulcount integer default 0)")
+		// The follow service is guarded by a block service which does not remove the follow relationships, since the block service which removes them uses the follow service.
+		guard := block.NewBlockService(db, "blocks", "mutes", "id", "target", "time", nil, toArray)
+		follows := sqlFollows(t, db, guard)
+		service := block.NewBlockService(db, "blocks", "mutes", "id", "target", "time", follows.Service, toArray)
+		comments := comment.NewCommentService(db, "comments", "commentid", "id", "author", "userid", "comment", "anonymous", "time", "updatedat",
+			"rates", "id", "author", "commentcount", "users", "id", "imageurl", "username", nil, toArray, service)
+		replies := reply.NewCommentService(db, "replies", "commentid", "author", "id", "updatedat", "comment", "userid", "time", "histories", "commentthreadid",
+			"reaction", "replyreactions", "commentid", "users", "id", "username", "imageurl", "replyinfo", "usefulcount", "commentid",
+			"commentthreadinfo", "commentid", "replycount", "usefulcount", func(ids []string) ([]reply.Info, error) {
+				return nil, nil
+			}, toArray, service, func(ctx context.Context, id string) (string, error) {
+				return "author", nil
+			})
+		return Blocks{Service: service, Follows: follows, Comments: comments, Replies: replies}
+	})
+}
+
+// TestSqlCheckFollow reads the rows stored by Follow(a, b): (a, b) in the follower table and (b, a) in the following table.
+func TestSqlCheckFollow(t *testing.T) {
+	db := openSqlite(t)
+	service := sqlFollows(t, db, nil).Service
+	for _, stmt := range []string{
+		"insert into follower(id, follower) values ('a', 'b')",
+		"insert into following(id, following) values ('b', 'a')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	if n, err := service.CheckFollow(ctx, "a", "b"); err != nil || n != 1 {
+		t.Errorf("CheckFollow(a, b) = %d, %v, want 1", n, err)
+	}
+	if n, err := service.CheckFollow(ctx, "b", "a"); err != nil || n != 0 {
+		t.Errorf("CheckFollow(b, a) = %d, %v, want 0", n, err)
+	}
+	followers, err := service.Followers(ctx, "b", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(followers.List) != 1 || followers.List[0].Id != "a" {
+		t.Errorf("Followers(b) = %+v, want a", *followers)
+	}
+}
+
+// TestSqlBulkFollowRollback fails the chunk of a bulk follow, so that its result has none of the targets of the chunk.
+func TestSqlBulkFollowRollback(t *testing.T) {
+	g := newGuard()
+	g.blocks[[2]string{"a", "x"}] = true
+	db := openSqlite(t)
+	f := sqlFollows(t, db, g)
+	if _, err := db.Exec("create trigger fail before insert on following when new.id = 'z' begin select raise(abort, 'failed'); end"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := f.Service.BulkFollow(context.Background(), "a", []string{"x", "y", "z"})
+	if err == nil {
+		t.Fatal("BulkFollow() succeeded, want the error of the trigger")
+	}
+	if result.Followed != 0 || len(result.Blocked) != 0 || len(result.Duplicates) != 0 || len(result.Requested) != 0 {
+		t.Errorf("BulkFollow() = %+v, want nothing of the rolled back chunk", *result)
+	}
+	if followers, following := f.Count(t, "a"); followers != 0 || following != 0 {
+		t.Errorf("counters of a = %d followers and %d following, want 0 and 0", followers, following)
+	}
+	if _, err = db.Exec("drop trigger fail"); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = f.Service.BulkFollow(context.Background(), "a", []string{"x", "y", "z"}); err != nil {
+		t.Fatal(err)
+	}
+	if result.Followed != 2 || !reflect.DeepEqual(result.Blocked, []string{"x"}) {
+		t.Errorf("BulkFollow() again = %+v, want y and z followed and x blocked", *result)
+	}
+}
@@ -0,0 +1,1080 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/core-go/reaction"
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/comment"
+	"github.com/core-go/reaction/commentthread"
+	reply "github.com/core-go/reaction/commentthread/comment"
+	"github.com/core-go/reaction/feed"
+	"github.com/core-go/reaction/follow"
+	"github.com/core-go/reaction/memory"
+	"github.com/core-go/reaction/outbox"
+	"github.com/core-go/reaction/rate"
+	"github.com/core-go/reaction/rates"
+	"github.com/core-go/reaction/response"
+	"github.com/core-go/reaction/save"
+	ur "github.com/core-go/reaction/user-reaction"
+)
+
+// The suites take the services with the accessors of their counters, which are not part of the service interfaces.
+
+type Follows struct {
+	Service follow.FollowService
+	// Count returns the follower and following counters of id.
+	Count func(t *testing.T, id string) (followers int64, following int64)
+	// SetApproval sets whether id approves the follow requests.
+	SetApproval func(t *testing.T, id string, approval bool)
+}
+
+type Rates struct {
+	Service rate.RateService
+	// Info returns the rate info of id, or nil if id was never rated.
+	Info func(t *testing.T, id string) *rate.RateInfo
+}
+
+type UserReactions struct {
+	Service ur.UserReactionService
+	// Count returns the reaction counter of id and its counter of the level.
+	Count func(t *testing.T, id string, level int64) (total int64, count int64)
+}
+
+type MultiRates struct {
+	Service rates.RatesService
+	// Info returns the summary of id, or nil if id was never rated. The counts of the histograms which are 0 are ignored.
+	Info func(t *testing.T, id string) *memory.RatesInfo
+	// Load returns the rate of author on id, or nil.
+	Load func(t *testing.T, id string, author string) *rates.Rates
+}
+
+type Saves struct {
+	Service save.SaveService
+	// Put adds the target of item, which Load returns as a *target.
+	Put func(t *testing.T, item string)
+}
+
+type Comments struct {
+	Service comment.CommentService
+	// Count returns the comment counter of the rate of author on id.
+	Count func(t *testing.T, id string, author string) int64
+}
+
+type Responses struct {
+	Service response.ResponseService
+	// Count returns the response counter of id.
+	Count func(t *testing.T, id string) int64
+}
+
+type Feeds struct {
+	Service feed.FeedService
+	// Follow makes id follow target.
+	Follow func(t *testing.T, id string, target string)
+}
+
+type Blocks struct {
+	Service block.BlockService
+	// Follows uses Service as its guard, and Service removes the follow relationships of Follows.
+	Follows Follows
+	// Comments and Replies use Service as their guard. The owner of the items of Replies is author.
+	Comments comment.CommentService
+	Replies  reply.CommentService
+}
+
+// target is the saved item.
+type target struct {
+	Id string `json:"id" gorm:"column:id;primary_key"`
+}
+
+// guard blocks the pairs of users of blocks, in both directions, and mutes the pairs (id, muted user) of mutes.
+type guard struct {
+	blocks map[[2]string]bool
+	mutes  map[[2]string]bool
+}
+
+func newGuard() guard {
+	return guard{blocks: make(map[[2]string]bool), mutes: make(map[[2]string]bool)}
+}
+
+func (g guard) IsBlocked(ctx context.Context, id string, userId string) (bool, error) {
+	return g.blocks[[2]string{id, userId}] || g.blocks[[2]string{userId, id}], nil
+}
+
+func (g guard) Blocked(ctx context.Context, id string, userIds []string) (map[string]bool, error) {
+	blocked := make(map[string]bool)
+	for _, userId := range userIds {
+		if b, _ := g.IsBlocked(ctx, id, userId); b {
+			blocked[userId] = true
+		}
+	}
+	return blocked, nil
+}
+
+func (g guard) Muted(ctx context.Context, id string, userIds []string) (map[string]bool, error) {
+	muted := make(map[string]bool)
+	for _, userId := range userIds {
+		if g.mutes[[2]string{id, userId}] {
+			muted[userId] = true
+		}
+	}
+	return muted, nil
+}
+
+// The rates are rated on the scale of NewScale(5).
+
+func testReactions(t *testing.T, newService func(t *testing.T) reaction.ReactionService) {
+	ctx := context.Background()
+	service := newService(t)
+	insert := func(userId string, status reaction.Status, count int64) {
+		t.Helper()
+		now := time.Now()
+		result, err := service.Insert(ctx, &reaction.Reaction{Id: "item", Author: "author", UserId: userId, Time: &now, Type: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != status || result.Count != count {
+			t.Errorf("Insert(%s) = %+v, want %s with count %d", userId, *result, status, count)
+		}
+	}
+	insert("u1", reaction.Created, 1)
+	insert("u1", reaction.AlreadyExisted, 1)
+	insert("u2", reaction.Created, 2)
+	if _, err := service.Insert(ctx, &reaction.Reaction{Id: "item", Author: "author", UserId: "u3", Type: 9}); err != reaction.ErrInvalidType {
+		t.Errorf("Insert() of an unknown type = %v, want %v", err, reaction.ErrInvalidType)
+	}
+	summary, err := service.Summary(ctx, "item", "author")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary["useful"] != 2 {
+		t.Errorf("Summary() = %v, want 2 useful", summary)
+	}
+	reactors, err := service.ListReactors(ctx, "item", "author", 0, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reactors.Total != 2 || len(reactors.List) != 1 || reactors.List[0].UserId != "u2" || len(reactors.Next) == 0 {
+		t.Errorf("ListReactors() = %+v, want u2 of 2 with a next cursor", *reactors)
+	}
+	reactors, err = service.ListReactors(ctx, "item", "author", 0, reactors.Next, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reactors.List) != 1 || reactors.List[0].UserId != "u1" {
+		t.Errorf("ListReactors() of the next page = %+v, want u1", *reactors)
+	}
+	checked, err := service.CheckReactions(ctx, "u1", []reaction.Key{{Id: "item", Author: "author"}, {Id: "other", Author: "author"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checked) != 1 || checked[reaction.Key{Id: "item", Author: "author"}] != 1 {
+		t.Errorf("CheckReactions() = %v, want only item", checked)
+	}
+	result, err := service.Delete(ctx, &reaction.Reaction{Id: "item", Author: "author", UserId: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != reaction.Removed || result.Count != 1 {
+		t.Errorf("Delete() = %+v, want removed with count 1", *result)
+	}
+	result, err = service.Delete(ctx, &reaction.Reaction{Id: "item", Author: "author", UserId: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != reaction.NotFound {
+		t.Errorf("second Delete() = %+v, want not_found", *result)
+	}
+}
+
+func testFollows(t *testing.T, newFollows func(t *testing.T, guard block.Guard) Follows) {
+	ctx := context.Background()
+	g := newGuard()
+	f := newFollows(t, g)
+	service := f.Service
+	followed := func(id string, target string, status follow.Status) {
+		t.Helper()
+		result, err := service.Follow(ctx, id, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != status {
+			t.Errorf("Follow(%s, %s) = %+v, want %s", id, target, *result, status)
+		}
+	}
+	counts := func(id string, followers int64, following int64) {
+		t.Helper()
+		if f1, f2 := f.Count(t, id); f1 != followers || f2 != following {
+			t.Errorf("counters of %s = %d followers and %d following, want %d and %d", id, f1, f2, followers, following)
+		}
+	}
+	followed("a", "b", follow.Followed)
+	followed("a", "b", follow.AlreadyFollowing)
+	followed("c", "b", follow.Followed)
+	counts("a", 0, 1)
+	counts("b", 2, 0)
+	if n, err := service.CheckFollow(ctx, "a", "b"); err != nil || n != 1 {
+		t.Errorf("CheckFollow(a, b) = %d, %v, want 1", n, err)
+	}
+	if n, err := service.CheckFollow(ctx, "b", "a"); err != nil || n != 0 {
+		t.Errorf("CheckFollow(b, a) = %d, %v, want 0", n, err)
+	}
+	followers, err := service.Followers(ctx, "b", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := userIds(followers); followers.Total != 2 || len(ids) != 2 || !ids["a"] || !ids["c"] {
+		t.Errorf("Followers(b) = %+v, want a and c", *followers)
+	}
+	following, err := service.Following(ctx, "a", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := userIds(following); following.Total != 1 || !ids["b"] {
+		t.Errorf("Following(a) = %+v, want b", *following)
+	}
+	followed("b", "a", follow.Followed)
+	relationship, err := service.Relationship(ctx, "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !relationship.Following || !relationship.FollowedBy || !relationship.Mutual || relationship.Pending {
+		t.Errorf("Relationship(a, b) = %+v, want mutual", *relationship)
+	}
+	mutual, err := service.MutualFollowers(ctx, "c", "b", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := userIds(mutual); mutual.Total != 0 || len(ids) != 0 {
+		t.Errorf("MutualFollowers(c, b) = %+v, want none", *mutual)
+	}
+	followed("c", "a", follow.Followed)
+	mutual, err = service.MutualFollowers(ctx, "c", "b", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := userIds(mutual); mutual.Total != 1 || !ids["a"] {
+		t.Errorf("MutualFollowers(c, b) = %+v, want a", *mutual)
+	}
+	batch, err := service.BatchMutualFollowers(ctx, "c", []string{"a", "b", "z"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := userIds(batch["a"]); batch["a"].Total != 1 || !ids["b"] {
+		t.Errorf("BatchMutualFollowers(c)[a] = %+v, want b", *batch["a"])
+	}
+	if ids := userIds(batch["b"]); batch["b"].Total != 1 || !ids["a"] {
+		t.Errorf("BatchMutualFollowers(c)[b] = %+v, want a", *batch["b"])
+	}
+	if z := batch["z"]; z == nil || z.Total != 0 || len(z.List) != 0 {
+		t.Errorf("BatchMutualFollowers(c)[z] = %+v, want none", z)
+	}
+	if batch, err = service.BatchMutualFollowers(ctx, "c", []string{"a", "b"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch["a"].List) != 1 || len(batch["b"].List) != 1 {
+		t.Errorf("BatchMutualFollowers(c) with a limit of 1 = %+v, %+v, want one user each", *batch["a"], *batch["b"])
+	}
+	var graph bytes.Buffer
+	if err = service.ExportGraph(ctx, "a", follow.FormatNDJSON, &graph); err != nil {
+		t.Fatal(err)
+	}
+	edges := make(map[follow.Edge]bool)
+	for decoder := json.NewDecoder(&graph); decoder.More(); {
+		var edge follow.Edge
+		if err = decoder.Decode(&edge); err != nil {
+			t.Fatal(err)
+		}
+		edge.Time = nil
+		edges[edge] = true
+	}
+	if len(edges) != 3 || !edges[follow.Edge{Direction: follow.DirectionFollowing, UserId: "b"}] ||
+		!edges[follow.Edge{Direction: follow.DirectionFollower, UserId: "b"}] || !edges[follow.Edge{Direction: follow.DirectionFollower, UserId: "c"}] {
+		t.Errorf("ExportGraph(a) = %v, want following b, follower b and follower c", edges)
+	}
+
+	result, err := service.UnFollow(ctx, "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != follow.Unfollowed {
+		t.Errorf("UnFollow(a, b) = %+v, want unfollowed", *result)
+	}
+	result, err = service.UnFollow(ctx, "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != follow.NotFollowing {
+		t.Errorf("second UnFollow(a, b) = %+v, want not_following", *result)
+	}
+	counts("a", 2, 0)
+	counts("b", 1, 1)
+
+	f.SetApproval(t, "d", true)
+	followed("a", "d", follow.Requested)
+	followed("a", "d", follow.AlreadyRequested)
+	pending, err := service.ListPendingRequests(ctx, "d", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := userIds(pending); pending.Total != 1 || !ids["a"] {
+		t.Errorf("ListPendingRequests(d) = %+v, want a", *pending)
+	}
+	relationship, err = service.Relationship(ctx, "a", "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !relationship.Pending || relationship.Following {
+		t.Errorf("Relationship(a, d) = %+v, want pending", *relationship)
+	}
+	if n, err := service.ApproveFollow(ctx, "d", "a"); err != nil || n != 1 {
+		t.Errorf("ApproveFollow(d, a) = %d, %v, want 1", n, err)
+	}
+	if n, err := service.ApproveFollow(ctx, "d", "a"); err != nil || n != 0 {
+		t.Errorf("second ApproveFollow(d, a) = %d, %v, want 0", n, err)
+	}
+	counts("d", 1, 0)
+	if n, err := service.RequestFollow(ctx, "a", "d"); err != nil || n != 0 {
+		t.Errorf("RequestFollow(a, d) of a follower = %d, %v, want 0", n, err)
+	}
+	if n, err := service.RequestFollow(ctx, "c", "d"); err != nil || n != 1 {
+		t.Errorf("RequestFollow(c, d) = %d, %v, want 1", n, err)
+	}
+	if n, err := service.RejectFollow(ctx, "d", "c"); err != nil || n != 1 {
+		t.Errorf("RejectFollow(d, c) = %d, %v, want 1", n, err)
+	}
+	if n, err := service.CancelRequest(ctx, "c", "d"); err != nil || n != 0 {
+		t.Errorf("CancelRequest(c, d) = %d, %v, want 0", n, err)
+	}
+	if n, err := service.RequestFollow(ctx, "c", "d"); err != nil || n != 1 {
+		t.Errorf("RequestFollow(c, d) = %d, %v, want 1", n, err)
+	}
+	g.blocks[[2]string{"c", "d"}] = true
+	if _, err := service.ApproveFollow(ctx, "d", "c"); err != block.ErrBlocked {
+		t.Errorf("ApproveFollow(d, c) after c blocked d = %v, want %v", err, block.ErrBlocked)
+	}
+	delete(g.blocks, [2]string{"c", "d"})
+	if n, err := service.CancelRequest(ctx, "c", "d"); err != nil || n != 1 {
+		t.Errorf("CancelRequest(c, d) = %d, %v, want 1", n, err)
+	}
+	counts("d", 1, 0)
+
+	if n, err := service.Disconnect(ctx, "a", "c"); err != nil || n != 1 {
+		t.Errorf("Disconnect(a, c) = %d, %v, want 1", n, err)
+	}
+	counts("a", 1, 1)
+	counts("c", 0, 1)
+
+	bulk, err := service.BulkFollow(ctx, "e", []string{"a", "b", "a", "d", "e", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bulk.Followed != 2 || len(bulk.Requested) != 1 || bulk.Requested[0] != "d" || len(bulk.Duplicates) != 1 || len(bulk.Skipped) != 2 {
+		t.Errorf("BulkFollow(e) = %+v, want a and b followed, d requested, a duplicated, e and empty skipped", *bulk)
+	}
+	counts("e", 0, 2)
+	counts("a", 2, 1)
+
+	targets := make([]string, follow.MaxLimit+1)
+	for i := range targets {
+		targets[i] = fmt.Sprintf("t%d", i)
+	}
+	if _, err = service.BulkFollow(ctx, "f", targets); err != nil {
+		t.Fatal(err)
+	}
+	if following, err = service.Following(ctx, "f", "", follow.MaxLimit+1); err != nil {
+		t.Fatal(err)
+	}
+	if len(following.List) != follow.MaxLimit || following.Total != follow.MaxLimit+1 || len(following.Next) == 0 {
+		t.Errorf("Following(f) over MaxLimit = %d users of %d, next %q, want %d users and a next page", len(following.List), following.Total, following.Next, follow.MaxLimit)
+	}
+
+	testBatchMutualFollowers(t, newFollows(t, newGuard()).Service)
+}
+
+func testBatchMutualFollowers(t *testing.T, service follow.FollowService) {
+	ctx := context.Background()
+	// c follows m1, m2 and m3, who follow x, and m1 follows y.
+	for _, edge := range [][2]string{{"c", "m1"}, {"c", "m2"}, {"c", "m3"}, {"m1", "x"}, {"m2", "x"}, {"m3", "x"}, {"m1", "y"}} {
+		if _, err := service.Follow(ctx, edge[0], edge[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	batch, err := service.BatchMutualFollowers(ctx, "c", []string{"x", "y", "z", "x"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]struct {
+		total int64
+		ids   []string
+	}{
+		"x": {3, []string{"m3", "m2"}},
+		"y": {1, []string{"m1"}},
+		"z": {0, []string{}},
+	}
+	for target, w := range want {
+		users := batch[target]
+		if users == nil {
+			t.Errorf("BatchMutualFollowers(c)[%s] = nil, want %d users", target, w.total)
+			continue
+		}
+		ids := make([]string, 0)
+		for _, u := range users.List {
+			ids = append(ids, u.Id)
+		}
+		if users.Total != w.total || !reflect.DeepEqual(ids, w.ids) {
+			t.Errorf("BatchMutualFollowers(c)[%s] = %v of %d, want %v of %d", target, ids, users.Total, w.ids, w.total)
+		}
+	}
+	targets := make([]string, 0, follow.MaxTargets+1)
+	for i := 0; i <= follow.MaxTargets; i++ {
+		targets = append(targets, fmt.Sprintf("t%d", i))
+	}
+	if _, err = service.BatchMutualFollowers(ctx, "c", targets, 2); err != follow.ErrTooManyTargets {
+		t.Errorf("BatchMutualFollowers() of %d targets = %v, want %v", len(targets), err, follow.ErrTooManyTargets)
+	}
+	if _, err = service.BatchMutualFollowers(ctx, "c", append(targets[:follow.MaxTargets], "t0"), 2); err != nil {
+		t.Errorf("BatchMutualFollowers() of %d distinct targets = %v, want no error", follow.MaxTargets, err)
+	}
+}
+
+func userIds(users *follow.Users) map[string]bool {
+	ids := make(map[string]bool)
+	for _, u := range users.List {
+		ids[u.Id] = true
+	}
+	return ids
+}
+
+func testRates(t *testing.T, newRates func(t *testing.T) Rates) {
+	ctx := context.Background()
+	r := newRates(t)
+	service := r.Service
+	rated := func(author string, value float32, want int64) {
+		t.Helper()
+		n, err := service.Rate(ctx, "item", author, rate.Request{Rate: value, Review: "review"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (want > 0) != (n > 0) {
+			t.Errorf("Rate(%s, %v) = %d, want %d", author, value, n, want)
+		}
+	}
+	info := func(count int, score float64, histogram ...int) {
+		t.Helper()
+		i := r.Info(t, "item")
+		if i == nil {
+			t.Fatal("rate info not found")
+		}
+		if i.Count != count || math.Abs(i.Score-score) > 1e-6 || math.Abs(float64(i.Rate)-score/float64(count)) > 1e-3 {
+			t.Errorf("rate info = %d rates, score %v and rate %v, want %d and %v", i.Count, i.Score, i.Rate, count, score)
+		}
+		for k, n := range histogram {
+			if k >= len(i.Histogram) || i.Histogram[k] != n {
+				t.Errorf("histogram = %v, want %v", i.Histogram, histogram)
+				break
+			}
+		}
+	}
+	if _, err := service.Rate(ctx, "item", "a", rate.Request{Rate: 6}); err != rate.ErrInvalidRate {
+		t.Errorf("Rate() out of the scale = %v, want %v", err, rate.ErrInvalidRate)
+	}
+	rated("a", 5, 1)
+	rated("b", 3, 1)
+	info(2, 8, 0, 0, 1, 0, 1)
+	rated("b", 3, 0)
+	rated("b", 4, 1)
+	info(2, 9, 0, 0, 0, 1, 1)
+
+	loaded, err := service.Load(ctx, "item", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded == nil || loaded.Rate != 4 {
+		t.Errorf("Load(b) = %+v, want rate 4", loaded)
+	}
+	history, err := service.History(ctx, "item", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history == nil || len(history.Versions) != 2 || history.Versions[0].Rate != 3 || history.Versions[1].Rate != 4 {
+		t.Errorf("History(b) = %+v, want the rates 3 and 4", history)
+	}
+	if _, err = service.Revert(ctx, "item", "b", 5); err != rate.ErrInvalidVersion {
+		t.Errorf("Revert() of an unknown version = %v, want %v", err, rate.ErrInvalidVersion)
+	}
+	if _, err = service.Revert(ctx, "item", "c", 0); err != rate.ErrNotFound {
+		t.Errorf("Revert() without rate = %v, want %v", err, rate.ErrNotFound)
+	}
+	if _, err = service.Revert(ctx, "item", "b", 0); err != nil {
+		t.Fatal(err)
+	}
+	info(2, 8, 0, 0, 1, 0, 1)
+
+	if n, err := service.Remove(ctx, "item", "a"); err != nil || n <= 0 {
+		t.Errorf("Remove(a) = %d, %v, want removed", n, err)
+	}
+	info(1, 3, 0, 0, 1, 0, 0)
+	if loaded, err = service.Load(ctx, "item", "a"); err != nil || loaded != nil {
+		t.Errorf("Load(a) after Remove = %+v, %v, want nil", loaded, err)
+	}
+}
+
+func testUserReactions(t *testing.T, newReactions func(t *testing.T) UserReactions) {
+	ctx := context.Background()
+	r := newReactions(t)
+	service := r.Service
+	counts := func(level int64, total int64, count int64) {
+		t.Helper()
+		if t1, c1 := r.Count(t, "u", level); t1 != total || c1 != count {
+			t.Errorf("counters of u = %d and %d of level %d, want %d and %d", t1, c1, level, total, count)
+		}
+	}
+	for _, author := range []string{"a", "b"} {
+		if n, err := service.React(ctx, "u", author, "1"); err != nil || n <= 0 {
+			t.Errorf("React(u, %s, 1) = %d, %v, want reacted", author, n, err)
+		}
+	}
+	counts(1, 2, 2)
+	if n, err := service.React(ctx, "u", "a", "3"); err != nil || n <= 0 {
+		t.Errorf("React(u, a, 3) = %d, %v, want changed", n, err)
+	}
+	counts(1, 2, 1)
+	counts(3, 2, 1)
+	if level, err := service.CheckReaction(ctx, "u", "a"); err != nil || level != 3 {
+		t.Errorf("CheckReaction(u, a) = %d, %v, want 3", level, err)
+	}
+	if level, err := service.CheckReaction(ctx, "u", "c"); err != nil || level != -1 {
+		t.Errorf("CheckReaction(u, c) = %d, %v, want -1", level, err)
+	}
+	if n, err := service.Unreact(ctx, "u", "b", "1"); err != nil || n <= 0 {
+		t.Errorf("Unreact(u, b, 1) = %d, %v, want removed", n, err)
+	}
+	counts(1, 1, 0)
+	levels, err := service.CheckReactions(ctx, "a", []string{"u", "v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 1 || levels["u"] != 3 {
+		t.Errorf("CheckReactions(a) = %v, want u with 3", levels)
+	}
+}
+
+// The rates of rates.RatesService are rated on 2 criteria.
+
+func testMultiRates(t *testing.T, newRates func(t *testing.T) MultiRates) {
+	ctx := context.Background()
+	r := newRates(t)
+	service := r.Service
+	rated := func(author string, values ...float32) {
+		t.Helper()
+		if n, err := service.Rate(ctx, "item", author, &rates.Request{Rates: values, Review: "review"}); err != nil || n <= 0 {
+			t.Errorf("Rate(%s, %v) = %d, %v, want rated", author, values, n, err)
+		}
+	}
+	info := func(count int, score float32, averages []float32, counts ...map[int]int) {
+		t.Helper()
+		i := r.Info(t, "item")
+		if i == nil {
+			t.Fatal("rates info not found")
+		}
+		if i.Count != count || math.Abs(float64(i.Score-score)) > 1e-3 || math.Abs(float64(i.Rate-score/float32(count))) > 1e-3 {
+			t.Errorf("rates info = %d rates, score %v and rate %v, want %d and %v", i.Count, i.Score, i.Rate, count, score)
+		}
+		for k, average := range averages {
+			if k >= len(i.Rates) || math.Abs(float64(i.Rates[k]-average)) > 1e-3 {
+				t.Errorf("averages = %v, want %v", i.Rates, averages)
+				break
+			}
+		}
+		for k, want := range counts {
+			got := make(map[int]int)
+			if k < len(i.Counts) {
+				for v, n := range i.Counts[k] {
+					if n != 0 {
+						got[v] = n
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("histogram of the criterion %d = %v, want %v", k, got, want)
+			}
+		}
+	}
+	if _, err := service.Rate(ctx, "item", "a", &rates.Request{Rates: []float32{5}}); err != rates.ErrInvalidRate {
+		t.Errorf("Rate() of 1 criterion = %v, want %v", err, rates.ErrInvalidRate)
+	}
+	if i := r.Info(t, "item"); i != nil {
+		t.Errorf("rates info after an invalid rate = %+v, want nil", *i)
+	}
+	rated("a", 5, 3)
+	rated("b", 3, 3)
+	info(2, 7, []float32{4, 3}, map[int]int{5: 1, 3: 1}, map[int]int{3: 2})
+	// The first criterion is unchanged, the second is.
+	rated("b", 3, 5)
+	info(2, 8, []float32{4, 4}, map[int]int{5: 1, 3: 1}, map[int]int{3: 1, 5: 1})
+
+	loaded := r.Load(t, "item", "b")
+	if loaded == nil || loaded.Rate != 4 || !reflect.DeepEqual(loaded.Rates, []float32{3, 5}) {
+		t.Fatalf("Load(b) = %+v, want the rates 3 and 5", loaded)
+	}
+	if len(loaded.Histories) != 1 || loaded.Histories[0].Rate != 3 {
+		t.Errorf("histories of b = %+v, want the rate 3", loaded.Histories)
+	}
+	if loaded = r.Load(t, "item", "c"); loaded != nil {
+		t.Errorf("Load(c) = %+v, want nil", *loaded)
+	}
+}
+
+func testSaves(t *testing.T, newSaves func(t *testing.T, max int) Saves) {
+	ctx := context.Background()
+	s := newSaves(t, 2)
+	service := s.Service
+	for _, item := range []string{"i1", "i2", "i3"} {
+		s.Put(t, item)
+	}
+	saved := func(item string, saved bool) {
+		t.Helper()
+		if n, err := service.Save(ctx, "u", item); err != nil || (n > 0) != saved {
+			t.Errorf("Save(u, %s) = %d, %v, want saved %v", item, n, err, saved)
+		}
+	}
+	load := func(id string, want ...string) {
+		t.Helper()
+		var list []interface{}
+		if err := service.Load(ctx, id, &list); err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]bool)
+		for _, v := range list {
+			if target, ok := v.(*target); ok {
+				got[target.Id] = true
+			} else {
+				t.Errorf("Load(%s) returned a %T, want a *target", id, v)
+			}
+		}
+		if len(list) != len(want) {
+			t.Errorf("Load(%s) = %v, want %v", id, got, want)
+		}
+		for _, item := range want {
+			if !got[item] {
+				t.Errorf("Load(%s) = %v, want %v", id, got, want)
+				break
+			}
+		}
+	}
+	saved("i1", true)
+	saved("i1", false)
+	saved("i2", true)
+	load("u", "i1", "i2")
+	// The oldest item is dropped over the max.
+	saved("i3", true)
+	load("u", "i2", "i3")
+	if n, err := service.Remove(ctx, "u", "i2"); err != nil || n <= 0 {
+		t.Errorf("Remove(u, i2) = %d, %v, want removed", n, err)
+	}
+	load("u", "i3")
+	if n, err := service.Remove(ctx, "v", "i1"); err != nil || n != 0 {
+		t.Errorf("Remove(v, i1) = %d, %v, want 0", n, err)
+	}
+	load("v")
+}
+
+func testComments(t *testing.T, newComments func(t *testing.T, guard block.Guard) Comments) {
+	ctx := context.Background()
+	g := newGuard()
+	c := newComments(t, g)
+	service := c.Service
+	count := func(want int64) {
+		t.Helper()
+		if n := c.Count(t, "item", "author"); n != want {
+			t.Errorf("comment counter = %d, want %d", n, want)
+		}
+	}
+	commented := func(commentId string, userId string) {
+		t.Helper()
+		if n, err := service.Create(ctx, "item", commentId, userId, "author", comment.Request{Comment: "comment of " + userId}); err != nil || n != 1 {
+			t.Errorf("Create(%s) = %d, %v, want 1", commentId, n, err)
+		}
+	}
+	load := func(userId string, want ...string) []comment.Response {
+		t.Helper()
+		list, err := service.Load(ctx, "item", "author", userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]bool)
+		for _, r := range list {
+			got[r.CommentId] = true
+		}
+		if len(list) != len(want) {
+			t.Errorf("Load() for %s = %v, want %v", userId, got, want)
+		}
+		for _, commentId := range want {
+			if !got[commentId] {
+				t.Errorf("Load() for %s = %v, want %v", userId, got, want)
+				break
+			}
+		}
+		return list
+	}
+	commented("c1", "u1")
+	commented("c2", "u2")
+	count(2)
+	if _, err := service.Create(ctx, "item", "c1", "u1", "author", comment.Request{Comment: "again"}); err == nil {
+		t.Error("Create() of an existing comment succeeded, want an error")
+	}
+	g.blocks[[2]string{"author", "u3"}] = true
+	if _, err := service.Create(ctx, "item", "c3", "u3", "author", comment.Request{Comment: "blocked"}); err != block.ErrBlocked {
+		t.Errorf("Create() of a blocked user = %v, want %v", err, block.ErrBlocked)
+	}
+	count(2)
+
+	if n, err := service.Update(ctx, "item", "c1", "u1", "author", comment.Request{Comment: "edited"}); err != nil || n != 1 {
+		t.Errorf("Update(c1) = %d, %v, want 1", n, err)
+	}
+	if n, err := service.Update(ctx, "item", "c9", "u1", "author", comment.Request{Comment: "edited"}); err != nil || n != 0 {
+		t.Errorf("Update(c9) = %d, %v, want 0", n, err)
+	}
+	for _, r := range load("viewer", "c1", "c2") {
+		if r.CommentId == "c1" && (r.Comment != "edited" || len(r.Histories) != 1 || r.Histories[0].Comment != "comment of u1") {
+			t.Errorf("c1 = %+v, want edited with the first comment in its histories", r)
+		}
+	}
+	g.mutes[[2]string{"viewer", "u2"}] = true
+	load("viewer", "c1")
+	load("other", "c1", "c2")
+
+	if n, err := service.Delete(ctx, "item", "c2", "author"); err != nil || n != 1 {
+		t.Errorf("Delete(c2) = %d, %v, want 1", n, err)
+	}
+	count(1)
+	if n, err := service.Delete(ctx, "item", "c2", "author"); err != nil || n != 0 {
+		t.Errorf("second Delete(c2) = %d, %v, want 0", n, err)
+	}
+	count(1)
+}
+
+func testCommentThreads(t *testing.T, newService func(t *testing.T) commentthread.CommentThreadService) {
+	ctx := context.Background()
+	service := newService(t)
+	if n, err := service.Comment(ctx, "item", "t1", "a", commentthread.Request{Comment: "first"}); err != nil || n != 1 {
+		t.Errorf("Comment(t1) = %d, %v, want 1", n, err)
+	}
+	if _, err := service.Comment(ctx, "item", "t1", "a", commentthread.Request{Comment: "again"}); err == nil {
+		t.Error("Comment() of an existing thread succeeded, want an error")
+	}
+	thread, err := service.Load(ctx, "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thread == nil || thread.Id != "item" || thread.Author != "a" || thread.Comment != "first" || thread.UpdatedAt != nil {
+		t.Fatalf("Load(t1) = %+v, want the comment first of a on item", thread)
+	}
+	if n, err := service.Update(ctx, "t1", "b", commentthread.Request{Comment: "edited"}); err != commentthread.ErrNoPermission || n != -2 {
+		t.Errorf("Update(t1) by another author = %d, %v, want -2 and %v", n, err, commentthread.ErrNoPermission)
+	}
+	if n, err := service.Update(ctx, "t9", "a", commentthread.Request{Comment: "edited"}); err != nil || n != -1 {
+		t.Errorf("Update(t9) = %d, %v, want -1", n, err)
+	}
+	if n, err := service.Update(ctx, "t1", "a", commentthread.Request{Comment: "edited"}); err != nil || n != 1 {
+		t.Errorf("Update(t1) = %d, %v, want 1", n, err)
+	}
+	if thread, err = service.Load(ctx, "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if thread == nil || thread.Comment != "edited" || thread.UpdatedAt == nil || len(thread.Histories) != 1 || thread.Histories[0].Comment != "edited" {
+		t.Errorf("Load(t1) after Update = %+v, want edited with 1 history", thread)
+	}
+	if n, err := service.Remove(ctx, "t1", "a"); err != nil || n <= 0 {
+		t.Errorf("Remove(t1) = %d, %v, want removed", n, err)
+	}
+	if thread, err = service.Load(ctx, "t1"); err != nil || thread != nil {
+		t.Errorf("Load(t1) after Remove = %+v, %v, want nil", thread, err)
+	}
+	if n, err := service.Remove(ctx, "t1", "a"); err != nil || n != 0 {
+		t.Errorf("second Remove(t1) = %d, %v, want 0", n, err)
+	}
+}
+
+func testResponses(t *testing.T, newResponses func(t *testing.T) Responses) {
+	ctx := context.Background()
+	r := newResponses(t)
+	service := r.Service
+	responded := func(author string, description string, changed bool) {
+		t.Helper()
+		now := time.Now()
+		n, err := service.Response(ctx, &response.Response{Id: "item", Author: author, Description: description, Time: &now})
+		if err != nil || (n > 0) != changed {
+			t.Errorf("Response(%s, %s) = %d, %v, want changed %v", author, description, n, err, changed)
+		}
+	}
+	count := func(want int64) {
+		t.Helper()
+		if n := r.Count(t, "item"); n != want {
+			t.Errorf("response counter = %d, want %d", n, want)
+		}
+	}
+	responded("a", "first", true)
+	responded("a", "first", false)
+	count(1)
+	responded("a", "second", true)
+	count(1)
+	loaded, err := service.Load(ctx, "item", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded == nil || loaded.Description != "second" || len(loaded.Histories) != 1 || loaded.Histories[0].Description != "first" {
+		t.Errorf("Load(a) = %+v, want second with first in its histories", loaded)
+	}
+	responded("b", "first", true)
+	count(2)
+	if loaded, err = service.Load(ctx, "item", "c"); err != nil || loaded != nil {
+		t.Errorf("Load(c) = %+v, %v, want nil", loaded, err)
+	}
+}
+
+// message returns the message of the event, as the relay delivers it.
+func message(t *testing.T, id string, event outbox.Event) outbox.Message {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return outbox.Message{Id: id, Type: event.EventType(), Payload: payload, Time: time.Now()}
+}
+
+func testFeeds(t *testing.T, newFeeds func(t *testing.T, strategy feed.Strategy) Feeds) {
+	for _, strategy := range []feed.Strategy{feed.FanOutOnRead, feed.FanOutOnWrite} {
+		t.Run(string(strategy), func(t *testing.T) {
+			testFeed(t, strategy, newFeeds(t, strategy))
+		})
+	}
+}
+
+func testFeed(t *testing.T, strategy feed.Strategy, f Feeds) {
+	ctx := context.Background()
+	service := f.Service
+	f.Follow(t, "u", "a")
+	f.Follow(t, "u", "b")
+	f.Follow(t, "v", "c")
+	// m1, m2 and m3 have the same time, so that the pages are split between them by their ids.
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	messages := []outbox.Message{
+		message(t, "m1", outbox.RateCreated{Id: "i1", Author: "a", Rating: outbox.Rating{Rate: 5, Review: "good"}, Time: t0}),
+		message(t, "m2", outbox.CommentCreated{CommentId: "cm1", Id: "i2", Author: "x", UserId: "b", Comment: "nice", Time: t0}),
+		message(t, "m3", outbox.Saved{Id: "a", Item: "i3", Time: t0}),
+		message(t, "m4", outbox.RateCreated{Id: "i1", Author: "c", Rating: outbox.Rating{Rate: 4}, Time: t0.Add(time.Minute)}),
+		message(t, "m5", outbox.RateCreated{Id: "i4", Author: "a", Rating: outbox.Rating{Rate: 1}, Anonymous: true, Time: t0}),
+		message(t, "m6", outbox.CommentCreated{CommentId: "cm2", Id: "i2", Author: "x", UserId: "b", Comment: "hidden", Anonymous: true, Time: t0}),
+		message(t, "m7", outbox.ResponseCreated{Id: "i5", Author: "a", Description: "first", Time: t0.Add(-time.Minute)}),
+		message(t, "m8", outbox.Followed{Id: "a", Target: "b", Time: t0}),
+	}
+	// The first message is delivered twice.
+	for _, m := range append(messages, messages[0]) {
+		if err := service.Send(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(activities *feed.Activities) []string {
+		list := make([]string, 0)
+		for _, a := range activities.List {
+			list = append(list, a.Id)
+		}
+		return list
+	}
+	load := func(userId string, cursor string, limit int64, want ...string) *feed.Activities {
+		t.Helper()
+		activities, err := service.Load(ctx, userId, cursor, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(activities); !reflect.DeepEqual(got, append(make([]string, 0), want...)) {
+			t.Errorf("Load(%s) = %v, want %v", userId, got, want)
+		}
+		return activities
+	}
+	page := load("u", "", 2, "m3", "m2")
+	if len(page.Next) == 0 {
+		t.Fatal("Load(u) of the first page has no next cursor")
+	}
+	if page = load("u", page.Next, 2, "m1", "m7"); len(page.Next) != 0 {
+		t.Errorf("Load(u) of the last page has the next cursor %q", page.Next)
+	}
+	if a := page.List[0]; a.Type != feed.Rated || a.Actor != "a" || a.Item != "i1" || a.Content != "good" || a.Time == nil || !a.Time.Equal(t0) {
+		t.Errorf("activity m1 = %+v, want the rate of a on i1", a)
+	}
+	load("v", "", 10, "m4")
+	load("x", "", 10)
+	activities, err := service.Activities(ctx, "a", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(activities); !reflect.DeepEqual(got, []string{"m3", "m1", "m7"}) {
+		t.Errorf("Activities(a) = %v, want m3, m1 and m7", got)
+	}
+	if _, err = service.Load(ctx, "u", "invalid", 10); err != feed.ErrInvalidCursor {
+		t.Errorf("Load() with an invalid cursor = %v, want %v", err, feed.ErrInvalidCursor)
+	}
+
+	// The activities recorded before w follows a are fanned out on read only.
+	f.Follow(t, "w", "a")
+	if strategy == feed.FanOutOnWrite {
+		load("w", "", 10)
+	} else {
+		load("w", "", 10, "m3", "m1", "m7")
+	}
+
+	removed := []outbox.Message{
+		message(t, "d1", outbox.CommentDeleted{CommentId: "cm1", Id: "i2", Author: "x", Time: t0}),
+		message(t, "d2", outbox.Unsaved{Id: "a", Item: "i3", Time: t0}),
+		message(t, "d3", outbox.RateDeleted{Id: "i1", Author: "a", Time: t0}),
+	}
+	for _, m := range removed {
+		if err = service.Send(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	load("u", "", 10, "m7")
+	load("v", "", 10, "m4")
+}
+
+func testBlocks(t *testing.T, newBlocks func(t *testing.T) Blocks) {
+	ctx := context.Background()
+	b := newBlocks(t)
+	service := b.Service
+	follows := b.Follows.Service
+	followed := func(id string, target string, status follow.Status) {
+		t.Helper()
+		result, err := follows.Follow(ctx, id, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != status {
+			t.Errorf("Follow(%s, %s) = %+v, want %s", id, target, *result, status)
+		}
+	}
+	counts := func(id string, followers int64, following int64) {
+		t.Helper()
+		if f1, f2 := b.Follows.Count(t, id); f1 != followers || f2 != following {
+			t.Errorf("counters of %s = %d followers and %d following, want %d and %d", id, f1, f2, followers, following)
+		}
+	}
+	blocked := func(id string, userId string, want bool) {
+		t.Helper()
+		if got, err := service.IsBlocked(ctx, id, userId); err != nil || got != want {
+			t.Errorf("IsBlocked(%s, %s) = %t, %v, want %t", id, userId, got, err, want)
+		}
+	}
+	followed("a", "b", follow.Followed)
+	followed("b", "a", follow.Followed)
+	followed("c", "b", follow.Followed)
+	b.Follows.SetApproval(t, "d", true)
+	followed("a", "d", follow.Requested)
+	counts("a", 1, 1)
+	counts("b", 2, 1)
+
+	if n, err := service.Block(ctx, "b", "a"); err != nil || n != 1 {
+		t.Errorf("Block(b, a) = %d, %v, want 1", n, err)
+	}
+	if n, err := service.Block(ctx, "b", "a"); err != nil || n != 0 {
+		t.Errorf("second Block(b, a) = %d, %v, want 0", n, err)
+	}
+	counts("a", 0, 0)
+	counts("b", 1, 0)
+	counts("c", 0, 1)
+	for _, users := range [][2]string{{"a", "b"}, {"b", "a"}} {
+		if n, err := follows.CheckFollow(ctx, users[0], users[1]); err != nil || n != 0 {
+			t.Errorf("CheckFollow(%s, %s) after Block = %d, %v, want 0", users[0], users[1], n, err)
+		}
+		blocked(users[0], users[1], true)
+		if _, err := follows.Follow(ctx, users[0], users[1]); err != block.ErrBlocked {
+			t.Errorf("Follow(%s, %s) after Block = %v, want %v", users[0], users[1], err, block.ErrBlocked)
+		}
+	}
+	blocked("a", "c", false)
+	if got, err := service.Blocked(ctx, "a", []string{"b", "c"}); err != nil || !reflect.DeepEqual(got, map[string]bool{"b": true}) {
+		t.Errorf("Blocked(a) = %v, %v, want b", got, err)
+	}
+	if got, err := service.Blocked(ctx, "b", []string{"a", "c"}); err != nil || !reflect.DeepEqual(got, map[string]bool{"a": true}) {
+		t.Errorf("Blocked(b) = %v, %v, want a", got, err)
+	}
+
+	if _, err := service.Block(ctx, "d", "a"); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := follows.ListPendingRequests(ctx, "d", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending.List) != 0 {
+		t.Errorf("ListPendingRequests(d) after Block = %+v, want none", *pending)
+	}
+
+	if n, err := service.Unblock(ctx, "b", "a"); err != nil || n != 1 {
+		t.Errorf("Unblock(b, a) = %d, %v, want 1", n, err)
+	}
+	blocked("a", "b", false)
+	followed("a", "b", follow.Followed)
+	counts("b", 2, 0)
+
+	for _, userId := range []string{"u1", "u2"} {
+		if _, err := b.Comments.Create(ctx, "item", "c"+userId, userId, "author", comment.Request{Comment: "comment of " + userId}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.Replies.Create(ctx, "item", "r"+userId, "t1", userId, reply.Request{Comment: "reply of " + userId}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	visible := func(userId string, want ...string) {
+		t.Helper()
+		comments, err := b.Comments.Load(ctx, "item", "author", userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range comments {
+			got = append(got, c.UserId)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Load() for %s = the comments of %v, want %v", userId, got, want)
+		}
+		replies, err := b.Replies.GetComments(ctx, "t1", &userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = nil
+		for _, r := range replies {
+			got = append(got, r.Author)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetComments() for %s = the replies of %v, want %v", userId, got, want)
+		}
+	}
+	if n, err := service.Mute(ctx, "viewer", "u2"); err != nil || n != 1 {
+		t.Errorf("Mute(viewer, u2) = %d, %v, want 1", n, err)
+	}
+	if got, err := service.Muted(ctx, "viewer", []string{"u1", "u2"}); err != nil || !reflect.DeepEqual(got, map[string]bool{"u2": true}) {
+		t.Errorf("Muted(viewer) = %v, %v, want u2", got, err)
+	}
+	if got, err := service.Muted(ctx, "u2", []string{"viewer"}); err != nil || len(got) != 0 {
+		t.Errorf("Muted(u2) = %v, %v, want none", got, err)
+	}
+	blocked("viewer", "u2", false)
+	visible("viewer", "u1")
+	visible("other", "u1", "u2")
+	if n, err := service.Unmute(ctx, "viewer", "u2"); err != nil || n != 1 {
+		t.Errorf("Unmute(viewer, u2) = %d, %v, want 1", n, err)
+	}
+	visible("viewer", "u1", "u2")
+}
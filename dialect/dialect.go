@@ -0,0 +1,253 @@
+package dialect
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	DriverPostgres   = "postgres"
+	DriverMysql      = "mysql"
+	DriverMssql      = "mssql"
+	DriverOracle     = "oracle"
+	DriverSqlite3    = "sqlite3"
+	DriverNotSupport = "no support"
+)
+
+var Postgres = Dialect{Driver: DriverPostgres}
+
+type Dialect struct {
+	Driver string
+}
+
+func New(db *sql.DB) Dialect {
+	return Dialect{Driver: GetDriver(db)}
+}
+
+// GetDriver returns the driver name of db. The unknown drivers are handled as Postgres, as the queries were written before the dialects,
+// so use Dialect{Driver: ...} directly for another database.
+func GetDriver(db *sql.DB) string {
+	if db == nil {
+		return DriverNotSupport
+	}
+	driver := reflect.TypeOf(db.Driver()).String()
+	switch driver {
+	case "*godror.drv", "*go_ora.OracleDriver":
+		return DriverOracle
+	case "*mysql.MySQLDriver":
+		return DriverMysql
+	case "*mssql.Driver":
+		return DriverMssql
+	case "*sqlite3.SQLiteDriver", "*sqlite.Driver":
+		return DriverSqlite3
+	default:
+		return DriverPostgres
+	}
+}
+
+func GetBuildByDriver(driver string) func(i int) string {
+	switch driver {
+	case DriverPostgres:
+		return BuildDollarParam
+	case DriverOracle:
+		return BuildOracleParam
+	case DriverMssql:
+		return BuildMsSqlParam
+	default:
+		return BuildParam
+	}
+}
+
+func ReplaceQueryArgs(driver string, query string) string {
+	if driver == DriverOracle || driver == DriverPostgres || driver == DriverMssql {
+		var x string
+		if driver == DriverOracle {
+			x = ":val"
+		} else if driver == DriverPostgres {
+			x = "$"
+		} else if driver == DriverMssql {
+			x = "@p"
+		}
+		i := 1
+		k := strings.Index(query, "?")
+		if k >= 0 {
+			for {
+				query = strings.Replace(query, "?", x+fmt.Sprintf("%v", i), 1)
+				i = i + 1
+				k := strings.Index(query, "?")
+				if k < 0 {
+					return query
+				}
+			}
+		}
+	}
+	return query
+}
+
+func BuildParam(i int) string {
+	return "?"
+}
+func BuildOracleParam(i int) string {
+	return ":" + strconv.Itoa(i)
+}
+func BuildMsSqlParam(i int) string {
+	return "@p" + strconv.Itoa(i)
+}
+func BuildDollarParam(i int) string {
+	return "$" + strconv.Itoa(i)
+}
+
+// Param returns the placeholder of the i-th (1-based) parameter.
+func (d Dialect) Param(i int) string {
+	return GetBuildByDriver(d.Driver)(i)
+}
+
+// Rebind converts the '?' placeholders of a query to the placeholders of the driver.
+func (d Dialect) Rebind(query string) string {
+	return ReplaceQueryArgs(d.Driver, query)
+}
+
+// Upsert builds an insert statement which updates the existing row identified by keys with sets.
+// If sets is empty, the existing row is left unchanged.
+// The placeholders of values come before the placeholders of sets.
+func (d Dialect) Upsert(table string, columns []string, values []string, keys []string, sets []string) string {
+	return d.UpsertRows(table, columns, [][]string{values}, keys, sets)
+}
+
+// UpsertRows builds the upsert of Upsert for several rows, the placeholders of the rows in order.
+// The rows must have distinct keys, since a statement cannot update a row twice.
+func (d Dialect) UpsertRows(table string, columns []string, rows [][]string, keys []string, sets []string) string {
+	values := make([]string, len(rows))
+	for i, row := range rows {
+		values[i] = "(" + strings.Join(row, ", ") + ")"
+	}
+	switch d.Driver {
+	case DriverMysql:
+		if len(sets) == 0 {
+			return fmt.Sprintf("insert ignore into %s(%s) values %s", table, strings.Join(columns, ", "), strings.Join(values, ", "))
+		}
+		return fmt.Sprintf("insert into %s(%s) values %s on duplicate key update %s",
+			table, strings.Join(columns, ", "), strings.Join(values, ", "), strings.Join(sets, ", "))
+	case DriverMssql, DriverOracle:
+		return d.merge(table, columns, rows, keys, sets)
+	default:
+		query := fmt.Sprintf("insert into %s(%s) values %s on conflict (%s) do ",
+			table, strings.Join(columns, ", "), strings.Join(values, ", "), strings.Join(keys, ", "))
+		if len(sets) == 0 {
+			return query + "nothing"
+		}
+		return query + "update set " + strings.Join(sets, ", ")
+	}
+}
+
+func (d Dialect) merge(table string, columns []string, rows [][]string, keys []string, sets []string) string {
+	selects := make([]string, len(rows))
+	for i, row := range rows {
+		values := make([]string, len(columns))
+		for j, c := range columns {
+			values[j] = row[j] + " as " + c
+		}
+		selects[i] = "select " + strings.Join(values, ", ")
+		if d.Driver == DriverOracle {
+			selects[i] += " from dual"
+		}
+	}
+	inserts := make([]string, len(columns))
+	for i, c := range columns {
+		inserts[i] = "s." + c
+	}
+	on := make([]string, len(keys))
+	for i, k := range keys {
+		on[i] = table + "." + k + " = s." + k
+	}
+	using := strings.Join(selects, " union all ")
+	query := fmt.Sprintf("merge into %s using (%s) s on (%s)", table, using, strings.Join(on, " and "))
+	if len(sets) > 0 {
+		query += " when matched then update set " + strings.Join(sets, ", ")
+	}
+	query += fmt.Sprintf(" when not matched then insert (%s) values (%s)", strings.Join(columns, ", "), strings.Join(inserts, ", "))
+	if d.Driver == DriverMssql {
+		query += ";"
+	}
+	return query
+}
+
+// ForUpdate builds a select statement which locks the selected rows until the end of the transaction.
+// SQLite locks the whole database on the first write of a transaction, so the rows are selected without a lock.
+func (d Dialect) ForUpdate(columns []string, table string, where string) string {
+	switch d.Driver {
+	case DriverMssql:
+		return fmt.Sprintf("select %s from %s with (updlock, rowlock) where %s", strings.Join(columns, ", "), table, where)
+	case DriverSqlite3:
+		return fmt.Sprintf("select %s from %s where %s", strings.Join(columns, ", "), table, where)
+	default:
+		return fmt.Sprintf("select %s from %s where %s for update", strings.Join(columns, ", "), table, where)
+	}
+}
+
+// Excluded refers to the value proposed for column in the set clause of an upsert.
+func (d Dialect) Excluded(column string) string {
+	switch d.Driver {
+	case DriverMysql:
+		return "values(" + column + ")"
+	case DriverMssql, DriverOracle:
+		return "s." + column
+	default:
+		return "excluded." + column
+	}
+}
+
+// UpdateSets builds the set clauses which update columns to the values proposed by an upsert.
+func (d Dialect) UpdateSets(columns ...string) []string {
+	sets := make([]string, len(columns))
+	for i, c := range columns {
+		sets[i] = c + " = " + d.Excluded(c)
+	}
+	return sets
+}
+
+// InArray builds the condition column is one of values.
+// Postgres binds values as a single array parameter, the other drivers expand it to a list of parameters.
+func (d Dialect) InArray(column string, values []string, toArray func(interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+}) (string, []interface{}) {
+	if d.Driver == DriverPostgres && toArray != nil {
+		return column + " = any(?)", []interface{}{toArray(values)}
+	}
+	if len(values) == 0 {
+		return "1 = 0", nil
+	}
+	params := make([]string, len(values))
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		params[i] = "?"
+		args[i] = v
+	}
+	return column + " in (" + strings.Join(params, ", ") + ")", args
+}
+
+// ILike builds a case insensitive like condition of column.
+func (d Dialect) ILike(column string, param string) string {
+	if d.Driver == DriverPostgres {
+		return column + " ilike " + param
+	}
+	return "lower(" + column + ") like lower(" + param + ")"
+}
+
+// Limit returns the clause to be appended to a query to take the first n rows.
+// On SQL Server, the query must have an order by clause.
+func (d Dialect) Limit(n int64) string {
+	switch d.Driver {
+	case DriverOracle:
+		return fmt.Sprintf("fetch first %d rows only", n)
+	case DriverMssql:
+		return fmt.Sprintf("offset 0 rows fetch next %d rows only", n)
+	default:
+		return fmt.Sprintf("limit %d", n)
+	}
+}
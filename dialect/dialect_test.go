@@ -0,0 +1,219 @@
+package dialect
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type unknownDriver struct{}
+
+func (unknownDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("not implemented")
+}
+
+func init() {
+	sql.Register("unknown", unknownDriver{})
+}
+
+func openSqlite(t *testing.T) (*sql.DB, Dialect) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err = db.Exec("create table counters (id varchar(40) primary key, name varchar(40), count integer)"); err != nil {
+		t.Fatal(err)
+	}
+	return db, New(db)
+}
+
+func TestGetDriver(t *testing.T) {
+	sqlite, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+	unknown, err := sql.Open("unknown", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unknown.Close()
+	tests := []struct {
+		name string
+		db   *sql.DB
+		want string
+	}{
+		{"sqlite", sqlite, DriverSqlite3},
+		{"unknown driver is postgres", unknown, DriverPostgres},
+		{"nil", nil, DriverNotSupport},
+	}
+	for _, tt := range tests {
+		if got := GetDriver(tt.db); got != tt.want {
+			t.Errorf("%s: GetDriver() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := "select * from t where a = ? and b = ?"
+	tests := []struct {
+		driver string
+		want   string
+	}{
+		{DriverPostgres, "select * from t where a = $1 and b = $2"},
+		{DriverOracle, "select * from t where a = :val1 and b = :val2"},
+		{DriverMssql, "select * from t where a = @p1 and b = @p2"},
+		{DriverMysql, query},
+		{DriverSqlite3, query},
+	}
+	for _, tt := range tests {
+		if got := (Dialect{Driver: tt.driver}).Rebind(query); got != tt.want {
+			t.Errorf("%s: Rebind() = %q, want %q", tt.driver, got, tt.want)
+		}
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	db, d := openSqlite(t)
+	columns := []string{"id", "name", "count"}
+	values := []string{"?", "?", "1"}
+	keys := []string{"id"}
+	insert := d.Rebind(d.Upsert("counters", columns, values, keys, nil))
+	increase := d.Rebind(d.Upsert("counters", columns, values, keys, append(d.UpdateSets("name"), "count = counters.count + 1")))
+
+	res, err := db.Exec(insert, "a", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Fatalf("insert affected %d rows, want 1", n)
+	}
+	res, err = db.Exec(insert, "a", "ignored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 0 {
+		t.Fatalf("insert of an existing key affected %d rows, want 0", n)
+	}
+	if _, err = db.Exec(increase, "a", "second"); err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	var count int
+	if err = db.QueryRow("select name, count from counters where id = ?", "a").Scan(&name, &count); err != nil {
+		t.Fatal(err)
+	}
+	if name != "second" || count != 2 {
+		t.Errorf("got (%s, %d), want (second, 2)", name, count)
+	}
+}
+
+func TestUpsertRows(t *testing.T) {
+	db, d := openSqlite(t)
+	if _, err := db.Exec("insert into counters(id, name, count) values ('a', 'a', 1)"); err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]string{{"?", "?", "1"}, {"?", "?", "1"}}
+	query := d.Rebind(d.UpsertRows("counters", []string{"id", "name", "count"}, rows, []string{"id"}, []string{"count = counters.count + 1"}))
+	if _, err := db.Exec(query, "a", "ignored", "b", "b"); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	r, err := db.Query("select id, count from counters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for r.Next() {
+		var id string
+		var count int
+		if err = r.Scan(&id, &count); err != nil {
+			t.Fatal(err)
+		}
+		counts[id] = count
+	}
+	if len(counts) != 2 || counts["a"] != 2 || counts["b"] != 1 {
+		t.Errorf("counts after UpsertRows() = %v, want a 2 and b 1", counts)
+	}
+}
+
+func TestMergeRows(t *testing.T) {
+	rows := [][]string{{"?", "1"}, {"?", "1"}}
+	want := "merge into t using (select ? as id, 1 as n from dual union all select ? as id, 1 as n from dual) s on (t.id = s.id)" +
+		" when matched then update set n = t.n + 1 when not matched then insert (id, n) values (s.id, s.n)"
+	if got := (Dialect{Driver: DriverOracle}).UpsertRows("t", []string{"id", "n"}, rows, []string{"id"}, []string{"n = t.n + 1"}); got != want {
+		t.Errorf("UpsertRows() = %q, want %q", got, want)
+	}
+}
+
+func TestInArrayLimitForUpdate(t *testing.T) {
+	db, d := openSqlite(t)
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := db.Exec("insert into counters(id, name, count) values (?, ?, 0)", id, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	in, params := d.InArray("id", []string{"a", "c", "d"}, nil)
+	var count int
+	if err := db.QueryRow(d.Rebind("select count(*) from counters where "+in), params...).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("InArray matched %d rows, want 2", count)
+	}
+	in, params = d.InArray("id", nil, nil)
+	if err := db.QueryRow(d.Rebind("select count(*) from counters where "+in), params...).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("InArray of no values matched %d rows, want 0", count)
+	}
+
+	rows, err := db.Query("select id from counters order by id " + d.Limit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	rows.Close()
+	if n != 2 {
+		t.Errorf("Limit(2) returned %d rows", n)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	var name string
+	if err = tx.QueryRow(d.Rebind(d.ForUpdate([]string{"name"}, "counters", "id = ?")), "b").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "b" {
+		t.Errorf("ForUpdate selected %q, want b", name)
+	}
+}
+
+func TestForUpdate(t *testing.T) {
+	tests := []struct {
+		driver string
+		want   string
+	}{
+		{DriverPostgres, "select a, b from t where id = ? for update"},
+		{DriverMssql, "select a, b from t with (updlock, rowlock) where id = ?"},
+		{DriverSqlite3, "select a, b from t where id = ?"},
+	}
+	for _, tt := range tests {
+		if got := (Dialect{Driver: tt.driver}).ForUpdate([]string{"a", "b"}, "t", "id = ?"); got != tt.want {
+			t.Errorf("%s: ForUpdate() = %q, want %q", tt.driver, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package feed
+
+import (
+	"time"
+
+	"github.com/core-go/reaction/outbox"
+)
+
+// Strategy is how the feed of a user is built from the activities of the users they follow.
+type Strategy string
+
+const (
+	// FanOutOnRead queries the activities of the followed users when the feed is loaded.
+	FanOutOnRead Strategy = "read"
+	// FanOutOnWrite copies each activity to the feeds of the followers of its actor when it is recorded.
+	// The activities recorded before a user follows the actor are not in the feed of the user.
+	FanOutOnWrite Strategy = "write"
+)
+
+const (
+	Rated     = "rated"
+	Commented = "commented"
+	Replied   = "replied"
+	Responded = "responded"
+	Saved     = "saved"
+)
+
+// Activity is done by Actor on Item. Object is the comment, for the comments and the replies.
+type Activity struct {
+	Id      string     `json:"id"`
+	Type    string     `json:"type"`
+	Actor   string     `json:"actor"`
+	Item    string     `json:"item"`
+	Object  string     `json:"object,omitempty"`
+	Content string     `json:"content,omitempty"`
+	Time    *time.Time `json:"time,omitempty"`
+	Name    *string    `json:"name,omitempty"`
+	Url     *string    `json:"url,omitempty"`
+}
+
+type Activities struct {
+	List []Activity `json:"list"`
+	Next string     `json:"next,omitempty"`
+}
+
+// ToActivity returns the activity of the message, or nil if the message is not an activity.
+// The anonymous rates and comments are not activities.
+func ToActivity(m outbox.Message) (*Activity, error) {
+	a := Activity{Id: m.Id}
+	switch m.Type {
+	case outbox.TypeRateCreated:
+		var e outbox.RateCreated
+		if err := m.Decode(&e); err != nil || e.Anonymous {
+			return nil, err
+		}
+		a.Type, a.Actor, a.Item, a.Content, a.Time = Rated, e.Author, e.Id, e.Rating.Review, &e.Time
+	case outbox.TypeCommentCreated:
+		var e outbox.CommentCreated
+		if err := m.Decode(&e); err != nil || e.Anonymous {
+			return nil, err
+		}
+		// The comments of the rates have the commenter in UserId, and the comment threads have it in Author.
+		actor := e.UserId
+		if len(actor) == 0 {
+			actor = e.Author
+		}
+		a.Type, a.Actor, a.Item, a.Object, a.Content, a.Time = Commented, actor, e.Id, e.CommentId, e.Comment, &e.Time
+	case outbox.TypeCommentReplied:
+		var e outbox.CommentReplied
+		if err := m.Decode(&e); err != nil {
+			return nil, err
+		}
+		a.Type, a.Actor, a.Item, a.Object, a.Content, a.Time = Replied, e.Author, e.Id, e.CommentId, e.Comment, &e.Time
+	case outbox.TypeResponseCreated:
+		var e outbox.ResponseCreated
+		if err := m.Decode(&e); err != nil {
+			return nil, err
+		}
+		a.Type, a.Actor, a.Item, a.Content, a.Time = Responded, e.Author, e.Id, e.Description, &e.Time
+	case outbox.TypeSaved:
+		var e outbox.Saved
+		if err := m.Decode(&e); err != nil {
+			return nil, err
+		}
+		a.Type, a.Actor, a.Item, a.Time = Saved, e.Id, e.Item, &e.Time
+	default:
+		return nil, nil
+	}
+	return &a, nil
+}
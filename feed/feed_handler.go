@@ -0,0 +1,79 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func NewFeedHandler(service FeedService, idIndex int) FeedHandler {
+	return FeedHandler{service: service, idIndex: idIndex}
+}
+
+type FeedHandler struct {
+	service FeedService
+	idIndex int
+}
+
+// Load returns the feed of the user at idIndex.
+func (h *FeedHandler) Load(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, h.service.Load)
+}
+
+// Activities returns the activities of the user at idIndex.
+func (h *FeedHandler) Activities(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, h.service.Activities)
+}
+
+func (h *FeedHandler) list(w http.ResponseWriter, r *http.Request, load func(ctx context.Context, id string, cursor string, limit int64) (*Activities, error)) {
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 {
+		return
+	}
+	var limit int64
+	if l := r.URL.Query().Get("limit"); len(l) > 0 {
+		var err error
+		limit, err = strconv.ParseInt(l, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	result, err := load(r.Context(), id, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if err == ErrInvalidCursor {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+func GetParam(r *http.Request, options ...int) string {
+	offset := 0
+	if len(options) > 0 && options[0] > 0 {
+		offset = options[0]
+	}
+	s := r.URL.Path
+	params := strings.Split(s, "/")
+	i := len(params) - 1 - offset
+	if i >= 0 {
+		return params[i]
+	} else {
+		return ""
+	}
+}
+func GetRequiredParam(w http.ResponseWriter, r *http.Request, options ...int) string {
+	p := GetParam(r, options...)
+	if len(p) == 0 {
+		http.Error(w, "parameter is required", http.StatusBadRequest)
+		return ""
+	}
+	return p
+}
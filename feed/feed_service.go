@@ -0,0 +1,255 @@
+package feed
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
+	"github.com/core-go/reaction/paging"
+)
+
+var ErrInvalidCursor = paging.ErrInvalidCursor
+
+// FeedService records the activities of the messages it receives from the outbox relay, and serves the feeds.
+type FeedService interface {
+	outbox.Sink
+	// Load returns the feed of userId: the activities of the users they follow, the latest first.
+	Load(ctx context.Context, userId string, cursor string, limit int64) (*Activities, error)
+	// Activities returns the activities of actor, the latest first.
+	Activities(ctx context.Context, actor string, cursor string, limit int64) (*Activities, error)
+}
+
+// NewFeedService returns a FeedService. The following table is read by FanOutOnRead, and the follower table and the feed table by FanOutOnWrite.
+// The following table has a row (id, following) for each user followed by id, and the follower table a row (id, follower) for each follower of id.
+// With the tables of follow.NewFollowService, they are its follower table and its following table respectively.
+// A strategy other than FanOutOnWrite is FanOutOnRead.
+func NewFeedService(
+	db *sql.DB,
+	strategy Strategy,
+	activityTable string,
+	idCol string,
+	typeCol string,
+	actorCol string,
+	itemCol string,
+	objectCol string,
+	contentCol string,
+	timeCol string,
+	feedTable string,
+	feedUserCol string,
+	feedActivityCol string,
+	followingTable string,
+	followingIdCol string,
+	followingCol string,
+	followerTable string,
+	followerIdCol string,
+	followerCol string,
+	queryInfo func(ids []string) ([]Info, error),
+) FeedService {
+	if strategy != FanOutOnWrite {
+		strategy = FanOutOnRead
+	}
+	return &feedService{
+		DB:              db,
+		Strategy:        strategy,
+		ActivityTable:   activityTable,
+		IdCol:           idCol,
+		TypeCol:         typeCol,
+		ActorCol:        actorCol,
+		ItemCol:         itemCol,
+		ObjectCol:       objectCol,
+		ContentCol:      contentCol,
+		TimeCol:         timeCol,
+		FeedTable:       feedTable,
+		FeedUserCol:     feedUserCol,
+		FeedActivityCol: feedActivityCol,
+		FollowingTable:  followingTable,
+		FollowingIdCol:  followingIdCol,
+		FollowingCol:    followingCol,
+		FollowerTable:   followerTable,
+		FollowerIdCol:   followerIdCol,
+		FollowerCol:     followerCol,
+		QueryInfo:       queryInfo,
+		Dialect:         dialect.New(db),
+	}
+}
+
+type feedService struct {
+	DB            *sql.DB
+	Strategy      Strategy
+	ActivityTable string
+	IdCol         string
+	TypeCol       string
+	ActorCol      string
+	ItemCol       string
+	ObjectCol     string
+	ContentCol    string
+	TimeCol       string
+	// FeedTable has the activities of the feeds of FanOutOnWrite, with the key (FeedUserCol, FeedActivityCol), and TimeCol.
+	FeedTable       string
+	FeedUserCol     string
+	FeedActivityCol string
+	FollowingTable  string
+	FollowingIdCol  string
+	FollowingCol    string
+	FollowerTable   string
+	FollowerIdCol   string
+	FollowerCol     string
+	QueryInfo       func(ids []string) ([]Info, error)
+	Dialect         dialect.Dialect
+}
+
+// Send records the activity of the message. It is idempotent, because the activity is keyed by the id of the message.
+// The deleted rates and comments and the unsaved items are removed from the feeds.
+func (s *feedService) Send(ctx context.Context, m outbox.Message) error {
+	switch m.Type {
+	case outbox.TypeCommentDeleted:
+		var e outbox.CommentDeleted
+		if err := m.Decode(&e); err != nil {
+			return err
+		}
+		return s.remove(ctx, fmt.Sprintf("%s in (?, ?) and %s = ?", s.TypeCol, s.ObjectCol), Commented, Replied, e.CommentId)
+	case outbox.TypeUnsaved:
+		var e outbox.Unsaved
+		if err := m.Decode(&e); err != nil {
+			return err
+		}
+		return s.remove(ctx, fmt.Sprintf("%s = ? and %s = ? and %s = ?", s.TypeCol, s.ActorCol, s.ItemCol), Saved, e.Id, e.Item)
+	case outbox.TypeRateDeleted:
+		var e outbox.RateDeleted
+		if err := m.Decode(&e); err != nil {
+			return err
+		}
+		return s.remove(ctx, fmt.Sprintf("%s = ? and %s = ? and %s = ?", s.TypeCol, s.ActorCol, s.ItemCol), Rated, e.Author, e.Id)
+	}
+	a, err := ToActivity(m)
+	if err != nil || a == nil {
+		return err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query1 := s.Dialect.Rebind(s.Dialect.Upsert(s.ActivityTable,
+		[]string{s.IdCol, s.TypeCol, s.ActorCol, s.ItemCol, s.ObjectCol, s.ContentCol, s.TimeCol}, []string{"?", "?", "?", "?", "?", "?", "?"}, []string{s.IdCol}, nil))
+	if _, err = tx.ExecContext(ctx, query1, a.Id, a.Type, a.Actor, a.Item, a.Object, a.Content, a.Time); err != nil {
+		return err
+	}
+	if s.Strategy == FanOutOnWrite {
+		// The feed rows which exist are skipped, so that a message delivered twice is fanned out once.
+		query2 := s.Dialect.Rebind(fmt.Sprintf("insert into %s (%s, %s, %s) select f.%s, ?, ? from %s f where f.%s = ? and not exists (select 1 from %s e where e.%s = f.%s and e.%s = ?)",
+			s.FeedTable, s.FeedUserCol, s.FeedActivityCol, s.TimeCol, s.FollowerCol, s.FollowerTable, s.FollowerIdCol,
+			s.FeedTable, s.FeedUserCol, s.FollowerCol, s.FeedActivityCol))
+		if _, err = tx.ExecContext(ctx, query2, a.Id, a.Time, a.Actor, a.Id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// remove deletes the activities which match where, and their feed rows.
+func (s *feedService) remove(ctx context.Context, where string, args ...interface{}) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if s.Strategy == FanOutOnWrite {
+		query1 := s.Dialect.Rebind(fmt.Sprintf("delete from %s where %s in (select %s from %s where %s)", s.FeedTable, s.FeedActivityCol, s.IdCol, s.ActivityTable, where))
+		if _, err = tx.ExecContext(ctx, query1, args...); err != nil {
+			return err
+		}
+	}
+	query2 := s.Dialect.Rebind(fmt.Sprintf("delete from %s where %s", s.ActivityTable, where))
+	if _, err = tx.ExecContext(ctx, query2, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *feedService) Load(ctx context.Context, userId string, cursor string, limit int64) (*Activities, error) {
+	fields := fmt.Sprintf("a.%s, a.%s, a.%s, a.%s, a.%s, a.%s, a.%s", s.IdCol, s.TypeCol, s.ActorCol, s.ItemCol, s.ObjectCol, s.ContentCol, s.TimeCol)
+	if s.Strategy == FanOutOnWrite {
+		from := fmt.Sprintf("%s e inner join %s a on a.%s = e.%s", s.FeedTable, s.ActivityTable, s.IdCol, s.FeedActivityCol)
+		return s.list(ctx, fields, from, "e."+s.FeedUserCol, "e."+s.TimeCol, "e."+s.FeedActivityCol, userId, cursor, limit)
+	}
+	from := fmt.Sprintf("%s a inner join %s f on f.%s = a.%s", s.ActivityTable, s.FollowingTable, s.FollowingCol, s.ActorCol)
+	return s.list(ctx, fields, from, "f."+s.FollowingIdCol, "a."+s.TimeCol, "a."+s.IdCol, userId, cursor, limit)
+}
+
+func (s *feedService) Activities(ctx context.Context, actor string, cursor string, limit int64) (*Activities, error) {
+	fields := fmt.Sprintf("a.%s, a.%s, a.%s, a.%s, a.%s, a.%s, a.%s", s.IdCol, s.TypeCol, s.ActorCol, s.ItemCol, s.ObjectCol, s.ContentCol, s.TimeCol)
+	return s.list(ctx, fields, s.ActivityTable+" a", "a."+s.ActorCol, "a."+s.TimeCol, "a."+s.IdCol, actor, cursor, limit)
+}
+
+// list returns the activities of from where keyCol is key, ordered by timeCol and idCol, the latest first.
+func (s *feedService) list(ctx context.Context, fields string, from string, keyCol string, timeCol string, idCol string, key string, cursor string, limit int64) (*Activities, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	where := fmt.Sprintf("%s = ?", keyCol)
+	params := []interface{}{key}
+	if len(cursor) > 0 {
+		t, id, err := paging.DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, t, t, id)
+		where += fmt.Sprintf(" and (%s < ? or (%s = ? and %s < ?))", timeCol, timeCol, idCol)
+	}
+	query := s.Dialect.Rebind(fmt.Sprintf("select %s from %s where %s order by %s desc, %s desc %s", fields, from, where, timeCol, idCol, s.Dialect.Limit(limit+1)))
+	rows, err := s.DB.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := &Activities{List: make([]Activity, 0)}
+	for rows.Next() {
+		var a Activity
+		var object, content sql.NullString
+		if err = rows.Scan(&a.Id, &a.Type, &a.Actor, &a.Item, &object, &content, &a.Time); err != nil {
+			return nil, err
+		}
+		a.Object, a.Content = object.String, content.String
+		result.List = append(result.List, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if int64(len(result.List)) > limit {
+		result.List = result.List[:limit]
+		last := result.List[limit-1]
+		if last.Time != nil {
+			result.Next = paging.EncodeCursor(*last.Time, last.Id)
+		}
+	}
+	if err = s.enrich(result.List); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// enrich sets the names and the urls of the actors.
+func (s *feedService) enrich(activities []Activity) error {
+	if s.QueryInfo == nil || len(activities) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(activities))
+	for _, a := range activities {
+		ids = append(ids, a.Actor)
+	}
+	infos, err := s.QueryInfo(ids)
+	if err != nil {
+		return err
+	}
+	for k := range activities {
+		i := paging.BinarySearch(infos, activities[k].Actor)
+		if i >= 0 && infos[i].Id == activities[k].Actor {
+			activities[k].Url = &infos[i].Url
+			activities[k].Name = &infos[i].Name
+		}
+	}
+	return nil
+}
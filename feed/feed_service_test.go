@@ -0,0 +1,115 @@
+package feed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/core-go/reaction/outbox"
+)
+
+func newTestService(t *testing.T, strategy Strategy) (*sql.DB, FeedService) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range []string{
+		"create table activities (id varchar(40) primary key, type varchar(20), actor varchar(40), item varchar(40), object varchar(40), content text, time timestamp)",
+		"create table feeds (userid varchar(40), activityid varchar(40), time timestamp, primary key (userid, activityid))",
+		"create table following (id varchar(40), following varchar(40), primary key (id, following))",
+		"create table follower (id varchar(40), follower varchar(40), primary key (id, follower))",
+		"insert into following(id, following) values ('u', 'a'), ('v', 'a'), ('v', 'b')",
+		"insert into follower(id, follower) values ('a', 'u'), ('a', 'v'), ('b', 'v')",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	service := NewFeedService(db, strategy, "activities", "id", "type", "actor", "item", "object", "content", "time",
+		"feeds", "userid", "activityid", "following", "id", "following", "follower", "id", "follower", nil)
+	return db, service
+}
+
+func send(t *testing.T, service FeedService, id string, event outbox.Event) {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = service.Send(context.Background(), outbox.Message{Id: id, Type: event.EventType(), Payload: payload, Time: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func count(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestFanOutOnWrite(t *testing.T) {
+	db, service := newTestService(t, FanOutOnWrite)
+	now := time.Now()
+	// The message is delivered twice, and fanned out once to the followers of a.
+	for i := 0; i < 2; i++ {
+		send(t, service, "m1", outbox.RateCreated{Id: "i1", Author: "a", Rating: outbox.Rating{Rate: 5}, Time: now})
+	}
+	send(t, service, "m2", outbox.CommentCreated{CommentId: "c1", Id: "i2", Author: "x", UserId: "b", Comment: "nice", Time: now})
+	send(t, service, "m3", outbox.RateCreated{Id: "i3", Author: "a", Rating: outbox.Rating{Rate: 1}, Anonymous: true, Time: now})
+	if n := count(t, db, "select count(*) from activities"); n != 2 {
+		t.Errorf("got %d activities, want 2", n)
+	}
+	tests := []struct {
+		userId string
+		want   int
+	}{
+		{"u", 1},
+		{"v", 2},
+		{"a", 0},
+		{"b", 0},
+	}
+	for _, tt := range tests {
+		if n := count(t, db, "select count(*) from feeds where userid = ?", tt.userId); n != tt.want {
+			t.Errorf("feed of %s has %d rows, want %d", tt.userId, n, tt.want)
+		}
+	}
+	send(t, service, "d1", outbox.CommentDeleted{CommentId: "c1", Id: "i2", Author: "x", Time: now})
+	send(t, service, "d2", outbox.RateDeleted{Id: "i1", Author: "a", Time: now})
+	if n := count(t, db, "select count(*) from feeds"); n != 0 {
+		t.Errorf("got %d feed rows after the deletes, want 0", n)
+	}
+	if n := count(t, db, "select count(*) from activities"); n != 0 {
+		t.Errorf("got %d activities after the deletes, want 0", n)
+	}
+}
+
+func TestFanOutOnRead(t *testing.T) {
+	db, service := newTestService(t, FanOutOnRead)
+	now := time.Now()
+	send(t, service, "m1", outbox.Saved{Id: "a", Item: "i1", Time: now})
+	send(t, service, "m1", outbox.Saved{Id: "a", Item: "i1", Time: now})
+	if n := count(t, db, "select count(*) from feeds"); n != 0 {
+		t.Errorf("got %d feed rows, want none on read", n)
+	}
+	activities, err := service.Load(context.Background(), "v", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(activities.List) != 1 || activities.List[0].Type != Saved || activities.List[0].Item != "i1" {
+		t.Errorf("Load(v) = %+v, want the item i1 saved by a", activities.List)
+	}
+	send(t, service, "d1", outbox.Unsaved{Id: "a", Item: "i1", Time: now})
+	if n := count(t, db, "select count(*) from activities"); n != 0 {
+		t.Errorf("got %d activities after Unsaved, want 0", n)
+	}
+}
@@ -0,0 +1,5 @@
+package feed
+
+import "github.com/core-go/reaction/paging"
+
+type Info = paging.Info
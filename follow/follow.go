@@ -1,5 +1,7 @@
 package follow
 
+import "time"
+
 type Follower struct {
 	Id       string `json:"id,omitempty" gorm:"column:id;primary_key" bson:"id,omitempty" dynamodbav:"id,omitempty" firestore:"id,omitempty" validate:"required,max=255"`
 	Follower string `json:"follower,omitempty" gorm:"column:follower" bson:"follower,omitempty" dynamodbav:"follower,omitempty" firestore:"follower,omitempty" validate:"required,max=10"`
@@ -10,12 +12,58 @@ type Following struct {
 	Following string `json:"following,omitempty" gorm:"column:following" bson:"following,omitempty" dynamodbav:"following,omitempty" firestore:"following,omitempty" validate:"required,max=10"`
 }
 
+// UserInfo is the row of the user info table shared by follow and user-reaction.
 type UserInfo struct {
 	Id             string `json:"id,omitempty" gorm:"column:id;primary_key" bson:"id,omitempty" dynamodbav:"id,omitempty" firestore:"id,omitempty" validate:"required,max=255"`
-	FollowerCount  string `json:"followercount,omitempty" gorm:"column:followercount" bson:"followercount,omitempty" dynamodbav:"followercount,omitempty" firestore:"followercount,omitempty"`
-	FollowingCount string `json:"followingcount,omitempty" gorm:"column:followingcount" bson:"followingcount,omitempty" dynamodbav:"followingcount,omitempty" firestore:"followingcount,omitempty"`
-	ReactionCount  string `json:"reactioncount,omitempty" gorm:"column:reactioncount" bson:"reactioncount,omitempty" dynamodbav:"reactioncount,omitempty" firestore:"reactioncount,omitempty"`
-	Level1Count    string `json:"level1count,omitempty" gorm:"column:level1count" bson:"level1count,omitempty" dynamodbav:"level1count,omitempty" firestore:"level1count,omitempty"`
-	Level2Count    string `json:"level2count,omitempty" gorm:"column:level2count" bson:"level2count,omitempty" dynamodbav:"level2count,omitempty" firestore:"level2count,omitempty"`
-	Level3Count    string `json:"level3count,omitempty" gorm:"column:level3count" bson:"level3count,omitempty" dynamodbav:"level3count,omitempty" firestore:"level3count,omitempty"`
+	FollowerCount  int64  `json:"followercount" gorm:"column:followercount" bson:"followercount" dynamodbav:"followercount" firestore:"followercount"`
+	FollowingCount int64  `json:"followingcount" gorm:"column:followingcount" bson:"followingcount" dynamodbav:"followingcount" firestore:"followingcount"`
+	ReactionCount  int64  `json:"reactioncount" gorm:"column:reactioncount" bson:"reactioncount" dynamodbav:"reactioncount" firestore:"reactioncount"`
+	Level1Count    int64  `json:"level1count" gorm:"column:level1count" bson:"level1count" dynamodbav:"level1count" firestore:"level1count"`
+	Level2Count    int64  `json:"level2count" gorm:"column:level2count" bson:"level2count" dynamodbav:"level2count" firestore:"level2count"`
+	Level3Count    int64  `json:"level3count" gorm:"column:level3count" bson:"level3count" dynamodbav:"level3count" firestore:"level3count"`
+}
+
+type User struct {
+	Id   string     `json:"id,omitempty"`
+	Time *time.Time `json:"time,omitempty"`
+	Name *string    `json:"name,omitempty"`
+	Url  *string    `json:"url,omitempty"`
+}
+
+// MaxLimit is the maximum number of users of a page of Followers, Following and ListPendingRequests.
+const MaxLimit = 100
+
+type Users struct {
+	List  []User `json:"list"`
+	Total int64  `json:"total"`
+	Next  string `json:"next,omitempty"`
+}
+
+// Relationship is the relationship of a user with the user Id.
+type Relationship struct {
+	Id string `json:"id"`
+	// Following is true if the user follows Id.
+	Following bool `json:"following"`
+	// FollowedBy is true if Id follows the user.
+	FollowedBy bool `json:"followedBy"`
+	Mutual     bool `json:"mutual"`
+	// Pending is true if the user requested to follow Id, and Id has not approved it yet.
+	Pending bool `json:"pending"`
+	Blocked bool `json:"blocked"`
+}
+
+type Status string
+
+const (
+	Followed         Status = "followed"
+	Requested        Status = "requested"
+	AlreadyFollowing Status = "already_following"
+	AlreadyRequested Status = "already_requested"
+	Unfollowed       Status = "unfollowed"
+	NotFollowing     Status = "not_following"
+)
+
+type Result struct {
+	Status Status `json:"status"`
+	Count  int64  `json:"count"`
 }
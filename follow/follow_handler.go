@@ -1,9 +1,13 @@
 package follow
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
+
+	"github.com/core-go/reaction/block"
 )
 
 func NewFollowHandler(service FollowService, targetIndex int, idIndex int) FollowHandler {
@@ -23,6 +27,10 @@ func (h *FollowHandler) Follow(w http.ResponseWriter, r *http.Request) {
 	if len(follower.Id) > 0 && len(follower.Follower) > 0 {
 		result, err := h.service.Follow(r.Context(), follower.Id, follower.Follower)
 		if err != nil {
+			if err == block.ErrBlocked {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
 			http.Error(w, err.Error(), 500)
 			return
 		}
@@ -50,6 +58,7 @@ func (h *FollowHandler) UnFollow(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Check writes 1 if the user at idIndex follows the user at targetIndex, or 0.
 func (h *FollowHandler) Check(w http.ResponseWriter, r *http.Request) {
 	target := GetRequiredParam(w, r, h.targetIndex)
 	id := GetRequiredParam(w, r, h.idIndex)
@@ -65,6 +74,227 @@ func (h *FollowHandler) Check(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+func (h *FollowHandler) RequestFollow(w http.ResponseWriter, r *http.Request) {
+	h.exec(w, r, h.service.RequestFollow)
+}
+
+// ApproveFollow approves the request of the user at targetIndex to follow the user at idIndex.
+func (h *FollowHandler) ApproveFollow(w http.ResponseWriter, r *http.Request) {
+	h.exec(w, r, h.service.ApproveFollow)
+}
+
+// RejectFollow rejects the request of the user at targetIndex to follow the user at idIndex.
+func (h *FollowHandler) RejectFollow(w http.ResponseWriter, r *http.Request) {
+	h.exec(w, r, h.service.RejectFollow)
+}
+
+func (h *FollowHandler) CancelRequest(w http.ResponseWriter, r *http.Request) {
+	h.exec(w, r, h.service.CancelRequest)
+}
+
+func (h *FollowHandler) ListPendingRequests(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, h.service.ListPendingRequests)
+}
+
+func (h *FollowHandler) exec(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, id string, target string) (int64, error)) {
+	target := GetRequiredParam(w, r, h.targetIndex)
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) > 0 && len(target) > 0 {
+		result, err := action(r.Context(), id, target)
+		if err != nil {
+			if err == block.ErrBlocked {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func (h *FollowHandler) Followers(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, h.service.Followers)
+}
+
+func (h *FollowHandler) Following(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, h.service.Following)
+}
+
+func (h *FollowHandler) list(w http.ResponseWriter, r *http.Request, load func(ctx context.Context, id string, cursor string, limit int64) (*Users, error)) {
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 {
+		return
+	}
+	limit, ok := getLimit(w, r)
+	if !ok {
+		return
+	}
+	result, err := load(r.Context(), id, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if err == ErrInvalidCursor {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+func (h *FollowHandler) Relationship(w http.ResponseWriter, r *http.Request) {
+	target := GetRequiredParam(w, r, h.targetIndex)
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) > 0 && len(target) > 0 {
+		result, err := h.service.Relationship(r.Context(), id, target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// Relationships returns the relationships of the user at idIndex with the users of the request body.
+func (h *FollowHandler) Relationships(w http.ResponseWriter, r *http.Request) {
+	var targets []string
+	if er1 := Decode(w, r, &targets); er1 != nil {
+		return
+	}
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 {
+		return
+	}
+	result, err := h.service.Relationships(r.Context(), id, targets)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *FollowHandler) MutualFollowers(w http.ResponseWriter, r *http.Request) {
+	target := GetRequiredParam(w, r, h.targetIndex)
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 || len(target) == 0 {
+		return
+	}
+	limit, ok := getLimit(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.service.MutualFollowers(r.Context(), id, target, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+// BatchMutualFollowers returns the mutual followers of the user at idIndex with each of the users of the request body.
+func (h *FollowHandler) BatchMutualFollowers(w http.ResponseWriter, r *http.Request) {
+	var targets []string
+	if er1 := Decode(w, r, &targets); er1 != nil {
+		return
+	}
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 {
+		return
+	}
+	limit, ok := getLimit(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.service.BatchMutualFollowers(r.Context(), id, targets, limit)
+	if err == ErrTooManyTargets {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+// BulkFollow follows the users of the request body by the user at idIndex.
+func (h *FollowHandler) BulkFollow(w http.ResponseWriter, r *http.Request) {
+	var targets []string
+	if er1 := Decode(w, r, &targets); er1 != nil {
+		return
+	}
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 {
+		return
+	}
+	result, err := h.service.BulkFollow(r.Context(), id, targets)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+// ExportGraph streams the follow edges of the user at idIndex, in the format of the query parameter format, csv or ndjson (by default).
+func (h *FollowHandler) ExportGraph(w http.ResponseWriter, r *http.Request) {
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", FormatNDJSON:
+		format = FormatNDJSON
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	case FormatCSV:
+		w.Header().Set("Content-Type", "text/csv")
+	default:
+		http.Error(w, ErrInvalidFormat.Error(), http.StatusBadRequest)
+		return
+	}
+	// The status is sent with the first bytes, so an error after them can only end the response.
+	ew := &exportWriter{writer: w}
+	if err := h.service.ExportGraph(r.Context(), id, format, ew); err != nil && !ew.written {
+		w.Header().Del("Content-Type")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+type exportWriter struct {
+	writer  http.ResponseWriter
+	written bool
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.writer.Write(p)
+}
+
+func getLimit(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	l := r.URL.Query().Get("limit")
+	if len(l) == 0 {
+		return 0, true
+	}
+	limit, err := strconv.ParseInt(l, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return 0, false
+	}
+	return limit, true
+}
 func GetParam(r *http.Request, options ...int) string {
 	offset := 0
 	if len(options) > 0 && options[0] > 0 {
@@ -87,3 +317,19 @@ func GetRequiredParam(w http.ResponseWriter, r *http.Request, options ...int) st
 	}
 	return p
 }
+func Decode(w http.ResponseWriter, r *http.Request, obj interface{}, options ...func(context.Context, interface{}) (interface{}, error)) error {
+	er1 := json.NewDecoder(r.Body).Decode(obj)
+	defer r.Body.Close()
+	if er1 != nil {
+		http.Error(w, er1.Error(), http.StatusBadRequest)
+		return er1
+	}
+	if len(options) > 0 && options[0] != nil {
+		_, er2 := options[0](r.Context(), obj)
+		if er2 != nil {
+			http.Error(w, er2.Error(), http.StatusInternalServerError)
+		}
+		return er2
+	}
+	return nil
+}
@@ -3,15 +3,116 @@ package follow
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
+	"time"
+
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
+	"github.com/core-go/reaction/paging"
 )
 
+var ErrInvalidCursor = paging.ErrInvalidCursor
+
+// MaxTargets is the maximum number of distinct targets of BatchMutualFollowers.
+const MaxTargets = 100
+
+var ErrTooManyTargets = errors.New("too many targets")
+
 type FollowService interface {
-	Follow(ctx context.Context, id string, target string) (int64, error)
-	UnFollow(ctx context.Context, id string, target string) (int64, error)
+	// Follow follows target, or requests to follow target if target requires approval.
+	Follow(ctx context.Context, id string, target string) (*Result, error)
+	// UnFollow removes the follow edges of id to target. It does nothing if id does not follow target.
+	UnFollow(ctx context.Context, id string, target string) (*Result, error)
+	// CheckFollow returns 1 if id follows target, or 0. It reads the row (id, target) of the follower table;
+	// the first versions read the following table, so they returned whether target followed id.
 	CheckFollow(ctx context.Context, id string, target string) (int, error)
+	// Followers returns the users who follow id, the latest first.
+	Followers(ctx context.Context, id string, cursor string, limit int64) (*Users, error)
+	// Following returns the users followed by id, the latest first.
+	Following(ctx context.Context, id string, cursor string, limit int64) (*Users, error)
+	// RequestFollow requests id to follow target. It returns 0 if id already follows target or already requested it.
+	RequestFollow(ctx context.Context, id string, target string) (int64, error)
+	// ApproveFollow approves the request of requester to follow id, unless they blocked each other since the request.
+	ApproveFollow(ctx context.Context, id string, requester string) (int64, error)
+	// RejectFollow rejects the request of requester to follow id.
+	RejectFollow(ctx context.Context, id string, requester string) (int64, error)
+	// CancelRequest cancels the request of id to follow target.
+	CancelRequest(ctx context.Context, id string, target string) (int64, error)
+	// ListPendingRequests returns the users who requested to follow id, the latest first.
+	ListPendingRequests(ctx context.Context, id string, cursor string, limit int64) (*Users, error)
+	// Relationship returns the relationship of id with target.
+	Relationship(ctx context.Context, id string, target string) (*Relationship, error)
+	// Relationships returns the relationships of id with the targets, in the order of the targets.
+	Relationships(ctx context.Context, id string, targets []string) ([]Relationship, error)
+	// MutualFollowers returns the users followed by id who follow target, the latest first.
+	MutualFollowers(ctx context.Context, id string, target string, limit int64) (*Users, error)
+	// BatchMutualFollowers returns the mutual followers of id with each of the targets.
+	// It returns ErrTooManyTargets if there are more than MaxTargets distinct targets.
+	BatchMutualFollowers(ctx context.Context, id string, targets []string, limit int64) (map[string]*Users, error)
+	// Disconnect removes the follow edges and the follow requests between id and target, in both directions.
+	Disconnect(ctx context.Context, id string, target string) (int64, error)
+	// BulkFollow follows the targets in chunks, one transaction per chunk, for account migrations.
+	// The targets which require approval are requested.
+	BulkFollow(ctx context.Context, id string, targets []string) (*BulkResult, error)
+	// ExportGraph writes the following and follower edges of id to w, in the format csv or ndjson.
+	ExportGraph(ctx context.Context, id string, format string, w io.Writer) error
+}
+
+const bulkSize = 500
+
+// Option configures the optional features of the follow service.
+type Option func(*followService)
+
+// WithRequests sets the table of the follow requests, which has the row (target, requester) when requester requests to follow target.
+func WithRequests(table string, idCol string, requestCol string) Option {
+	return func(s *followService) {
+		s.RequestTable = table
+		s.RequestIdCol = idCol
+		s.RequestCol = requestCol
+	}
+}
+
+// WithApproval sets the column of the user info table which is true if the user approves the follow requests. It requires WithRequests.
+func WithApproval(approvalCol string) Option {
+	return func(s *followService) {
+		s.ApprovalCol = approvalCol
+	}
+}
+
+func WithGuard(guard block.Guard) Option {
+	return func(s *followService) {
+		s.Guard = guard
+	}
+}
+
+func WithQueryInfo(queryInfo func(ids []string) ([]Info, error)) Option {
+	return func(s *followService) {
+		s.QueryInfo = queryInfo
+	}
 }
 
+// WithToArray binds the ids of Relationships and of the batch queries as one array parameter on Postgres.
+func WithToArray(toArray func(interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+}) Option {
+	return func(s *followService) {
+		s.ToArray = toArray
+	}
+}
+
+func WithPublishers(publishers ...outbox.Publisher) Option {
+	return func(s *followService) {
+		s.Publishers = append(s.Publishers, publishers...)
+	}
+}
+
+// NewFollowService returns a FollowService. When id follows target, the follower table has the row (id, target),
+// and the following table has the row (target, id), so the following table lists the followers of each user.
 func NewFollowService(
 	db *sql.DB,
 	followerTable string,
@@ -20,12 +121,14 @@ func NewFollowService(
 	followingTable string,
 	followingIdCol string,
 	followingCol string,
+	timeCol string,
 	userInfoTable string,
 	userInfoIdCol string,
 	followerCountCol string,
 	followingCountCol string,
+	options ...Option,
 ) FollowService {
-	return &followService{
+	s := &followService{
 		DB:                db,
 		FollowerTable:     followerTable,
 		FollowerIdCol:     followerIdCol,
@@ -33,11 +136,17 @@ func NewFollowService(
 		FollowingTable:    followingTable,
 		FollowingIdCol:    followingIdCol,
 		FollowingCol:      followingCol,
+		TimeCol:           timeCol,
 		UserInfoTable:     userInfoTable,
 		UserInfoIdCol:     userInfoIdCol,
 		FollowerCountCol:  followerCountCol,
 		FollowingCountCol: followingCountCol,
+		Dialect:           dialect.New(db),
+	}
+	for _, option := range options {
+		option(s)
 	}
+	return s
 }
 
 type followService struct {
@@ -48,14 +157,29 @@ type followService struct {
 	FollowingTable    string
 	FollowingIdCol    string
 	FollowingCol      string
+	TimeCol           string
+	RequestTable      string
+	RequestIdCol      string
+	RequestCol        string
 	UserInfoTable     string
 	UserInfoIdCol     string
 	FollowerCountCol  string
 	FollowingCountCol string
+	// ApprovalCol is the column of UserInfoTable which is true if the user approves the follow requests.
+	// If it is empty, Follow never requests approval.
+	ApprovalCol string
+	Guard       block.Guard
+	QueryInfo   func(ids []string) ([]Info, error)
+	ToArray     func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	}
+	Dialect    dialect.Dialect
+	Publishers []outbox.Publisher
 }
 
 func (s *followService) CheckFollow(ctx context.Context, id string, target string) (int, error) {
-	query := fmt.Sprintf("select %s from %s where %s = $1 and %s = $2", s.FollowingIdCol, s.FollowingTable, s.FollowingIdCol, s.FollowingCol)
+	query := s.Dialect.Rebind(fmt.Sprintf("select %s from %s where %s = ? and %s = ?", s.FollowerIdCol, s.FollowerTable, s.FollowerIdCol, s.FollowerCol))
 	rows, err := s.DB.QueryContext(ctx, query, id, target)
 	if err != nil {
 		return -1, err
@@ -75,94 +199,696 @@ func (s *followService) CheckFollow(ctx context.Context, id string, target strin
 	return len(result), nil
 }
 
-func (s *followService) Follow(ctx context.Context, id string, target string) (int64, error) {
+func (s *followService) Follow(ctx context.Context, id string, target string) (*Result, error) {
+	if err := block.Check(ctx, s.Guard, target, id); err != nil {
+		return nil, err
+	}
 	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 	defer tx.Rollback()
-	query1 := fmt.Sprintf("insert into %s (%s, %s) values ($1, $2);", s.FollowerTable, s.FollowerIdCol, s.FollowerCol)
-	stmt1, er0 := tx.Prepare(query1)
-	if er0 != nil {
-		return -1, nil
+	now := time.Now()
+	approval, err := s.requiresApproval(ctx, tx, target)
+	if err != nil {
+		return nil, err
+	}
+	result := Result{Status: Followed}
+	if approval {
+		// A user who already follows target does not need to request it again.
+		query := s.Dialect.Rebind(fmt.Sprintf("select count(*) from %s where %s = ? and %s = ?", s.FollowerTable, s.FollowerIdCol, s.FollowerCol))
+		var count int64
+		if err = tx.QueryRowContext(ctx, query, id, target).Scan(&count); err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return &Result{Status: AlreadyFollowing}, nil
+		}
+		result.Status = Requested
+		result.Count, err = s.request(ctx, tx, id, target, now)
+	} else {
+		result.Count, err = s.follow(ctx, tx, id, target, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	if result.Count == 0 {
+		if result.Status == Requested {
+			result.Status = AlreadyRequested
+		} else {
+			result.Status = AlreadyFollowing
+		}
 	}
-	stmt1.ExecContext(ctx, id, target)
+	return &result, nil
+}
 
-	query2 := fmt.Sprintf("insert into %s (%s, %s) values ($1, $2);", s.FollowingTable, s.FollowingIdCol, s.FollowingCol)
-	stmt2, er0 := tx.Prepare(query2)
-	if er0 != nil {
-		return -1, nil
+// follow inserts the follow edges of id to target, and increases each counter only if its edge was inserted.
+// It returns 1 if id did not follow target, or 0 if nothing changed.
+func (s *followService) follow(ctx context.Context, tx *sql.Tx, id string, target string, now time.Time) (int64, error) {
+	query1 := s.Dialect.Rebind(s.Dialect.Upsert(s.FollowerTable,
+		[]string{s.FollowerIdCol, s.FollowerCol, s.TimeCol}, []string{"?", "?", "?"}, []string{s.FollowerIdCol, s.FollowerCol}, nil))
+	following, err := exec(ctx, tx, query1, id, target, now)
+	if err != nil {
+		return -1, err
+	}
+	query2 := s.Dialect.Rebind(s.Dialect.Upsert(s.FollowingTable,
+		[]string{s.FollowingIdCol, s.FollowingCol, s.TimeCol}, []string{"?", "?", "?"}, []string{s.FollowingIdCol, s.FollowingCol}, nil))
+	follower, err := exec(ctx, tx, query2, target, id, now)
+	if err != nil {
+		return -1, err
+	}
+	if following > 0 {
+		query3 := s.Dialect.Rebind(s.Dialect.Upsert(s.UserInfoTable,
+			[]string{s.UserInfoIdCol, s.FollowerCountCol, s.FollowingCountCol}, []string{"?", "0", "1"}, []string{s.UserInfoIdCol},
+			[]string{fmt.Sprintf("%s = %s.%s + 1", s.FollowingCountCol, s.UserInfoTable, s.FollowingCountCol)}))
+		if _, err = exec(ctx, tx, query3, id); err != nil {
+			return -1, err
+		}
+	}
+	if follower > 0 {
+		query4 := s.Dialect.Rebind(s.Dialect.Upsert(s.UserInfoTable,
+			[]string{s.UserInfoIdCol, s.FollowerCountCol, s.FollowingCountCol}, []string{"?", "1", "0"}, []string{s.UserInfoIdCol},
+			[]string{fmt.Sprintf("%s = %s.%s + 1", s.FollowerCountCol, s.UserInfoTable, s.FollowerCountCol)}))
+		if _, err = exec(ctx, tx, query4, target); err != nil {
+			return -1, err
+		}
+	}
+	if following == 0 {
+		return 0, nil
+	}
+	if err = outbox.Publish(ctx, tx, s.Publishers, outbox.Followed{Id: id, Target: target, Time: now}); err != nil {
+		return -1, err
 	}
-	stmt2.ExecContext(ctx, target, id)
+	return 1, nil
+}
 
-	query3 := fmt.Sprintf(
-		"insert into %s(%s, %s, %s) values ($1, 0, 1) on conflict (%s) do update set %s = %s.%s + 1;",
-		s.UserInfoTable, s.UserInfoIdCol, s.FollowerCountCol, s.FollowingCountCol, s.UserInfoIdCol, s.FollowingCountCol, s.UserInfoTable, s.FollowingCountCol)
-	stmt3, er0 := tx.Prepare(query3)
-	if er0 != nil {
-		return -1, nil
+func (s *followService) requiresApproval(ctx context.Context, tx *sql.Tx, id string) (bool, error) {
+	if len(s.ApprovalCol) == 0 {
+		return false, nil
+	}
+	query := s.Dialect.Rebind(fmt.Sprintf("select %s from %s where %s = ?", s.ApprovalCol, s.UserInfoTable, s.UserInfoIdCol))
+	var approval sql.NullBool
+	err := tx.QueryRowContext(ctx, query, id).Scan(&approval)
+	if err == sql.ErrNoRows {
+		return false, nil
 	}
-	stmt3.ExecContext(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return approval.Valid && approval.Bool, nil
+}
 
-	query4 := fmt.Sprintf(
-		"insert into %s(%s, %s, %s) values ($1, 1, 0) on conflict (%s) do update set %s = %s.%s + 1;",
-		s.UserInfoTable, s.UserInfoIdCol, s.FollowerCountCol, s.FollowingCountCol, s.UserInfoIdCol, s.FollowerCountCol, s.UserInfoTable, s.FollowerCountCol)
-	stmt4, er0 := tx.Prepare(query4)
-	if er0 != nil {
-		return -1, nil
+func (s *followService) RequestFollow(ctx context.Context, id string, target string) (int64, error) {
+	if err := block.Check(ctx, s.Guard, target, id); err != nil {
+		return -1, err
 	}
-	res4, er4 := stmt4.ExecContext(ctx, target)
-	if er4 != nil {
-		return -1, er4
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+	following, err := s.exist(ctx, tx, s.FollowerTable, s.FollowerIdCol, s.FollowerCol, id, []string{target})
+	if err != nil || following[target] {
+		return 0, err
+	}
+	r, err := s.request(ctx, tx, id, target, time.Now())
+	if err != nil {
+		return -1, err
 	}
-	r, err := res4.RowsAffected()
 	if err = tx.Commit(); err != nil {
 		return -1, err
 	}
+	return r, nil
+}
 
-	return r, err
+func (s *followService) request(ctx context.Context, tx *sql.Tx, id string, target string, now time.Time) (int64, error) {
+	query := s.Dialect.Rebind(s.Dialect.Upsert(s.RequestTable,
+		[]string{s.RequestIdCol, s.RequestCol, s.TimeCol}, []string{"?", "?", "?"}, []string{s.RequestIdCol, s.RequestCol}, nil))
+	res, err := tx.ExecContext(ctx, query, target, id, now)
+	if err != nil {
+		return -1, err
+	}
+	r, err := res.RowsAffected()
+	if err != nil {
+		return -1, err
+	}
+	if r == 0 {
+		return 0, nil
+	}
+	if err = outbox.Publish(ctx, tx, s.Publishers, outbox.FollowRequested{Id: id, Target: target, Time: now}); err != nil {
+		return -1, err
+	}
+	return r, nil
 }
 
-func (s *followService) UnFollow(ctx context.Context, id string, target string) (int64, error) {
+func (s *followService) ApproveFollow(ctx context.Context, id string, requester string) (int64, error) {
+	if err := block.Check(ctx, s.Guard, id, requester); err != nil {
+		return -1, err
+	}
 	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return -1, err
 	}
 	defer tx.Rollback()
-	query1 := fmt.Sprintf("delete from %s where %s = $1 and %s = $2;", s.FollowerTable, s.FollowerIdCol, s.FollowerCol)
-	stmt1, er0 := tx.Prepare(query1)
-	if er0 != nil {
-		return -1, er0
+	r, err := s.removeRequest(ctx, tx, id, requester)
+	if err != nil || r == 0 {
+		return r, err
 	}
-	stmt1.ExecContext(ctx, id, target)
+	r, err = s.follow(ctx, tx, requester, id, time.Now())
+	if err != nil {
+		return -1, err
+	}
+	if err = tx.Commit(); err != nil {
+		return -1, err
+	}
+	return r, nil
+}
 
-	query2 := fmt.Sprintf("delete from %s where %s = $1 and %s = $2;", s.FollowingTable, s.FollowingIdCol, s.FollowingCol)
-	stmt2, er0 := tx.Prepare(query2)
-	if er0 != nil {
-		return -1, er0
+func (s *followService) RejectFollow(ctx context.Context, id string, requester string) (int64, error) {
+	return s.removeRequest(ctx, s.DB, id, requester)
+}
+
+func (s *followService) CancelRequest(ctx context.Context, id string, target string) (int64, error) {
+	return s.removeRequest(ctx, s.DB, target, id)
+}
+
+func (s *followService) ListPendingRequests(ctx context.Context, id string, cursor string, limit int64) (*Users, error) {
+	return s.list(ctx, s.RequestTable, s.RequestIdCol, s.RequestCol, id, cursor, limit)
+}
+
+func (s *followService) removeRequest(ctx context.Context, db executor, id string, requester string) (int64, error) {
+	query := s.Dialect.Rebind(fmt.Sprintf("delete from %s where %s = ? and %s = ?", s.RequestTable, s.RequestIdCol, s.RequestCol))
+	return exec(ctx, db, query, id, requester)
+}
+
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func exec(ctx context.Context, db executor, query string, args ...interface{}) (int64, error) {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return -1, err
 	}
-	stmt2.ExecContext(ctx, target, id)
+	return res.RowsAffected()
+}
 
-	query3 := fmt.Sprintf("update %s set %s = %s - 1 where %s = $1;", s.UserInfoTable, s.FollowingCountCol, s.FollowingCountCol, s.UserInfoIdCol)
-	stmt3, er0 := tx.Prepare(query3)
-	if er0 != nil {
-		return -1, er0
+func (s *followService) UnFollow(ctx context.Context, id string, target string) (*Result, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
 	}
-	stmt3.ExecContext(ctx, id)
+	defer tx.Rollback()
+	r, err := s.unfollow(ctx, tx, id, target)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	if r == 0 {
+		return &Result{Status: NotFollowing}, nil
+	}
+	return &Result{Status: Unfollowed, Count: r}, nil
+}
 
-	query4 := fmt.Sprintf("update %s set %s = %s - 1 where %s = $1;", s.UserInfoTable, s.FollowerCountCol, s.FollowerCountCol, s.UserInfoIdCol)
-	stmt4, er0 := tx.Prepare(query4)
-	if er0 != nil {
-		return -1, er0
+// unfollow deletes the follow edges of id to target, and decreases each counter only if its edge was deleted.
+// It returns 1 if id followed target, or 0 if it did not.
+func (s *followService) unfollow(ctx context.Context, tx *sql.Tx, id string, target string) (int64, error) {
+	query1 := s.Dialect.Rebind(fmt.Sprintf("delete from %s where %s = ? and %s = ?", s.FollowerTable, s.FollowerIdCol, s.FollowerCol))
+	following, err := exec(ctx, tx, query1, id, target)
+	if err != nil {
+		return -1, err
 	}
-	res, err := stmt4.ExecContext(ctx, target)
+	query2 := s.Dialect.Rebind(fmt.Sprintf("delete from %s where %s = ? and %s = ?", s.FollowingTable, s.FollowingIdCol, s.FollowingCol))
+	follower, err := exec(ctx, tx, query2, target, id)
 	if err != nil {
 		return -1, err
 	}
+	if following > 0 {
+		query3 := s.Dialect.Rebind(fmt.Sprintf("update %s set %s = %s - 1 where %s = ? and %s > 0", s.UserInfoTable, s.FollowingCountCol, s.FollowingCountCol, s.UserInfoIdCol, s.FollowingCountCol))
+		if _, err = exec(ctx, tx, query3, id); err != nil {
+			return -1, err
+		}
+	}
+	if follower > 0 {
+		query4 := s.Dialect.Rebind(fmt.Sprintf("update %s set %s = %s - 1 where %s = ? and %s > 0", s.UserInfoTable, s.FollowerCountCol, s.FollowerCountCol, s.UserInfoIdCol, s.FollowerCountCol))
+		if _, err = exec(ctx, tx, query4, target); err != nil {
+			return -1, err
+		}
+	}
+	if following == 0 {
+		return 0, nil
+	}
+	if err = outbox.Publish(ctx, tx, s.Publishers, outbox.Unfollowed{Id: id, Target: target, Time: time.Now()}); err != nil {
+		return -1, err
+	}
+	return 1, nil
+}
 
-	r, err := res.RowsAffected()
+func (s *followService) Disconnect(ctx context.Context, id string, target string) (int64, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+	var count int64
+	for _, users := range [][2]string{{id, target}, {target, id}} {
+		r, err := s.unfollow(ctx, tx, users[0], users[1])
+		if err != nil {
+			return -1, err
+		}
+		count += r
+		if len(s.RequestTable) > 0 {
+			if _, err = s.removeRequest(ctx, tx, users[1], users[0]); err != nil {
+				return -1, err
+			}
+		}
+	}
 	if err = tx.Commit(); err != nil {
 		return -1, err
 	}
-	return r, err
+	return count, nil
+}
+
+func (s *followService) Followers(ctx context.Context, id string, cursor string, limit int64) (*Users, error) {
+	return s.list(ctx, s.FollowingTable, s.FollowingIdCol, s.FollowingCol, id, cursor, limit)
+}
+
+func (s *followService) Following(ctx context.Context, id string, cursor string, limit int64) (*Users, error) {
+	return s.list(ctx, s.FollowerTable, s.FollowerIdCol, s.FollowerCol, id, cursor, limit)
+}
+
+func (s *followService) list(ctx context.Context, table string, idCol string, userCol string, id string, cursor string, limit int64) (*Users, error) {
+	if limit <= 0 {
+		limit = 20
+	} else if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	where := fmt.Sprintf("%s = ?", idCol)
+	params := []interface{}{id}
+	result := &Users{List: make([]User, 0)}
+	query1 := s.Dialect.Rebind(fmt.Sprintf("select count(*) from %s where %s", table, where))
+	err := s.DB.QueryRowContext(ctx, query1, params...).Scan(&result.Total)
+	if err != nil {
+		return nil, err
+	}
+	if len(cursor) > 0 {
+		t, userId, err := paging.DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, t, t, userId)
+		where += fmt.Sprintf(" and (%s < ? or (%s = ? and %s < ?))", s.TimeCol, s.TimeCol, userCol)
+	}
+	query2 := s.Dialect.Rebind(fmt.Sprintf("select %s, %s from %s where %s order by %s desc, %s desc %s",
+		userCol, s.TimeCol, table, where, s.TimeCol, userCol, s.Dialect.Limit(limit+1)))
+	rows, err := s.DB.QueryContext(ctx, query2, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var user User
+		err = rows.Scan(&user.Id, &user.Time)
+		if err != nil {
+			return nil, err
+		}
+		result.List = append(result.List, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if int64(len(result.List)) > limit {
+		result.List = result.List[:limit]
+		last := result.List[limit-1]
+		if last.Time != nil {
+			result.Next = paging.EncodeCursor(*last.Time, last.Id)
+		}
+	}
+	if err = s.enrich(result.List); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// enrich sets the names and the urls of the users of the lists, with one query.
+func (s *followService) enrich(lists ...[]User) error {
+	if s.QueryInfo == nil {
+		return nil
+	}
+	ids := make([]string, 0)
+	for _, users := range lists {
+		for _, u := range users {
+			ids = append(ids, u.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	infos, err := s.QueryInfo(ids)
+	if err != nil {
+		return err
+	}
+	for _, users := range lists {
+		for k := range users {
+			i := paging.BinarySearch(infos, users[k].Id)
+			if i >= 0 && infos[i].Id == users[k].Id {
+				users[k].Url = &infos[i].Url
+				users[k].Name = &infos[i].Name
+			}
+		}
+	}
+	return nil
+}
+
+func (s *followService) Relationship(ctx context.Context, id string, target string) (*Relationship, error) {
+	relationships, err := s.Relationships(ctx, id, []string{target})
+	if err != nil {
+		return nil, err
+	}
+	return &relationships[0], nil
+}
+
+func (s *followService) Relationships(ctx context.Context, id string, targets []string) ([]Relationship, error) {
+	relationships := make([]Relationship, len(targets))
+	if len(targets) == 0 {
+		return relationships, nil
+	}
+	ids := paging.Distinct(append([]string{}, targets...))
+	following, err := s.exist(ctx, s.DB, s.FollowerTable, s.FollowerIdCol, s.FollowerCol, id, ids)
+	if err != nil {
+		return nil, err
+	}
+	followedBy, err := s.exist(ctx, s.DB, s.FollowingTable, s.FollowingIdCol, s.FollowingCol, id, ids)
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[string]bool)
+	if len(s.RequestTable) > 0 {
+		pending, err = s.exist(ctx, s.DB, s.RequestTable, s.RequestCol, s.RequestIdCol, id, ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	blocked, err := block.Blocked(ctx, s.Guard, id, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i, target := range targets {
+		relationships[i] = Relationship{
+			Id:         target,
+			Following:  following[target],
+			FollowedBy: followedBy[target],
+			Mutual:     following[target] && followedBy[target],
+			Pending:    pending[target],
+			Blocked:    blocked[target],
+		}
+	}
+	return relationships, nil
+}
+
+// exist returns the values of col in table, of the rows where idCol is id and col is one of values.
+func (s *followService) exist(ctx context.Context, db querier, table string, idCol string, col string, id string, values []string) (map[string]bool, error) {
+	in, params := s.Dialect.InArray(col, values, s.ToArray)
+	query := s.Dialect.Rebind(fmt.Sprintf("select %s from %s where %s = ? and %s", col, table, idCol, in))
+	rows, err := db.QueryContext(ctx, query, append([]interface{}{id}, params...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[string]bool)
+	for rows.Next() {
+		var value string
+		if err = rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		result[value] = true
+	}
+	return result, rows.Err()
+}
+
+func (s *followService) MutualFollowers(ctx context.Context, id string, target string, limit int64) (*Users, error) {
+	result, err := s.BatchMutualFollowers(ctx, id, []string{target}, limit)
+	if err != nil {
+		return nil, err
+	}
+	return result[target], nil
+}
+
+func (s *followService) BatchMutualFollowers(ctx context.Context, id string, targets []string, limit int64) (map[string]*Users, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	distinct := paging.Distinct(append([]string{}, targets...))
+	if len(distinct) > MaxTargets {
+		return nil, ErrTooManyTargets
+	}
+	result := make(map[string]*Users)
+	for _, target := range targets {
+		result[target] = &Users{List: make([]User, 0)}
+	}
+	if len(targets) == 0 {
+		return result, nil
+	}
+	// The mutual followers of all the targets are loaded by one query, which numbers them per target, the latest first,
+	// and keeps at most limit of them per target, with their count.
+	in, params := s.Dialect.InArray("r."+s.FollowingIdCol, distinct, s.ToArray)
+	query := s.Dialect.Rebind(fmt.Sprintf(`select m.mutualtarget, m.mutual, m.mutualtime, m.mutualcount from (
+select r.%s as mutualtarget, r.%s as mutual, r.%s as mutualtime, count(*) over (partition by r.%s) as mutualcount,
+row_number() over (partition by r.%s order by r.%s desc, r.%s desc) as mutualorder
+from %s r inner join %s g on g.%s = r.%s where g.%s = ? and %s) m where m.mutualorder <= ? order by m.mutualtarget, m.mutualorder`,
+		s.FollowingIdCol, s.FollowingCol, s.TimeCol, s.FollowingIdCol,
+		s.FollowingIdCol, s.TimeCol, s.FollowingCol,
+		s.FollowingTable, s.FollowerTable, s.FollowerCol, s.FollowingCol, s.FollowerIdCol, in))
+	args := append([]interface{}{id}, params...)
+	rows, err := s.DB.QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var target string
+		var user User
+		var total int64
+		if err = rows.Scan(&target, &user.Id, &user.Time, &total); err != nil {
+			return nil, err
+		}
+		if users, ok := result[target]; ok {
+			users.Total = total
+			users.List = append(users.List, user)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	lists := make([][]User, 0, len(result))
+	for _, users := range result {
+		lists = append(lists, users.List)
+	}
+	if err = s.enrich(lists...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+
+func (s *followService) BulkFollow(ctx context.Context, id string, targets []string) (*BulkResult, error) {
+	result := &BulkResult{}
+	seen := make(map[string]bool)
+	candidates := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if len(target) == 0 || target == id {
+			result.Skipped = append(result.Skipped, target)
+		} else if seen[target] {
+			result.Duplicates = append(result.Duplicates, target)
+		} else {
+			seen[target] = true
+			candidates = append(candidates, target)
+		}
+	}
+	for i := 0; i < len(candidates); i += bulkSize {
+		end := i + bulkSize
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+		if err := s.bulkFollow(ctx, id, candidates[i:end], result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (s *followService) bulkFollow(ctx context.Context, id string, chunk []string, result *BulkResult) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// The follow edges and the approvals of the targets are read in the transaction which follows them.
+	// The guard reads the blocks outside of it, so a target blocked during the chunk can still be followed, as with Follow.
+	ids := paging.Distinct(append([]string{}, chunk...))
+	blocked, err := block.Blocked(ctx, s.Guard, id, ids)
+	if err != nil {
+		return err
+	}
+	following, err := s.exist(ctx, tx, s.FollowerTable, s.FollowerIdCol, s.FollowerCol, id, ids)
+	if err != nil {
+		return err
+	}
+	approvals, err := s.approvals(ctx, tx, ids)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	query1 := s.Dialect.Rebind(s.Dialect.Upsert(s.FollowerTable,
+		[]string{s.FollowerIdCol, s.FollowerCol, s.TimeCol}, []string{"?", "?", "?"}, []string{s.FollowerIdCol, s.FollowerCol}, nil))
+	query2 := s.Dialect.Rebind(s.Dialect.Upsert(s.FollowingTable,
+		[]string{s.FollowingIdCol, s.FollowingCol, s.TimeCol}, []string{"?", "?", "?"}, []string{s.FollowingIdCol, s.FollowingCol}, nil))
+	var requested, duplicates, blockedIds, followers []string
+	var count int64
+	for _, target := range chunk {
+		if blocked[target] {
+			blockedIds = append(blockedIds, target)
+			continue
+		}
+		if following[target] {
+			duplicates = append(duplicates, target)
+			continue
+		}
+		if approvals[target] {
+			r, err := s.request(ctx, tx, id, target, now)
+			if err != nil {
+				return err
+			}
+			if r > 0 {
+				requested = append(requested, target)
+			} else {
+				duplicates = append(duplicates, target)
+			}
+			continue
+		}
+		r, err := exec(ctx, tx, query1, id, target, now)
+		if err != nil {
+			return err
+		}
+		if r == 0 {
+			duplicates = append(duplicates, target)
+			continue
+		}
+		count++
+		r, err = exec(ctx, tx, query2, target, id, now)
+		if err != nil {
+			return err
+		}
+		if r > 0 {
+			followers = append(followers, target)
+		}
+		if err = outbox.Publish(ctx, tx, s.Publishers, outbox.Followed{Id: id, Target: target, Time: now}); err != nil {
+			return err
+		}
+	}
+	if count > 0 {
+		query3 := s.Dialect.Rebind(s.Dialect.Upsert(s.UserInfoTable,
+			[]string{s.UserInfoIdCol, s.FollowerCountCol, s.FollowingCountCol}, []string{"?", "0", "?"}, []string{s.UserInfoIdCol},
+			[]string{fmt.Sprintf("%s = %s.%s + ?", s.FollowingCountCol, s.UserInfoTable, s.FollowingCountCol)}))
+		if _, err = exec(ctx, tx, query3, id, count, count); err != nil {
+			return err
+		}
+	}
+	if err = s.increaseFollowers(ctx, tx, followers); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	result.Followed += count
+	result.Requested = append(result.Requested, requested...)
+	result.Duplicates = append(result.Duplicates, duplicates...)
+	result.Blocked = append(result.Blocked, blockedIds...)
+	return nil
+}
+
+// increaseFollowers increases the follower counters of the distinct users ids with one upsert, which inserts the counters of the users who have none.
+func (s *followService) increaseFollowers(ctx context.Context, tx *sql.Tx, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	rows := make([][]string, len(ids))
+	params := make([]interface{}, len(ids))
+	for i, userId := range ids {
+		rows[i] = []string{"?", "1", "0"}
+		params[i] = userId
+	}
+	query := s.Dialect.Rebind(s.Dialect.UpsertRows(s.UserInfoTable,
+		[]string{s.UserInfoIdCol, s.FollowerCountCol, s.FollowingCountCol}, rows, []string{s.UserInfoIdCol},
+		[]string{fmt.Sprintf("%s = %s.%s + 1", s.FollowerCountCol, s.UserInfoTable, s.FollowerCountCol)}))
+	_, err := exec(ctx, tx, query, params...)
+	return err
+}
+
+// approvals returns the users of ids who approve their followers.
+func (s *followService) approvals(ctx context.Context, db querier, ids []string) (map[string]bool, error) {
+	approvals := make(map[string]bool)
+	if len(s.ApprovalCol) == 0 {
+		return approvals, nil
+	}
+	in, params := s.Dialect.InArray(s.UserInfoIdCol, ids, s.ToArray)
+	query := s.Dialect.Rebind(fmt.Sprintf("select %s, %s from %s where %s", s.UserInfoIdCol, s.ApprovalCol, s.UserInfoTable, in))
+	rows, err := db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userId string
+		var approval sql.NullBool
+		if err = rows.Scan(&userId, &approval); err != nil {
+			return nil, err
+		}
+		if approval.Valid && approval.Bool {
+			approvals[userId] = true
+		}
+	}
+	return approvals, rows.Err()
+}
+
+func (s *followService) ExportGraph(ctx context.Context, id string, format string, w io.Writer) error {
+	writer, err := NewEdgeWriter(w, format)
+	if err != nil {
+		return err
+	}
+	if err = s.export(ctx, writer, DirectionFollowing, s.FollowerTable, s.FollowerIdCol, s.FollowerCol, id); err != nil {
+		return err
+	}
+	if err = s.export(ctx, writer, DirectionFollower, s.FollowingTable, s.FollowingIdCol, s.FollowingCol, id); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func (s *followService) export(ctx context.Context, writer EdgeWriter, direction string, table string, idCol string, userCol string, id string) error {
+	query := s.Dialect.Rebind(fmt.Sprintf("select %s, %s from %s where %s = ? order by %s, %s", userCol, s.TimeCol, table, idCol, s.TimeCol, userCol))
+	rows, err := s.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		edge := Edge{Direction: direction}
+		if err = rows.Scan(&edge.UserId, &edge.Time); err != nil {
+			return err
+		}
+		if err = writer.Write(edge); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
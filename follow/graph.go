@@ -0,0 +1,85 @@
+package follow
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+
+	DirectionFollowing = "following"
+	DirectionFollower  = "follower"
+)
+
+var ErrInvalidFormat = errors.New("invalid format")
+
+// Edge is a follow edge of the exported user: the user follows UserId if Direction is following, or UserId follows the user if it is follower.
+type Edge struct {
+	Direction string     `json:"direction"`
+	UserId    string     `json:"userId"`
+	Time      *time.Time `json:"time,omitempty"`
+}
+
+// BulkResult is the result of BulkFollow. Skipped are the empty targets and the user itself.
+type BulkResult struct {
+	Followed   int64    `json:"followed"`
+	Requested  []string `json:"requested,omitempty"`
+	Skipped    []string `json:"skipped,omitempty"`
+	Duplicates []string `json:"duplicates,omitempty"`
+	Blocked    []string `json:"blocked,omitempty"`
+}
+
+type EdgeWriter interface {
+	Write(edge Edge) error
+	Flush() error
+}
+
+// NewEdgeWriter returns the writer of the edges to w in the format, csv or ndjson.
+func NewEdgeWriter(w io.Writer, format string) (EdgeWriter, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"direction", "userId", "time"}); err != nil {
+			return nil, err
+		}
+		return &csvWriter{writer: cw}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, ErrInvalidFormat
+	}
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvWriter) Write(edge Edge) error {
+	t := ""
+	if edge.Time != nil {
+		t = edge.Time.Format(time.RFC3339Nano)
+	}
+	return w.writer.Write([]string{edge.Direction, edge.UserId, t})
+}
+
+func (w *csvWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(edge Edge) error {
+	return w.encoder.Encode(edge)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return nil
+}
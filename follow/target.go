@@ -0,0 +1,32 @@
+package follow
+
+import (
+	"errors"
+	"time"
+)
+
+// TypeUser is the type of the targets which are users. If a TargetService has a FollowService, it follows the users with it.
+const TypeUser = "user"
+
+var ErrInvalidType = errors.New("invalid type")
+
+// Counter is the table of the follower counts of the targets of a type.
+type Counter struct {
+	Table            string
+	IdCol            string
+	FollowerCountCol string
+}
+
+type Target struct {
+	Id   string     `json:"id,omitempty"`
+	Type string     `json:"type,omitempty"`
+	Time *time.Time `json:"time,omitempty"`
+	Name *string    `json:"name,omitempty"`
+	Url  *string    `json:"url,omitempty"`
+}
+
+type Targets struct {
+	List  []Target `json:"list"`
+	Total int64    `json:"total"`
+	Next  string   `json:"next,omitempty"`
+}
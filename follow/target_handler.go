@@ -0,0 +1,138 @@
+package follow
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/core-go/reaction/block"
+)
+
+// NewTargetHandler returns the handler of the TargetService. The type of the targets is the query parameter type.
+func NewTargetHandler(service TargetService, targetIndex int, idIndex int) TargetHandler {
+	return TargetHandler{service: service, targetIndex: targetIndex, idIndex: idIndex}
+}
+
+type TargetHandler struct {
+	service     TargetService
+	targetIndex int
+	idIndex     int
+}
+
+func (h *TargetHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	kind, target, id, ok := h.params(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.service.FollowTarget(r.Context(), id, kind, target)
+	if err != nil {
+		handleTargetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *TargetHandler) UnFollow(w http.ResponseWriter, r *http.Request) {
+	kind, target, id, ok := h.params(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.service.UnFollowTarget(r.Context(), id, kind, target)
+	if err != nil {
+		handleTargetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *TargetHandler) Check(w http.ResponseWriter, r *http.Request) {
+	kind, target, id, ok := h.params(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.service.IsFollowing(r.Context(), id, kind, target)
+	if err != nil {
+		handleTargetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+// Following returns the targets of the type followed by the user at idIndex.
+func (h *TargetHandler) Following(w http.ResponseWriter, r *http.Request) {
+	kind := getType(w, r)
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(kind) == 0 || len(id) == 0 {
+		return
+	}
+	limit, ok := getLimit(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.service.FollowingTargets(r.Context(), id, kind, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		handleTargetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+// Followers returns the followers of the target of the type at idIndex.
+func (h *TargetHandler) Followers(w http.ResponseWriter, r *http.Request) {
+	kind := getType(w, r)
+	target := GetRequiredParam(w, r, h.idIndex)
+	if len(kind) == 0 || len(target) == 0 {
+		return
+	}
+	limit, ok := getLimit(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.service.TargetFollowers(r.Context(), kind, target, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		handleTargetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *TargetHandler) params(w http.ResponseWriter, r *http.Request) (string, string, string, bool) {
+	kind := getType(w, r)
+	if len(kind) == 0 {
+		return "", "", "", false
+	}
+	target := GetRequiredParam(w, r, h.targetIndex)
+	if len(target) == 0 {
+		return "", "", "", false
+	}
+	id := GetRequiredParam(w, r, h.idIndex)
+	return kind, target, id, len(id) > 0
+}
+
+func getType(w http.ResponseWriter, r *http.Request) string {
+	kind := r.URL.Query().Get("type")
+	if len(kind) == 0 {
+		http.Error(w, "type is required", http.StatusBadRequest)
+	}
+	return kind
+}
+
+func handleTargetError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrInvalidType, ErrInvalidCursor:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case block.ErrBlocked:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,275 @@
+package follow
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
+	"github.com/core-go/reaction/paging"
+)
+
+// TargetService follows the targets of the types of the counters, such as topics, places or items.
+// The users are followed with the FollowService, if any, so that they can approve the follow requests and block their followers.
+type TargetService interface {
+	FollowTarget(ctx context.Context, id string, kind string, target string) (*Result, error)
+	UnFollowTarget(ctx context.Context, id string, kind string, target string) (*Result, error)
+	IsFollowing(ctx context.Context, id string, kind string, target string) (bool, error)
+	// FollowingTargets returns the targets of the type kind followed by id, the latest first.
+	FollowingTargets(ctx context.Context, id string, kind string, cursor string, limit int64) (*Targets, error)
+	// TargetFollowers returns the users who follow the target of the type kind, the latest first.
+	TargetFollowers(ctx context.Context, kind string, target string, cursor string, limit int64) (*Users, error)
+}
+
+func NewTargetService(
+	db *sql.DB,
+	targetTable string,
+	idCol string,
+	typeCol string,
+	targetCol string,
+	timeCol string,
+	counters map[string]Counter,
+	users FollowService,
+	queryInfo func(ids []string) ([]Info, error),
+	publishers ...outbox.Publisher,
+) TargetService {
+	return &targetService{
+		DB:          db,
+		TargetTable: targetTable,
+		IdCol:       idCol,
+		TypeCol:     typeCol,
+		TargetCol:   targetCol,
+		TimeCol:     timeCol,
+		Counters:    counters,
+		Users:       users,
+		QueryInfo:   queryInfo,
+		Dialect:     dialect.New(db),
+		Publishers:  publishers,
+	}
+}
+
+type targetService struct {
+	DB *sql.DB
+	// TargetTable has the follow edges of all the types, with the key (IdCol, TypeCol, TargetCol).
+	// TargetFollowers needs an index on (TypeCol, TargetCol).
+	TargetTable string
+	IdCol       string
+	TypeCol     string
+	TargetCol   string
+	TimeCol     string
+	// Counters are the follower counters of the types. A type without a counter cannot be followed.
+	// The counter of a type may have no table, if the targets of the type have no follower count.
+	Counters   map[string]Counter
+	Users      FollowService
+	QueryInfo  func(ids []string) ([]Info, error)
+	Dialect    dialect.Dialect
+	Publishers []outbox.Publisher
+}
+
+func (s *targetService) counter(kind string) (Counter, error) {
+	counter, ok := s.Counters[kind]
+	if !ok || len(kind) == 0 {
+		return counter, ErrInvalidType
+	}
+	return counter, nil
+}
+
+func (s *targetService) FollowTarget(ctx context.Context, id string, kind string, target string) (*Result, error) {
+	if kind == TypeUser && s.Users != nil {
+		return s.Users.Follow(ctx, id, target)
+	}
+	counter, err := s.counter(kind)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	query1 := s.Dialect.Rebind(s.Dialect.Upsert(s.TargetTable,
+		[]string{s.IdCol, s.TypeCol, s.TargetCol, s.TimeCol}, []string{"?", "?", "?", "?"}, []string{s.IdCol, s.TypeCol, s.TargetCol}, nil))
+	r, err := exec(ctx, tx, query1, id, kind, target, now)
+	if err != nil {
+		return nil, err
+	}
+	if r == 0 {
+		return &Result{Status: AlreadyFollowing}, nil
+	}
+	if len(counter.Table) > 0 {
+		query2 := s.Dialect.Rebind(s.Dialect.Upsert(counter.Table,
+			[]string{counter.IdCol, counter.FollowerCountCol}, []string{"?", "1"}, []string{counter.IdCol},
+			[]string{fmt.Sprintf("%s = %s.%s + 1", counter.FollowerCountCol, counter.Table, counter.FollowerCountCol)}))
+		if _, err = exec(ctx, tx, query2, target); err != nil {
+			return nil, err
+		}
+	}
+	if err = outbox.Publish(ctx, tx, s.Publishers, outbox.TargetFollowed{Id: id, Type: kind, Target: target, Time: now}); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &Result{Status: Followed, Count: 1}, nil
+}
+
+func (s *targetService) UnFollowTarget(ctx context.Context, id string, kind string, target string) (*Result, error) {
+	if kind == TypeUser && s.Users != nil {
+		return s.Users.UnFollow(ctx, id, target)
+	}
+	counter, err := s.counter(kind)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	query1 := s.Dialect.Rebind(fmt.Sprintf("delete from %s where %s = ? and %s = ? and %s = ?", s.TargetTable, s.IdCol, s.TypeCol, s.TargetCol))
+	r, err := exec(ctx, tx, query1, id, kind, target)
+	if err != nil {
+		return nil, err
+	}
+	if r == 0 {
+		return &Result{Status: NotFollowing}, nil
+	}
+	if len(counter.Table) > 0 {
+		query2 := s.Dialect.Rebind(fmt.Sprintf("update %s set %s = %s - 1 where %s = ? and %s > 0",
+			counter.Table, counter.FollowerCountCol, counter.FollowerCountCol, counter.IdCol, counter.FollowerCountCol))
+		if _, err = exec(ctx, tx, query2, target); err != nil {
+			return nil, err
+		}
+	}
+	if err = outbox.Publish(ctx, tx, s.Publishers, outbox.TargetUnfollowed{Id: id, Type: kind, Target: target, Time: time.Now()}); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &Result{Status: Unfollowed, Count: 1}, nil
+}
+
+func (s *targetService) IsFollowing(ctx context.Context, id string, kind string, target string) (bool, error) {
+	if kind == TypeUser && s.Users != nil {
+		r, err := s.Users.CheckFollow(ctx, id, target)
+		return r > 0, err
+	}
+	if _, err := s.counter(kind); err != nil {
+		return false, err
+	}
+	query := s.Dialect.Rebind(fmt.Sprintf("select count(*) from %s where %s = ? and %s = ? and %s = ?", s.TargetTable, s.IdCol, s.TypeCol, s.TargetCol))
+	var count int64
+	if err := s.DB.QueryRowContext(ctx, query, id, kind, target).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *targetService) FollowingTargets(ctx context.Context, id string, kind string, cursor string, limit int64) (*Targets, error) {
+	if kind == TypeUser && s.Users != nil {
+		users, err := s.Users.Following(ctx, id, cursor, limit)
+		if err != nil {
+			return nil, err
+		}
+		result := &Targets{List: make([]Target, 0, len(users.List)), Total: users.Total, Next: users.Next}
+		for _, u := range users.List {
+			result.List = append(result.List, Target{Id: u.Id, Type: kind, Time: u.Time, Name: u.Name, Url: u.Url})
+		}
+		return result, nil
+	}
+	if _, err := s.counter(kind); err != nil {
+		return nil, err
+	}
+	users, err := s.list(ctx, s.IdCol, s.TargetCol, id, kind, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	result := &Targets{List: make([]Target, 0, len(users.List)), Total: users.Total, Next: users.Next}
+	for _, u := range users.List {
+		result.List = append(result.List, Target{Id: u.Id, Type: kind, Time: u.Time})
+	}
+	return result, nil
+}
+
+func (s *targetService) TargetFollowers(ctx context.Context, kind string, target string, cursor string, limit int64) (*Users, error) {
+	if kind == TypeUser && s.Users != nil {
+		return s.Users.Followers(ctx, target, cursor, limit)
+	}
+	if _, err := s.counter(kind); err != nil {
+		return nil, err
+	}
+	users, err := s.list(ctx, s.TargetCol, s.IdCol, target, kind, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	if s.QueryInfo != nil && len(users.List) > 0 {
+		ids := make([]string, 0, len(users.List))
+		for _, u := range users.List {
+			ids = append(ids, u.Id)
+		}
+		infos, err := s.QueryInfo(ids)
+		if err != nil {
+			return nil, err
+		}
+		for k := range users.List {
+			i := paging.BinarySearch(infos, users.List[k].Id)
+			if i >= 0 && infos[i].Id == users.List[k].Id {
+				users.List[k].Url = &infos[i].Url
+				users.List[k].Name = &infos[i].Name
+			}
+		}
+	}
+	return users, nil
+}
+
+// list returns the values of col in the edges of the type kind where keyCol is key, the latest first.
+func (s *targetService) list(ctx context.Context, keyCol string, col string, key string, kind string, cursor string, limit int64) (*Users, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	where := fmt.Sprintf("%s = ? and %s = ?", keyCol, s.TypeCol)
+	params := []interface{}{key, kind}
+	result := &Users{List: make([]User, 0)}
+	query1 := s.Dialect.Rebind(fmt.Sprintf("select count(*) from %s where %s", s.TargetTable, where))
+	err := s.DB.QueryRowContext(ctx, query1, params...).Scan(&result.Total)
+	if err != nil {
+		return nil, err
+	}
+	if len(cursor) > 0 {
+		t, last, err := paging.DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, t, t, last)
+		where += fmt.Sprintf(" and (%s < ? or (%s = ? and %s < ?))", s.TimeCol, s.TimeCol, col)
+	}
+	query2 := s.Dialect.Rebind(fmt.Sprintf("select %s, %s from %s where %s order by %s desc, %s desc %s",
+		col, s.TimeCol, s.TargetTable, where, s.TimeCol, col, s.Dialect.Limit(limit+1)))
+	rows, err := s.DB.QueryContext(ctx, query2, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var user User
+		if err = rows.Scan(&user.Id, &user.Time); err != nil {
+			return nil, err
+		}
+		result.List = append(result.List, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if int64(len(result.List)) > limit {
+		result.List = result.List[:limit]
+		last := result.List[limit-1]
+		if last.Time != nil {
+			result.Next = paging.EncodeCursor(*last.Time, last.Id)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package follow
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func NewUserInfoHandler(service UserInfoService, idIndex int) UserInfoHandler {
+	return UserInfoHandler{service: service, idIndex: idIndex}
+}
+
+type UserInfoHandler struct {
+	service UserInfoService
+	idIndex int
+}
+
+// Load returns the counters of the user at idIndex, for the profile header.
+func (h *UserInfoHandler) Load(w http.ResponseWriter, r *http.Request) {
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 {
+		return
+	}
+	result, err := h.service.Load(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+// LoadMany returns the counters of the users of the body, which is an array of ids.
+func (h *UserInfoHandler) LoadMany(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	if err := Decode(w, r, &ids); err != nil {
+		return
+	}
+	result, err := h.service.LoadMany(r.Context(), ids)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,121 @@
+package follow
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/paging"
+)
+
+// UserInfoService reads the counters of the user info table. A user without a row has zero counters.
+type UserInfoService interface {
+	Load(ctx context.Context, id string) (*UserInfo, error)
+	// LoadMany returns the user infos in the order of the ids.
+	LoadMany(ctx context.Context, ids []string) ([]UserInfo, error)
+}
+
+// NewUserInfoService returns a UserInfoService. The level columns are prefix + level + suffix, as in user-reaction.
+// A counter with an empty column is always zero.
+func NewUserInfoService(
+	db *sql.DB,
+	userInfoTable string,
+	idCol string,
+	followerCountCol string,
+	followingCountCol string,
+	reactionCountCol string,
+	prefix string,
+	suffix string,
+	toArray func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	},
+) UserInfoService {
+	return &userInfoService{
+		DB:                db,
+		UserInfoTable:     userInfoTable,
+		IdCol:             idCol,
+		FollowerCountCol:  followerCountCol,
+		FollowingCountCol: followingCountCol,
+		ReactionCountCol:  reactionCountCol,
+		Prefix:            prefix,
+		Suffix:            suffix,
+		ToArray:           toArray,
+		Dialect:           dialect.New(db),
+	}
+}
+
+type userInfoService struct {
+	DB                *sql.DB
+	UserInfoTable     string
+	IdCol             string
+	FollowerCountCol  string
+	FollowingCountCol string
+	ReactionCountCol  string
+	Prefix            string
+	Suffix            string
+	ToArray           func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	}
+	Dialect dialect.Dialect
+}
+
+func (s *userInfoService) Load(ctx context.Context, id string) (*UserInfo, error) {
+	infos, err := s.LoadMany(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	return &infos[0], nil
+}
+
+func (s *userInfoService) LoadMany(ctx context.Context, ids []string) ([]UserInfo, error) {
+	result := make([]UserInfo, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	cols := []string{s.FollowerCountCol, s.FollowingCountCol, s.ReactionCountCol}
+	if len(s.Prefix) > 0 || len(s.Suffix) > 0 {
+		cols = append(cols, s.Prefix+"1"+s.Suffix, s.Prefix+"2"+s.Suffix, s.Prefix+"3"+s.Suffix)
+	} else {
+		cols = append(cols, "", "", "")
+	}
+	fields := s.IdCol
+	for _, col := range cols {
+		if len(col) > 0 {
+			fields += ", " + col
+		} else {
+			fields += ", 0"
+		}
+	}
+	in, params := s.Dialect.InArray(s.IdCol, paging.Distinct(append([]string{}, ids...)), s.ToArray)
+	query := s.Dialect.Rebind(fmt.Sprintf("select %s from %s where %s", fields, s.UserInfoTable, in))
+	rows, err := s.DB.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	infos := make(map[string]UserInfo)
+	for rows.Next() {
+		var id string
+		counts := make([]sql.NullInt64, len(cols))
+		if err = rows.Scan(&id, &counts[0], &counts[1], &counts[2], &counts[3], &counts[4], &counts[5]); err != nil {
+			return nil, err
+		}
+		infos[id] = UserInfo{Id: id, FollowerCount: counts[0].Int64, FollowingCount: counts[1].Int64, ReactionCount: counts[2].Int64,
+			Level1Count: counts[3].Int64, Level2Count: counts[4].Int64, Level3Count: counts[5].Int64}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		info, ok := infos[id]
+		if !ok {
+			info.Id = id
+		}
+		result[i] = info
+	}
+	return result, nil
+}
@@ -0,0 +1,5 @@
+package reaction
+
+import "github.com/core-go/reaction/paging"
+
+type Info = paging.Info
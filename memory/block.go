@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/core-go/reaction/block"
+)
+
+// NewBlockService returns the block service, which removes the follow relationships of the blocked users from follows if it is not nil.
+func NewBlockService(follows block.Follows) *BlockService {
+	return &BlockService{
+		follows: follows,
+		blocks:  make(map[string]map[string]bool),
+		mutes:   make(map[string]map[string]bool),
+	}
+}
+
+type BlockService struct {
+	follows block.Follows
+	mu      sync.RWMutex
+	blocks  map[string]map[string]bool
+	mutes   map[string]map[string]bool
+}
+
+func (s *BlockService) Block(ctx context.Context, id string, target string) (int64, error) {
+	r := s.set(s.blocks, id, target, true)
+	if s.follows == nil {
+		return r, nil
+	}
+	if _, err := s.follows.Disconnect(ctx, id, target); err != nil {
+		return -1, err
+	}
+	return r, nil
+}
+
+func (s *BlockService) Unblock(ctx context.Context, id string, target string) (int64, error) {
+	return s.set(s.blocks, id, target, false), nil
+}
+
+func (s *BlockService) Mute(ctx context.Context, id string, target string) (int64, error) {
+	return s.set(s.mutes, id, target, true), nil
+}
+
+func (s *BlockService) Unmute(ctx context.Context, id string, target string) (int64, error) {
+	return s.set(s.mutes, id, target, false), nil
+}
+
+func (s *BlockService) IsBlocked(ctx context.Context, id string, userId string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.blocks[id][userId] || s.blocks[userId][id], nil
+}
+
+func (s *BlockService) Blocked(ctx context.Context, id string, userIds []string) (map[string]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	blocked := make(map[string]bool)
+	for _, userId := range userIds {
+		if s.blocks[id][userId] || s.blocks[userId][id] {
+			blocked[userId] = true
+		}
+	}
+	return blocked, nil
+}
+
+func (s *BlockService) Muted(ctx context.Context, id string, userIds []string) (map[string]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	muted := make(map[string]bool)
+	for _, userId := range userIds {
+		if s.mutes[id][userId] {
+			muted[userId] = true
+		}
+	}
+	return muted, nil
+}
+
+func (s *BlockService) set(m map[string]map[string]bool, id string, target string, value bool) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m[id][target] == value {
+		return 0
+	}
+	if value {
+		targets, ok := m[id]
+		if !ok {
+			targets = make(map[string]bool)
+			m[id] = targets
+		}
+		targets[target] = true
+	} else {
+		delete(m[id], target)
+	}
+	return 1
+}
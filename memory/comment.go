@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/core-go/reaction"
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/comment"
+)
+
+func NewCommentService(queryInfo func(ids []string) ([]comment.Info, error)) *CommentService {
+	return &CommentService{
+		QueryInfo: queryInfo,
+		comments:  make(map[string]comment.Comment),
+		counts:    make(map[reaction.Key]int64),
+	}
+}
+
+type CommentService struct {
+	QueryInfo func(ids []string) ([]comment.Info, error)
+	Guard     block.Guard
+	mu        sync.RWMutex
+	comments  map[string]comment.Comment
+	order     []string
+	counts    map[reaction.Key]int64
+}
+
+func (s *CommentService) Load(ctx context.Context, id string, author string, userId string) ([]comment.Response, error) {
+	s.mu.RLock()
+	var comments []comment.Comment
+	for _, commentId := range s.order {
+		c := s.comments[commentId]
+		if c.Id == id && c.Author == author {
+			comments = append(comments, c)
+		}
+	}
+	s.mu.RUnlock()
+	var rs []comment.Response
+	if len(comments) == 0 {
+		return rs, nil
+	}
+	ids := make([]string, 0)
+	for _, c := range comments {
+		ids = append(ids, c.UserId)
+	}
+	muted, err := block.Muted(ctx, s.Guard, userId, ids)
+	if err != nil {
+		return nil, err
+	}
+	var infos []comment.Info
+	if s.QueryInfo != nil {
+		infos, err = s.QueryInfo(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range comments {
+		if muted[c.UserId] {
+			continue
+		}
+		r := comment.Response{CommentId: c.CommentId, Id: c.Id, Author: c.Author, UserId: c.UserId, Comment: c.Comment,
+			Anonymous: c.Anonymous, Time: c.Time, UpdatedAt: c.UpdatedAt, Histories: c.Histories}
+		if !c.Anonymous {
+			for i := range infos {
+				if infos[i].Id == c.UserId {
+					r.AuthorURL = &infos[i].Url
+					r.AuthorName = &infos[i].Name
+					break
+				}
+			}
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func (s *CommentService) Create(ctx context.Context, id string, commentId string, userId string, author string, req comment.Request) (int64, error) {
+	if err := block.Check(ctx, s.Guard, author, userId); err != nil {
+		return -1, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.comments[commentId]; ok {
+		return -1, ErrDuplicate
+	}
+	t := time.Now()
+	s.comments[commentId] = comment.Comment{CommentId: commentId, Id: id, Author: author, UserId: userId, Comment: req.Comment, Anonymous: req.Anonymous, Time: &t}
+	s.order = append(s.order, commentId)
+	s.counts[reaction.Key{Id: id, Author: author}]++
+	return 1, nil
+}
+
+func (s *CommentService) Update(ctx context.Context, id string, commentId string, userId string, author string, req comment.Request) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.comments[commentId]
+	if !ok {
+		return 0, nil
+	}
+	if c.UpdatedAt != nil {
+		c.Histories = append(c.Histories, comment.Histories{Time: c.UpdatedAt, Comment: c.Comment})
+	} else {
+		c.Histories = append(c.Histories, comment.Histories{Time: c.Time, Comment: c.Comment})
+	}
+	t := time.Now()
+	c.Comment = req.Comment
+	c.UpdatedAt = &t
+	s.comments[commentId] = c
+	return 1, nil
+}
+
+func (s *CommentService) Delete(ctx context.Context, id string, commentId string, author string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.comments[commentId]
+	if !ok {
+		return 0, nil
+	}
+	delete(s.comments, commentId)
+	s.order = remove(s.order, commentId)
+	s.counts[reaction.Key{Id: c.Id, Author: c.Author}]--
+	return 1, nil
+}
+
+// Count returns the comment counter of the rate of author on id.
+func (s *CommentService) Count(id string, author string) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.counts[reaction.Key{Id: id, Author: author}]
+}
+
+func remove(list []string, item string) []string {
+	for i, v := range list {
+		if v == item {
+			return append(list[:i:i], list[i+1:]...)
+		}
+	}
+	return list
+}
@@ -0,0 +1,278 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/commentthread"
+	reply "github.com/core-go/reaction/commentthread/comment"
+)
+
+func NewCommentThreadService() *CommentThreadService {
+	return &CommentThreadService{
+		threads:   make(map[string]commentthread.CommentThread),
+		replies:   make(map[string]reply.Comment),
+		replied:   make(map[string]int64),
+		useful:    make(map[string]int64),
+		reactions: make(map[string]map[string]int),
+	}
+}
+
+type CommentThreadService struct {
+	Guard block.Guard
+	// Owner returns the author of the item id, to check that the author did not block the users who comment on it.
+	// It is required if Guard is not nil.
+	Owner     func(ctx context.Context, id string) (string, error)
+	mu        sync.RWMutex
+	threads   map[string]commentthread.CommentThread
+	replies   map[string]reply.Comment
+	order     []string
+	replied   map[string]int64
+	useful    map[string]int64
+	reactions map[string]map[string]int
+}
+
+func (s *CommentThreadService) Load(ctx context.Context, commentId string) (*commentthread.CommentThread, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.threads[commentId]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (s *CommentThreadService) Comment(ctx context.Context, id string, commentId string, author string, req commentthread.Request) (int64, error) {
+	if err := block.CheckOwner(ctx, s.Guard, s.Owner, id, author); err != nil {
+		return -1, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.threads[commentId]; ok {
+		return -1, ErrDuplicate
+	}
+	s.threads[commentId] = commentthread.CommentThread{CommentId: commentId, Id: id, Author: author, Comment: req.Comment, Time: time.Now(), Histories: []commentthread.History{}}
+	return 1, nil
+}
+
+func (s *CommentThreadService) Update(ctx context.Context, commentId string, author string, req commentthread.Request) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.threads[commentId]
+	if !ok {
+		return -1, nil
+	}
+	if c.Author != author {
+		return -2, commentthread.ErrNoPermission
+	}
+	t := time.Now()
+	c.Histories = append(c.Histories, commentthread.History{Comment: req.Comment, Time: t})
+	c.Comment = req.Comment
+	c.UpdatedAt = &t
+	s.threads[commentId] = c
+	return 1, nil
+}
+
+func (s *CommentThreadService) Remove(ctx context.Context, commentId string, author string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	if _, ok := s.threads[commentId]; ok {
+		delete(s.threads, commentId)
+		count++
+	}
+	for _, replyId := range append([]string{}, s.order...) {
+		if s.replies[replyId].CommentThreadId == commentId {
+			count += s.removeReply(replyId)
+		}
+	}
+	delete(s.replied, commentId)
+	count += int64(len(s.reactions[commentId]))
+	delete(s.reactions, commentId)
+	delete(s.useful, commentId)
+	return count, nil
+}
+
+// ReplyCount returns the reply counter of the comment thread.
+func (s *CommentThreadService) ReplyCount(commentId string) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.replied[commentId]
+}
+
+// UsefulCount returns the useful counter of the comment thread or reply.
+func (s *CommentThreadService) UsefulCount(commentId string) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.useful[commentId]
+}
+
+func (s *CommentThreadService) removeReply(commentId string) int64 {
+	delete(s.replies, commentId)
+	s.order = remove(s.order, commentId)
+	count := 1 + int64(len(s.reactions[commentId]))
+	delete(s.reactions, commentId)
+	delete(s.useful, commentId)
+	return count
+}
+
+// NewReplyService returns the reply service of the comment threads of threads.
+func NewReplyService(threads *CommentThreadService, queryInfo func(ids []string) ([]reply.Info, error)) *ReplyService {
+	return &ReplyService{threads: threads, QueryInfo: queryInfo}
+}
+
+type ReplyService struct {
+	threads   *CommentThreadService
+	QueryInfo func(ids []string) ([]reply.Info, error)
+	Guard     block.Guard
+	// Owner returns the author of the item id, to check that the author did not block the users who reply on it.
+	// It is required if Guard is not nil.
+	Owner func(ctx context.Context, id string) (string, error)
+}
+
+func (s *ReplyService) GetComments(ctx context.Context, commentThreadId string, userId *string) ([]reply.Response, error) {
+	rs := make([]reply.Response, 0)
+	var comments []reply.Comment
+	s.threads.mu.RLock()
+	for _, commentId := range s.threads.order {
+		c := s.threads.replies[commentId]
+		if c.CommentThreadId != commentThreadId {
+			continue
+		}
+		useful := int(s.threads.useful[commentId])
+		c.UsefulCount = &useful
+		if userId != nil && len(*userId) > 0 {
+			disable := s.threads.reactions[commentId][*userId] == 1
+			c.Disable = &disable
+		}
+		comments = append(comments, c)
+	}
+	s.threads.mu.RUnlock()
+	if len(comments) == 0 {
+		return rs, nil
+	}
+	ids := make([]string, 0)
+	for _, c := range comments {
+		ids = append(ids, c.Author)
+	}
+	var muted map[string]bool
+	var err error
+	if userId != nil {
+		muted, err = block.Muted(ctx, s.Guard, *userId, ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var infos []reply.Info
+	if s.QueryInfo != nil {
+		infos, err = s.QueryInfo(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range comments {
+		if muted[c.Author] {
+			continue
+		}
+		r := reply.Response{CommentId: c.CommentId, Id: c.Id, Author: c.Author, Comment: c.Comment, Time: c.Time, CommentThreadId: c.CommentThreadId,
+			UpdatedAt: c.UpdatedAt, Histories: c.Histories, ReplyCount: c.ReplyCount, UsefulCount: c.UsefulCount, Disable: c.Disable}
+		for i := range infos {
+			if infos[i].Id == c.Author {
+				r.AuthorURL = &infos[i].Url
+				r.AuthorName = &infos[i].Name
+				break
+			}
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func (s *ReplyService) Create(ctx context.Context, id, commentId, commentThreadId, author string, req reply.Request) (int64, error) {
+	if err := block.CheckOwner(ctx, s.Guard, s.Owner, id, author); err != nil {
+		return -1, err
+	}
+	s.threads.mu.Lock()
+	defer s.threads.mu.Unlock()
+	if _, ok := s.threads.replies[commentId]; ok {
+		return -1, ErrDuplicate
+	}
+	s.threads.replies[commentId] = reply.Comment{CommentId: commentId, Id: id, Author: author, Comment: req.Comment, Time: time.Now(), CommentThreadId: commentThreadId, Histories: []reply.History{}}
+	s.threads.order = append(s.threads.order, commentId)
+	s.threads.replied[commentThreadId]++
+	return 2, nil
+}
+
+func (s *ReplyService) Update(ctx context.Context, commentId, author string, req reply.Request) (int64, error) {
+	s.threads.mu.Lock()
+	defer s.threads.mu.Unlock()
+	c, ok := s.threads.replies[commentId]
+	if !ok {
+		return -1, sql.ErrNoRows
+	}
+	if c.Author != author {
+		return -2, reply.ErrNoPermission
+	}
+	t := time.Now()
+	c.Histories = append(c.Histories, reply.History{Comment: c.Comment, Time: t})
+	c.Comment = req.Comment
+	c.UpdatedAt = &t
+	s.threads.replies[commentId] = c
+	return 1, nil
+}
+
+func (s *ReplyService) Remove(ctx context.Context, commentId string, commentThreadId string, author string) (int64, error) {
+	s.threads.mu.Lock()
+	defer s.threads.mu.Unlock()
+	c, ok := s.threads.replies[commentId]
+	if !ok || c.Author != author {
+		return 0, errors.New("user does not have permission to delete the comment")
+	}
+	count := s.threads.removeReply(commentId)
+	s.threads.replied[commentThreadId]--
+	return count + 1, nil
+}
+
+// NewCommentReactionService returns the service of the reactions to the comment threads and replies of threads.
+func NewCommentReactionService(threads *CommentThreadService) *CommentReactionService {
+	return &CommentReactionService{threads: threads}
+}
+
+type CommentReactionService struct {
+	threads *CommentThreadService
+	Guard   block.Guard
+}
+
+func (s *CommentReactionService) Save(ctx context.Context, commentId string, author string, userId string, reaction int) (int64, error) {
+	if err := block.Check(ctx, s.Guard, author, userId); err != nil {
+		return -1, err
+	}
+	s.threads.mu.Lock()
+	defer s.threads.mu.Unlock()
+	reactions, ok := s.threads.reactions[commentId]
+	if !ok {
+		reactions = make(map[string]int)
+		s.threads.reactions[commentId] = reactions
+	}
+	if _, ok := reactions[userId]; ok {
+		return -1, ErrDuplicate
+	}
+	reactions[userId] = reaction
+	s.threads.useful[commentId]++
+	return 2, nil
+}
+
+func (s *CommentReactionService) Remove(ctx context.Context, commentId string, author string, userId string) (int64, error) {
+	s.threads.mu.Lock()
+	defer s.threads.mu.Unlock()
+	if _, ok := s.threads.reactions[commentId][userId]; !ok {
+		return 0, nil
+	}
+	delete(s.threads.reactions[commentId], userId)
+	s.threads.useful[commentId]--
+	return 1, nil
+}
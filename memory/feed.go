@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/core-go/reaction/feed"
+	"github.com/core-go/reaction/outbox"
+	"github.com/core-go/reaction/paging"
+)
+
+// NewFeedService returns a feed.FeedService which reads the follow graph of follows.
+func NewFeedService(follows *FollowService, strategy feed.Strategy, queryInfo func(ids []string) ([]feed.Info, error)) *FeedService {
+	if strategy != feed.FanOutOnWrite {
+		strategy = feed.FanOutOnRead
+	}
+	return &FeedService{
+		Follows:    follows,
+		Strategy:   strategy,
+		QueryInfo:  queryInfo,
+		activities: make(map[string]feed.Activity),
+		feeds:      make(map[string]map[string]bool),
+	}
+}
+
+type FeedService struct {
+	Follows    *FollowService
+	Strategy   feed.Strategy
+	QueryInfo  func(ids []string) ([]feed.Info, error)
+	mu         sync.RWMutex
+	activities map[string]feed.Activity
+	feeds      map[string]map[string]bool
+}
+
+func (s *FeedService) Send(ctx context.Context, m outbox.Message) error {
+	switch m.Type {
+	case outbox.TypeCommentDeleted:
+		var e outbox.CommentDeleted
+		if err := m.Decode(&e); err != nil {
+			return err
+		}
+		s.remove(func(a feed.Activity) bool {
+			return (a.Type == feed.Commented || a.Type == feed.Replied) && a.Object == e.CommentId
+		})
+		return nil
+	case outbox.TypeUnsaved:
+		var e outbox.Unsaved
+		if err := m.Decode(&e); err != nil {
+			return err
+		}
+		s.remove(func(a feed.Activity) bool {
+			return a.Type == feed.Saved && a.Actor == e.Id && a.Item == e.Item
+		})
+		return nil
+	case outbox.TypeRateDeleted:
+		var e outbox.RateDeleted
+		if err := m.Decode(&e); err != nil {
+			return err
+		}
+		s.remove(func(a feed.Activity) bool {
+			return a.Type == feed.Rated && a.Actor == e.Author && a.Item == e.Id
+		})
+		return nil
+	}
+	a, err := feed.ToActivity(m)
+	if err != nil || a == nil {
+		return err
+	}
+	var followers []string
+	if s.Strategy == feed.FanOutOnWrite {
+		s.Follows.mu.RLock()
+		for follower := range s.Follows.followers[a.Actor] {
+			followers = append(followers, follower)
+		}
+		s.Follows.mu.RUnlock()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.activities[a.Id]; ok {
+		return nil
+	}
+	s.activities[a.Id] = *a
+	for _, follower := range followers {
+		activities, ok := s.feeds[follower]
+		if !ok {
+			activities = make(map[string]bool)
+			s.feeds[follower] = activities
+		}
+		activities[a.Id] = true
+	}
+	return nil
+}
+
+func (s *FeedService) remove(match func(a feed.Activity) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, a := range s.activities {
+		if match(a) {
+			delete(s.activities, id)
+			for _, activities := range s.feeds {
+				delete(activities, id)
+			}
+		}
+	}
+}
+
+func (s *FeedService) Load(ctx context.Context, userId string, cursor string, limit int64) (*feed.Activities, error) {
+	if s.Strategy == feed.FanOutOnWrite {
+		return s.list(func(a feed.Activity) bool { return s.feeds[userId][a.Id] }, cursor, limit)
+	}
+	s.Follows.mu.RLock()
+	following := make(map[string]bool, len(s.Follows.following[userId]))
+	for target := range s.Follows.following[userId] {
+		following[target] = true
+	}
+	s.Follows.mu.RUnlock()
+	return s.list(func(a feed.Activity) bool { return following[a.Actor] }, cursor, limit)
+}
+
+func (s *FeedService) Activities(ctx context.Context, actor string, cursor string, limit int64) (*feed.Activities, error) {
+	return s.list(func(a feed.Activity) bool { return a.Actor == actor }, cursor, limit)
+}
+
+func (s *FeedService) list(match func(a feed.Activity) bool, cursor string, limit int64) (*feed.Activities, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	s.mu.RLock()
+	list := make([]feed.Activity, 0)
+	for _, a := range s.activities {
+		if match(a) {
+			list = append(list, a)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Time.Equal(*list[j].Time) {
+			return list[i].Time.After(*list[j].Time)
+		}
+		return list[i].Id > list[j].Id
+	})
+	if len(cursor) > 0 {
+		t, id, err := paging.DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		i := sort.Search(len(list), func(i int) bool {
+			return list[i].Time.Before(t) || (list[i].Time.Equal(t) && list[i].Id < id)
+		})
+		list = list[i:]
+	}
+	result := &feed.Activities{List: list}
+	if int64(len(result.List)) > limit {
+		result.List = result.List[:limit]
+		last := result.List[limit-1]
+		result.Next = paging.EncodeCursor(*last.Time, last.Id)
+	}
+	if s.QueryInfo == nil || len(result.List) == 0 {
+		return result, nil
+	}
+	ids := make([]string, 0, len(result.List))
+	for _, a := range result.List {
+		ids = append(ids, a.Actor)
+	}
+	infos, err := s.QueryInfo(ids)
+	if err != nil {
+		return nil, err
+	}
+	for k := range result.List {
+		for i := range infos {
+			if infos[i].Id == result.List[k].Actor {
+				result.List[k].Url = &infos[i].Url
+				result.List[k].Name = &infos[i].Name
+				break
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,388 @@
+package memory
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/follow"
+	"github.com/core-go/reaction/paging"
+)
+
+func NewFollowService(queryInfo func(ids []string) ([]follow.Info, error)) *FollowService {
+	return &FollowService{
+		QueryInfo: queryInfo,
+		following: make(map[string]map[string]time.Time),
+		followers: make(map[string]map[string]time.Time),
+		requests:  make(map[string]map[string]time.Time),
+		approval:  make(map[string]bool),
+	}
+}
+
+type FollowService struct {
+	QueryInfo func(ids []string) ([]follow.Info, error)
+	Guard     block.Guard
+	mu        sync.RWMutex
+	following map[string]map[string]time.Time
+	followers map[string]map[string]time.Time
+	requests  map[string]map[string]time.Time
+	approval  map[string]bool
+}
+
+// SetApproval sets whether the user approves the follow requests, as the approval column of the user info table does.
+func (s *FollowService) SetApproval(id string, approval bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.approval[id] = approval
+}
+
+func (s *FollowService) Follow(ctx context.Context, id string, target string) (*follow.Result, error) {
+	if err := block.Check(ctx, s.Guard, target, id); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.following[id][target]; ok {
+		return &follow.Result{Status: follow.AlreadyFollowing}, nil
+	}
+	if s.approval[target] {
+		if s.request(id, target) == 0 {
+			return &follow.Result{Status: follow.AlreadyRequested}, nil
+		}
+		return &follow.Result{Status: follow.Requested, Count: 1}, nil
+	}
+	return &follow.Result{Status: follow.Followed, Count: s.follow(id, target)}, nil
+}
+
+func (s *FollowService) follow(id string, target string) int64 {
+	if _, ok := s.following[id][target]; ok {
+		return 0
+	}
+	now := time.Now()
+	put(s.following, id, target, now)
+	put(s.followers, target, id, now)
+	return 1
+}
+
+func (s *FollowService) request(id string, target string) int64 {
+	if _, ok := s.requests[target][id]; ok {
+		return 0
+	}
+	put(s.requests, target, id, time.Now())
+	return 1
+}
+
+func (s *FollowService) UnFollow(ctx context.Context, id string, target string) (*follow.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unfollow(id, target) == 0 {
+		return &follow.Result{Status: follow.NotFollowing}, nil
+	}
+	return &follow.Result{Status: follow.Unfollowed, Count: 1}, nil
+}
+
+func (s *FollowService) unfollow(id string, target string) int64 {
+	if _, ok := s.following[id][target]; !ok {
+		return 0
+	}
+	delete(s.following[id], target)
+	delete(s.followers[target], id)
+	return 1
+}
+
+func (s *FollowService) Disconnect(ctx context.Context, id string, target string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := s.unfollow(id, target) + s.unfollow(target, id)
+	s.removeRequest(id, target)
+	s.removeRequest(target, id)
+	return count, nil
+}
+
+func (s *FollowService) CheckFollow(ctx context.Context, id string, target string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.following[id][target]; ok {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (s *FollowService) Followers(ctx context.Context, id string, cursor string, limit int64) (*follow.Users, error) {
+	return s.list(s.followers, id, cursor, limit)
+}
+
+func (s *FollowService) Following(ctx context.Context, id string, cursor string, limit int64) (*follow.Users, error) {
+	return s.list(s.following, id, cursor, limit)
+}
+
+func (s *FollowService) RequestFollow(ctx context.Context, id string, target string) (int64, error) {
+	if err := block.Check(ctx, s.Guard, target, id); err != nil {
+		return -1, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.following[id][target]; ok {
+		return 0, nil
+	}
+	return s.request(id, target), nil
+}
+
+func (s *FollowService) ApproveFollow(ctx context.Context, id string, requester string) (int64, error) {
+	if err := block.Check(ctx, s.Guard, id, requester); err != nil {
+		return -1, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.removeRequest(id, requester) {
+		return 0, nil
+	}
+	return s.follow(requester, id), nil
+}
+
+func (s *FollowService) RejectFollow(ctx context.Context, id string, requester string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.removeRequest(id, requester) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (s *FollowService) CancelRequest(ctx context.Context, id string, target string) (int64, error) {
+	return s.RejectFollow(ctx, target, id)
+}
+
+func (s *FollowService) ListPendingRequests(ctx context.Context, id string, cursor string, limit int64) (*follow.Users, error) {
+	return s.list(s.requests, id, cursor, limit)
+}
+
+func (s *FollowService) removeRequest(id string, requester string) bool {
+	if _, ok := s.requests[id][requester]; !ok {
+		return false
+	}
+	delete(s.requests[id], requester)
+	return true
+}
+
+func (s *FollowService) Relationship(ctx context.Context, id string, target string) (*follow.Relationship, error) {
+	relationships, err := s.Relationships(ctx, id, []string{target})
+	if err != nil {
+		return nil, err
+	}
+	return &relationships[0], nil
+}
+
+func (s *FollowService) Relationships(ctx context.Context, id string, targets []string) ([]follow.Relationship, error) {
+	blocked, err := block.Blocked(ctx, s.Guard, id, targets)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	relationships := make([]follow.Relationship, len(targets))
+	for i, target := range targets {
+		_, following := s.following[id][target]
+		_, followedBy := s.followers[id][target]
+		_, pending := s.requests[target][id]
+		relationships[i] = follow.Relationship{Id: target, Following: following, FollowedBy: followedBy,
+			Mutual: following && followedBy, Pending: pending, Blocked: blocked[target]}
+	}
+	return relationships, nil
+}
+
+func (s *FollowService) MutualFollowers(ctx context.Context, id string, target string, limit int64) (*follow.Users, error) {
+	result, err := s.BatchMutualFollowers(ctx, id, []string{target}, limit)
+	if err != nil {
+		return nil, err
+	}
+	return result[target], nil
+}
+
+func (s *FollowService) BatchMutualFollowers(ctx context.Context, id string, targets []string, limit int64) (map[string]*follow.Users, error) {
+	if len(paging.Distinct(append([]string{}, targets...))) > follow.MaxTargets {
+		return nil, follow.ErrTooManyTargets
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	result := make(map[string]*follow.Users)
+	for _, target := range targets {
+		mutual := make(map[string]time.Time)
+		s.mu.RLock()
+		for userId, t := range s.followers[target] {
+			if _, ok := s.following[id][userId]; ok {
+				mutual[userId] = t
+			}
+		}
+		s.mu.RUnlock()
+		users, err := page(mutual, "", limit, s.QueryInfo)
+		if err != nil {
+			return nil, err
+		}
+		result[target] = users
+	}
+	return result, nil
+}
+
+// Count returns the follower and following counters of the user.
+func (s *FollowService) Count(id string) (followers int64, following int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return int64(len(s.followers[id])), int64(len(s.following[id]))
+}
+
+func (s *FollowService) list(users map[string]map[string]time.Time, id string, cursor string, limit int64) (*follow.Users, error) {
+	if limit > follow.MaxLimit {
+		limit = follow.MaxLimit
+	}
+	s.mu.RLock()
+	times := make(map[string]time.Time, len(users[id]))
+	for userId, t := range users[id] {
+		times[userId] = t
+	}
+	s.mu.RUnlock()
+	return page(times, cursor, limit, s.QueryInfo)
+}
+
+// page returns the page of the users, the latest first.
+func page(users map[string]time.Time, cursor string, limit int64, queryInfo func(ids []string) ([]follow.Info, error)) (*follow.Users, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	var after *time.Time
+	var afterUserId string
+	if len(cursor) > 0 {
+		t, userId, err := paging.DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after, afterUserId = &t, userId
+	}
+	list := make([]follow.User, 0, len(users))
+	for userId, t := range users {
+		t := t
+		list = append(list, follow.User{Id: userId, Time: &t})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Time.Equal(*list[j].Time) {
+			return list[i].Time.After(*list[j].Time)
+		}
+		return list[i].Id > list[j].Id
+	})
+	result := &follow.Users{List: make([]follow.User, 0), Total: int64(len(list))}
+	for _, u := range list {
+		if after != nil && (u.Time.After(*after) || (u.Time.Equal(*after) && u.Id >= afterUserId)) {
+			continue
+		}
+		result.List = append(result.List, u)
+	}
+	if int64(len(result.List)) > limit {
+		result.List = result.List[:limit]
+		last := result.List[limit-1]
+		result.Next = paging.EncodeCursor(*last.Time, last.Id)
+	}
+	if queryInfo == nil || len(result.List) == 0 {
+		return result, nil
+	}
+	ids := make([]string, 0)
+	for _, u := range result.List {
+		ids = append(ids, u.Id)
+	}
+	infos, err := queryInfo(ids)
+	if err != nil {
+		return nil, err
+	}
+	for k := range result.List {
+		for i := range infos {
+			if infos[i].Id == result.List[k].Id {
+				result.List[k].Url = &infos[i].Url
+				result.List[k].Name = &infos[i].Name
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func put(m map[string]map[string]time.Time, id string, target string, t time.Time) {
+	targets, ok := m[id]
+	if !ok {
+		targets = make(map[string]time.Time)
+		m[id] = targets
+	}
+	targets[target] = t
+}
+
+func (s *FollowService) BulkFollow(ctx context.Context, id string, targets []string) (*follow.BulkResult, error) {
+	result := &follow.BulkResult{}
+	seen := make(map[string]bool)
+	candidates := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if len(target) == 0 || target == id {
+			result.Skipped = append(result.Skipped, target)
+		} else if seen[target] {
+			result.Duplicates = append(result.Duplicates, target)
+		} else {
+			seen[target] = true
+			candidates = append(candidates, target)
+		}
+	}
+	blocked, err := block.Blocked(ctx, s.Guard, id, candidates)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, target := range candidates {
+		if blocked[target] {
+			result.Blocked = append(result.Blocked, target)
+		} else if _, ok := s.following[id][target]; ok {
+			result.Duplicates = append(result.Duplicates, target)
+		} else if s.approval[target] {
+			if s.request(id, target) > 0 {
+				result.Requested = append(result.Requested, target)
+			} else {
+				result.Duplicates = append(result.Duplicates, target)
+			}
+		} else {
+			result.Followed += s.follow(id, target)
+		}
+	}
+	return result, nil
+}
+
+func (s *FollowService) ExportGraph(ctx context.Context, id string, format string, w io.Writer) error {
+	writer, err := follow.NewEdgeWriter(w, format)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	following, followers := edges(follow.DirectionFollowing, s.following[id]), edges(follow.DirectionFollower, s.followers[id])
+	s.mu.RUnlock()
+	for _, edge := range append(following, followers...) {
+		if err = writer.Write(edge); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// edges returns the edges of the users, the oldest first, as the SQL service exports them.
+func edges(direction string, users map[string]time.Time) []follow.Edge {
+	list := make([]follow.Edge, 0, len(users))
+	for userId, t := range users {
+		t := t
+		list = append(list, follow.Edge{Direction: direction, UserId: userId, Time: &t})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Time.Equal(*list[j].Time) {
+			return list[i].Time.Before(*list[j].Time)
+		}
+		return list[i].UserId < list[j].UserId
+	})
+	return list
+}
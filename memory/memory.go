@@ -0,0 +1,7 @@
+// Package memory provides thread-safe in-memory implementations of the services of this module,
+// keeping the same counters as the SQL implementations, so that applications can be tested without a database.
+package memory
+
+import "errors"
+
+var ErrDuplicate = errors.New("duplicate key")
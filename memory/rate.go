@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/core-go/reaction/rate"
+)
+
+func NewRateService(scale rate.Scale) *RateService {
+	return &RateService{
+		Scale: scale,
+		rates: make(map[string]map[string]rate.Rate),
+		infos: make(map[string]*rateInfo),
+	}
+}
+
+type RateService struct {
+	Scale rate.Scale
+	// Ranking computes the ranking scores of Info.
+	Ranking rate.Ranking
+	mu      sync.RWMutex
+	rates   map[string]map[string]rate.Rate
+	infos   map[string]*rateInfo
+}
+
+type rateInfo struct {
+	counts []int
+	count  int
+	score  float64
+}
+
+func (s *RateService) Load(ctx context.Context, id string, author string) (*rate.Rate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.rates[id][author]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func (s *RateService) Rate(ctx context.Context, id string, author string, req rate.Request) (int64, error) {
+	if err := s.Scale.Validate(req.Rate); err != nil {
+		return -1, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rate(id, author, req)
+}
+
+func (s *RateService) rate(id string, author string, req rate.Request) (int64, error) {
+	bucket, err := s.Scale.BucketOf(req.Rate)
+	if err != nil {
+		return -1, err
+	}
+	now := time.Now()
+	r := rate.Rate{Id: id, Author: author, Rate: req.Rate, Review: req.Review, Anonymous: req.Anonymous, Time: &now}
+	rates, ok := s.rates[id]
+	if !ok {
+		rates = make(map[string]rate.Rate)
+		s.rates[id] = rates
+	}
+	info, ok := s.infos[id]
+	if !ok {
+		info = &rateInfo{counts: make([]int, s.Scale.Size())}
+		s.infos[id] = info
+	}
+	old, exist := rates[author]
+	if exist {
+		if old.Rate == r.Rate && old.Review == r.Review {
+			return 0, nil
+		}
+		if old.Rate != r.Rate {
+			// An old rate out of the scale is in no bucket.
+			if oldBucket, err := s.Scale.BucketOf(old.Rate); err == nil {
+				info.counts[oldBucket]--
+			} else if err != rate.ErrOutOfScale {
+				return -1, err
+			}
+			info.counts[bucket]++
+			info.score += float64(r.Rate - old.Rate)
+		}
+		r.UsefulCount, r.ReplyCount = old.UsefulCount, old.ReplyCount
+		r.Histories = append(old.Histories, rate.Histories{Time: old.Time, Rate: old.Rate, Review: old.Review})
+	} else {
+		info.counts[bucket]++
+		info.count++
+		info.score += float64(r.Rate)
+	}
+	rates[author] = r
+	return 1, nil
+}
+
+func (s *RateService) Remove(ctx context.Context, id string, author string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.rates[id][author]
+	if !ok {
+		return 0, nil
+	}
+	bucket, err := s.Scale.BucketOf(r.Rate)
+	if err != nil && err != rate.ErrOutOfScale {
+		return -1, err
+	}
+	delete(s.rates[id], author)
+	info := s.infos[id]
+	if err == nil {
+		info.counts[bucket]--
+	}
+	info.count--
+	info.score -= float64(r.Rate)
+	return 1, nil
+}
+
+func (s *RateService) History(ctx context.Context, id string, author string) (*rate.History, error) {
+	r, err := s.Load(ctx, id, author)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	history := rate.ToHistory(*r)
+	return &history, nil
+}
+
+func (s *RateService) Revert(ctx context.Context, id string, author string, index int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var old *rate.Rate
+	if r, ok := s.rates[id][author]; ok {
+		old = &r
+	}
+	req, err := rate.ToRevert(old, index)
+	if err != nil {
+		return -1, err
+	}
+	if err = s.Scale.Validate(req.Rate); err != nil {
+		return -1, err
+	}
+	return s.rate(id, author, req)
+}
+
+// Info returns the rate summary of the rated item, or nil if it was never rated.
+func (s *RateService) Info(id string) *rate.RateInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	info, ok := s.infos[id]
+	if !ok {
+		return nil
+	}
+	result := &rate.RateInfo{Id: id, Count: info.count, Score: info.score, Histogram: append([]int(nil), info.counts...)}
+	if info.count > 0 {
+		result.Rate = float32(info.score / float64(info.count))
+	}
+	s.Ranking.Rank(result)
+	return result
+}
@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/core-go/reaction/rates"
+)
+
+func NewRatesService(max int) *RatesService {
+	return &RatesService{
+		Max:   max,
+		rates: make(map[string]map[string]rates.Rates),
+		infos: make(map[string]*RatesInfo),
+	}
+}
+
+type RatesService struct {
+	Max   int
+	mu    sync.RWMutex
+	rates map[string]map[string]rates.Rates
+	infos map[string]*RatesInfo
+}
+
+// RatesInfo is the summary of a rated item: the overall average and, per criterion, the average and the histogram.
+type RatesInfo struct {
+	Id     string
+	Rate   float32
+	Count  int
+	Score  float32
+	Rates  []float32
+	Counts []map[int]int
+	scores []float32
+}
+
+func (s *RatesService) Rate(ctx context.Context, id string, author string, req *rates.Request) (int64, error) {
+	if len(req.Rates) != s.Max {
+		return -1, rates.ErrInvalidRate
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := time.Now()
+	r := rates.Rates{Id: id, Author: author, Rate: avg(req.Rates), Rates: req.Rates, Review: req.Review, Anonymous: req.Anonymous, Time: &t}
+	items, ok := s.rates[id]
+	if !ok {
+		items = make(map[string]rates.Rates)
+		s.rates[id] = items
+	}
+	info, ok := s.infos[id]
+	if !ok {
+		info = &RatesInfo{Id: id, Rates: make([]float32, s.Max), Counts: make([]map[int]int, s.Max), scores: make([]float32, s.Max)}
+		for i := range info.Counts {
+			info.Counts[i] = make(map[int]int)
+		}
+		s.infos[id] = info
+	}
+	old, exist := items[author]
+	if exist {
+		for i, v := range old.Rates {
+			info.Counts[i][int(v)]--
+			info.scores[i] -= v
+		}
+		info.Score -= old.Rate
+		r.UsefulCount, r.ReplyCount = old.UsefulCount, old.ReplyCount
+		r.Histories = append(old.Histories, rates.Histories{Time: old.Time, Rate: old.Rate, Review: old.Review})
+	} else {
+		info.Count++
+	}
+	for i, v := range r.Rates {
+		info.Counts[i][int(v)]++
+		info.scores[i] += v
+		info.Rates[i] = info.scores[i] / float32(info.Count)
+	}
+	info.Score += r.Rate
+	info.Rate = info.Score / float32(info.Count)
+	items[author] = r
+	return 1, nil
+}
+
+func (s *RatesService) Load(ctx context.Context, id string, author string) (*rates.Rates, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.rates[id][author]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+// Info returns a copy of the summary of the rated item, or nil if it was never rated.
+func (s *RatesService) Info(id string) *RatesInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	info, ok := s.infos[id]
+	if !ok {
+		return nil
+	}
+	result := &RatesInfo{Id: id, Rate: info.Rate, Count: info.Count, Score: info.Score,
+		Rates: append([]float32{}, info.Rates...), Counts: make([]map[int]int, len(info.Counts))}
+	for i, counts := range info.Counts {
+		result.Counts[i] = make(map[int]int, len(counts))
+		for k, v := range counts {
+			result.Counts[i][k] = v
+		}
+	}
+	return result
+}
+
+func avg(numbers []float32) float32 {
+	if len(numbers) == 0 {
+		return 0
+	}
+	var res float32
+	for _, n := range numbers {
+		res += n
+	}
+	return res / float32(len(numbers))
+}
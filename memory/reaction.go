@@ -0,0 +1,203 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/core-go/reaction"
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/paging"
+)
+
+func NewReactionService(queryInfo func(ids []string) ([]reaction.Info, error), kinds ...reaction.Kind) *ReactionService {
+	ks := []reaction.Kind{{Type: 1, Name: "useful"}}
+	for _, k := range kinds {
+		if k.Type == 1 {
+			ks[0] = k
+		} else {
+			ks = append(ks, k)
+		}
+	}
+	return &ReactionService{
+		Kinds:     ks,
+		QueryInfo: queryInfo,
+		reactions: make(map[reaction.Key]map[string]reaction.Reaction),
+		counts:    make(map[reaction.Key]map[int8]int64),
+	}
+}
+
+type ReactionService struct {
+	Kinds     []reaction.Kind
+	QueryInfo func(ids []string) ([]reaction.Info, error)
+	Guard     block.Guard
+	mu        sync.RWMutex
+	reactions map[reaction.Key]map[string]reaction.Reaction
+	counts    map[reaction.Key]map[int8]int64
+}
+
+func (s *ReactionService) Insert(ctx context.Context, r *reaction.Reaction) (*reaction.Result, error) {
+	if !s.valid(r.Type) {
+		return nil, reaction.ErrInvalidType
+	}
+	if err := block.Check(ctx, s.Guard, r.Author, r.UserId); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := reaction.Key{Id: r.Id, Author: r.Author}
+	reactions, ok := s.reactions[key]
+	if !ok {
+		reactions = make(map[string]reaction.Reaction)
+		s.reactions[key] = reactions
+	}
+	counts, ok := s.counts[key]
+	if !ok {
+		counts = make(map[int8]int64)
+		s.counts[key] = counts
+	}
+	result := &reaction.Result{Status: reaction.AlreadyExisted}
+	old, exist := reactions[r.UserId]
+	if !exist {
+		result.Status = reaction.Created
+		counts[r.Type]++
+	} else if old.Type != r.Type {
+		result.Status = reaction.Changed
+		counts[r.Type]++
+		if s.valid(old.Type) {
+			counts[old.Type]--
+		}
+	}
+	if result.Status != reaction.AlreadyExisted {
+		reactions[r.UserId] = *r
+	}
+	result.Count = counts[r.Type]
+	return result, nil
+}
+
+func (s *ReactionService) Delete(ctx context.Context, r *reaction.Reaction) (*reaction.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := reaction.Key{Id: r.Id, Author: r.Author}
+	old, exist := s.reactions[key][r.UserId]
+	if !exist {
+		return &reaction.Result{Status: reaction.NotFound}, nil
+	}
+	delete(s.reactions[key], r.UserId)
+	result := &reaction.Result{Status: reaction.Removed}
+	if s.valid(old.Type) {
+		s.counts[key][old.Type]--
+		result.Count = s.counts[key][old.Type]
+	}
+	return result, nil
+}
+
+func (s *ReactionService) Summary(ctx context.Context, id string, author string) (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := s.counts[reaction.Key{Id: id, Author: author}]
+	summary := make(map[string]int64, len(s.Kinds))
+	for _, k := range s.Kinds {
+		summary[k.Name] = counts[k.Type]
+	}
+	return summary, nil
+}
+
+func (s *ReactionService) ListReactors(ctx context.Context, id string, author string, kind int8, cursor string, limit int64) (*reaction.Reactors, error) {
+	if limit <= 0 {
+		limit = 20
+	} else if limit > reaction.MaxLimit {
+		limit = reaction.MaxLimit
+	}
+	var after *time.Time
+	var afterUserId string
+	if len(cursor) > 0 {
+		t, userId, err := paging.DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after, afterUserId = &t, userId
+	}
+	s.mu.RLock()
+	list := make([]reaction.Reactor, 0)
+	for _, r := range s.reactions[reaction.Key{Id: id, Author: author}] {
+		if kind == 0 || r.Type == kind {
+			list = append(list, reaction.Reactor{UserId: r.UserId, Type: r.Type, Time: r.Time})
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		ti, tj := timeOf(list[i].Time), timeOf(list[j].Time)
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return list[i].UserId > list[j].UserId
+	})
+	result := &reaction.Reactors{List: make([]reaction.Reactor, 0), Total: int64(len(list))}
+	for _, r := range list {
+		if after != nil {
+			t := timeOf(r.Time)
+			if t.After(*after) || (t.Equal(*after) && r.UserId >= afterUserId) {
+				continue
+			}
+		}
+		result.List = append(result.List, r)
+	}
+	if int64(len(result.List)) > limit {
+		result.List = result.List[:limit]
+		last := result.List[limit-1]
+		if last.Time != nil {
+			result.Next = paging.EncodeCursor(*last.Time, last.UserId)
+		}
+	}
+	if s.QueryInfo == nil || len(result.List) == 0 {
+		return result, nil
+	}
+	ids := make([]string, 0)
+	for _, r := range result.List {
+		ids = append(ids, r.UserId)
+	}
+	infos, err := s.QueryInfo(ids)
+	if err != nil {
+		return nil, err
+	}
+	for k := range result.List {
+		for i := range infos {
+			if infos[i].Id == result.List[k].UserId {
+				result.List[k].Url = &infos[i].Url
+				result.List[k].Name = &infos[i].Name
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *ReactionService) CheckReactions(ctx context.Context, userId string, keys []reaction.Key) (map[reaction.Key]int8, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[reaction.Key]int8)
+	for _, k := range keys {
+		if r, ok := s.reactions[k][userId]; ok {
+			result[k] = r.Type
+		}
+	}
+	return result, nil
+}
+
+func (s *ReactionService) valid(t int8) bool {
+	for _, k := range s.Kinds {
+		if k.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+func timeOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
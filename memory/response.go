@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/core-go/reaction"
+	"github.com/core-go/reaction/response"
+)
+
+func NewResponseService() *ResponseService {
+	return &ResponseService{
+		responses: make(map[reaction.Key]response.Response),
+		counts:    make(map[string]int64),
+	}
+}
+
+type ResponseService struct {
+	mu        sync.RWMutex
+	responses map[reaction.Key]response.Response
+	counts    map[string]int64
+}
+
+func (s *ResponseService) Load(ctx context.Context, id string, author string) (*response.Response, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.responses[reaction.Key{Id: id, Author: author}]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func (s *ResponseService) Response(ctx context.Context, r *response.Response) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := reaction.Key{Id: r.Id, Author: r.Author}
+	old, exist := s.responses[key]
+	if exist {
+		if old.Description == r.Description {
+			return 0, nil
+		}
+		r.Histories = append(old.Histories, response.Histories{Time: old.Time, Description: old.Description})
+		r.UsefulCount, r.CommentCount = old.UsefulCount, old.CommentCount
+	} else {
+		s.counts[r.Id]++
+	}
+	s.responses[key] = *r
+	return 1, nil
+}
+
+// Count returns the response counter of id.
+func (s *ResponseService) Count(id string) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.counts[id]
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+func NewSaveService(max int) *SaveService {
+	return &SaveService{
+		Max:     max,
+		items:   make(map[string][]string),
+		targets: make(map[string]interface{}),
+	}
+}
+
+type SaveService struct {
+	Max     int
+	mu      sync.RWMutex
+	items   map[string][]string
+	targets map[string]interface{}
+}
+
+// Put registers the target which Load returns for the saved item.
+func (s *SaveService) Put(item string, target interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets[item] = target
+}
+
+func (s *SaveService) Load(ctx context.Context, id string, listResult interface{}) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := s.items[id]
+	if len(items) == 0 {
+		return nil
+	}
+	v := reflect.ValueOf(listResult)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return errors.New("listResult must be a pointer to a slice")
+	}
+	list := reflect.MakeSlice(v.Elem().Type(), 0, len(items))
+	elemType := v.Elem().Type().Elem()
+	for _, item := range items {
+		target, ok := s.targets[item]
+		if !ok {
+			continue
+		}
+		t := reflect.ValueOf(target)
+		// As the SQL service, the targets are pointers in a []interface{}.
+		if !t.Type().AssignableTo(elemType) && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		list = reflect.Append(list, t)
+	}
+	v.Elem().Set(list)
+	return nil
+}
+
+func (s *SaveService) Save(ctx context.Context, id string, item string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := s.items[id]
+	for _, v := range items {
+		if v == item {
+			return -1, nil
+		}
+	}
+	items = append(items, item)
+	if s.Max > 0 && len(items) > s.Max {
+		items = items[1:]
+	}
+	s.items[id] = items
+	return 1, nil
+}
+
+func (s *SaveService) Remove(ctx context.Context, id string, item string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items, ok := s.items[id]
+	if !ok {
+		return 0, nil
+	}
+	newItems := []string{}
+	for _, v := range items {
+		if v != item {
+			newItems = append(newItems, v)
+		}
+	}
+	s.items[id] = newItems
+	return 1, nil
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"github.com/core-go/reaction"
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/comment"
+	"github.com/core-go/reaction/commentthread"
+	reply "github.com/core-go/reaction/commentthread/comment"
+	commentreaction "github.com/core-go/reaction/commentthread/reaction"
+	"github.com/core-go/reaction/feed"
+	"github.com/core-go/reaction/follow"
+	"github.com/core-go/reaction/rate"
+	"github.com/core-go/reaction/rates"
+	"github.com/core-go/reaction/response"
+	"github.com/core-go/reaction/save"
+	"github.com/core-go/reaction/suggest"
+	userreaction "github.com/core-go/reaction/user-reaction"
+)
+
+var (
+	_ reaction.ReactionService               = (*ReactionService)(nil)
+	_ follow.FollowService                   = (*FollowService)(nil)
+	_ rate.RateService                       = (*RateService)(nil)
+	_ rates.RatesService                     = (*RatesService)(nil)
+	_ save.SaveService                       = (*SaveService)(nil)
+	_ comment.CommentService                 = (*CommentService)(nil)
+	_ commentthread.CommentThreadService     = (*CommentThreadService)(nil)
+	_ reply.CommentService                   = (*ReplyService)(nil)
+	_ commentreaction.CommentReactionService = (*CommentReactionService)(nil)
+	_ response.ResponseService               = (*ResponseService)(nil)
+	_ userreaction.UserReactionService       = (*UserReactionService)(nil)
+	_ block.BlockService                     = (*BlockService)(nil)
+	_ suggest.SuggestService                 = (*SuggestService)(nil)
+	_ follow.TargetService                   = (*TargetService)(nil)
+	_ follow.UserInfoService                 = (*UserInfoService)(nil)
+	_ feed.FeedService                       = (*FeedService)(nil)
+)
@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/suggest"
+)
+
+// NewSuggestService returns the suggestions from the follow graph of follows, without the users blocked by guard.
+func NewSuggestService(follows *FollowService, guard block.Guard, maxCandidates int64) *SuggestService {
+	if maxCandidates <= 0 {
+		maxCandidates = 100
+	}
+	return &SuggestService{follows: follows, Guard: guard, MaxCandidates: maxCandidates}
+}
+
+type SuggestService struct {
+	follows       *FollowService
+	Guard         block.Guard
+	MaxCandidates int64
+}
+
+func (s *SuggestService) Suggest(ctx context.Context, id string, limit int64) ([]suggest.Suggestion, error) {
+	if limit <= 0 || limit > s.MaxCandidates {
+		limit = s.MaxCandidates
+	}
+	s.follows.mu.RLock()
+	scores := make(map[string]int64)
+	for friend := range s.follows.following[id] {
+		for candidate := range s.follows.following[friend] {
+			if _, ok := s.follows.following[id][candidate]; !ok && candidate != id {
+				scores[candidate]++
+			}
+		}
+	}
+	suggestions := make([]suggest.Suggestion, 0, len(scores))
+	ids := make([]string, 0, len(scores))
+	for candidate, score := range scores {
+		suggestions = append(suggestions, suggest.Suggestion{Id: candidate, Score: score, Followers: int64(len(s.follows.followers[candidate]))})
+		ids = append(ids, candidate)
+	}
+	s.follows.mu.RUnlock()
+	blocked, err := block.Blocked(ctx, s.Guard, id, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocked) > 0 {
+		visible := make([]suggest.Suggestion, 0, len(suggestions))
+		for _, suggestion := range suggestions {
+			if !blocked[suggestion.Id] {
+				visible = append(visible, suggestion)
+			}
+		}
+		suggestions = visible
+	}
+	// The candidates are the MaxCandidates users with the most overlap, the ties broken by id, as in the SQL service.
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Id < suggestions[j].Id
+	})
+	if int64(len(suggestions)) > s.MaxCandidates {
+		suggestions = suggestions[:s.MaxCandidates]
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		if suggestions[i].Followers != suggestions[j].Followers {
+			return suggestions[i].Followers > suggestions[j].Followers
+		}
+		return suggestions[i].Id < suggestions[j].Id
+	})
+	if int64(len(suggestions)) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/core-go/reaction/follow"
+)
+
+// NewTargetService returns a follow.TargetService which follows the targets of the kinds, and the users with users if it is not nil.
+func NewTargetService(users *FollowService, queryInfo func(ids []string) ([]follow.Info, error), kinds ...string) *TargetService {
+	s := &TargetService{
+		Users:     users,
+		QueryInfo: queryInfo,
+		kinds:     make(map[string]bool),
+		following: make(map[string]map[string]time.Time),
+		followers: make(map[string]map[string]time.Time),
+	}
+	for _, kind := range kinds {
+		s.kinds[kind] = true
+	}
+	return s
+}
+
+type TargetService struct {
+	Users     *FollowService
+	QueryInfo func(ids []string) ([]follow.Info, error)
+	kinds     map[string]bool
+	mu        sync.RWMutex
+	// following and followers are keyed by kind + "|" + id and kind + "|" + target.
+	following map[string]map[string]time.Time
+	followers map[string]map[string]time.Time
+}
+
+func (s *TargetService) check(kind string) error {
+	if len(kind) == 0 || !s.kinds[kind] {
+		return follow.ErrInvalidType
+	}
+	return nil
+}
+
+func (s *TargetService) FollowTarget(ctx context.Context, id string, kind string, target string) (*follow.Result, error) {
+	if kind == follow.TypeUser && s.Users != nil {
+		return s.Users.Follow(ctx, id, target)
+	}
+	if err := s.check(kind); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.following[kind+"|"+id][target]; ok {
+		return &follow.Result{Status: follow.AlreadyFollowing}, nil
+	}
+	now := time.Now()
+	put(s.following, kind+"|"+id, target, now)
+	put(s.followers, kind+"|"+target, id, now)
+	return &follow.Result{Status: follow.Followed, Count: 1}, nil
+}
+
+func (s *TargetService) UnFollowTarget(ctx context.Context, id string, kind string, target string) (*follow.Result, error) {
+	if kind == follow.TypeUser && s.Users != nil {
+		return s.Users.UnFollow(ctx, id, target)
+	}
+	if err := s.check(kind); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.following[kind+"|"+id][target]; !ok {
+		return &follow.Result{Status: follow.NotFollowing}, nil
+	}
+	delete(s.following[kind+"|"+id], target)
+	delete(s.followers[kind+"|"+target], id)
+	return &follow.Result{Status: follow.Unfollowed, Count: 1}, nil
+}
+
+func (s *TargetService) IsFollowing(ctx context.Context, id string, kind string, target string) (bool, error) {
+	if kind == follow.TypeUser && s.Users != nil {
+		r, err := s.Users.CheckFollow(ctx, id, target)
+		return r > 0, err
+	}
+	if err := s.check(kind); err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.following[kind+"|"+id][target]
+	return ok, nil
+}
+
+func (s *TargetService) FollowingTargets(ctx context.Context, id string, kind string, cursor string, limit int64) (*follow.Targets, error) {
+	var users *follow.Users
+	var err error
+	if kind == follow.TypeUser && s.Users != nil {
+		users, err = s.Users.Following(ctx, id, cursor, limit)
+	} else if err = s.check(kind); err == nil {
+		users, err = page(s.copy(s.following, kind+"|"+id), cursor, limit, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &follow.Targets{List: make([]follow.Target, 0, len(users.List)), Total: users.Total, Next: users.Next}
+	for _, u := range users.List {
+		result.List = append(result.List, follow.Target{Id: u.Id, Type: kind, Time: u.Time, Name: u.Name, Url: u.Url})
+	}
+	return result, nil
+}
+
+func (s *TargetService) TargetFollowers(ctx context.Context, kind string, target string, cursor string, limit int64) (*follow.Users, error) {
+	if kind == follow.TypeUser && s.Users != nil {
+		return s.Users.Followers(ctx, target, cursor, limit)
+	}
+	if err := s.check(kind); err != nil {
+		return nil, err
+	}
+	return page(s.copy(s.followers, kind+"|"+target), cursor, limit, s.QueryInfo)
+}
+
+// Count returns the follower count of the target of the type kind.
+func (s *TargetService) Count(kind string, target string) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return int64(len(s.followers[kind+"|"+target]))
+}
+
+func (s *TargetService) copy(m map[string]map[string]time.Time, key string) map[string]time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	times := make(map[string]time.Time, len(m[key]))
+	for k, t := range m[key] {
+		times[k] = t
+	}
+	return times
+}
@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/core-go/reaction"
+	"github.com/core-go/reaction/block"
+)
+
+func NewUserReactionService() *UserReactionService {
+	return &UserReactionService{
+		reactions: make(map[reaction.Key]int64),
+		levels:    make(map[string]map[int64]int64),
+		counts:    make(map[string]int64),
+	}
+}
+
+type UserReactionService struct {
+	Guard     block.Guard
+	mu        sync.RWMutex
+	reactions map[reaction.Key]int64
+	levels    map[string]map[int64]int64
+	counts    map[string]int64
+}
+
+func (s *UserReactionService) React(ctx context.Context, id string, author string, r string) (int64, error) {
+	if err := block.Check(ctx, s.Guard, id, author); err != nil {
+		return -1, err
+	}
+	level, err := strconv.ParseInt(r, 10, 64)
+	if err != nil {
+		return -1, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := reaction.Key{Id: id, Author: author}
+	old, exist := s.reactions[key]
+	if exist && old == level {
+		return 0, nil
+	}
+	levels, ok := s.levels[id]
+	if !ok {
+		levels = make(map[int64]int64)
+		s.levels[id] = levels
+	}
+	if exist {
+		levels[old]--
+	} else {
+		s.counts[id]++
+	}
+	levels[level]++
+	s.reactions[key] = level
+	return 2, nil
+}
+
+func (s *UserReactionService) Unreact(ctx context.Context, id string, author string, r string) (int64, error) {
+	level, err := strconv.ParseInt(r, 10, 64)
+	if err != nil {
+		return -1, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := reaction.Key{Id: id, Author: author}
+	if old, exist := s.reactions[key]; !exist || old != level {
+		return 0, nil
+	}
+	delete(s.reactions, key)
+	s.levels[id][level]--
+	s.counts[id]--
+	return 2, nil
+}
+
+func (s *UserReactionService) CheckReaction(ctx context.Context, id string, author string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	level, ok := s.reactions[reaction.Key{Id: id, Author: author}]
+	if !ok {
+		return -1, nil
+	}
+	return level, nil
+}
+
+func (s *UserReactionService) CheckReactions(ctx context.Context, author string, ids []string) (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string]int64)
+	for _, id := range ids {
+		if level, ok := s.reactions[reaction.Key{Id: id, Author: author}]; ok {
+			result[id] = level
+		}
+	}
+	return result, nil
+}
+
+// Count returns the reaction counter of id and its counter of the level.
+func (s *UserReactionService) Count(id string, level int64) (total int64, count int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.counts[id], s.levels[id][level]
+}
@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/core-go/reaction/follow"
+)
+
+// NewUserInfoService returns a follow.UserInfoService which reads the counters of follows and reactions. Both may be nil.
+func NewUserInfoService(follows *FollowService, reactions *UserReactionService) *UserInfoService {
+	return &UserInfoService{Follows: follows, Reactions: reactions}
+}
+
+type UserInfoService struct {
+	Follows   *FollowService
+	Reactions *UserReactionService
+}
+
+func (s *UserInfoService) Load(ctx context.Context, id string) (*follow.UserInfo, error) {
+	info := follow.UserInfo{Id: id}
+	if s.Follows != nil {
+		info.FollowerCount, info.FollowingCount = s.Follows.Count(id)
+	}
+	if s.Reactions != nil {
+		info.ReactionCount, info.Level1Count = s.Reactions.Count(id, 1)
+		_, info.Level2Count = s.Reactions.Count(id, 2)
+		_, info.Level3Count = s.Reactions.Count(id, 3)
+	}
+	return &info, nil
+}
+
+func (s *UserInfoService) LoadMany(ctx context.Context, ids []string) ([]follow.UserInfo, error) {
+	result := make([]follow.UserInfo, 0, len(ids))
+	for _, id := range ids {
+		info, err := s.Load(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *info)
+	}
+	return result, nil
+}
@@ -0,0 +1,239 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	TypeRateCreated      = "RateCreated"
+	TypeRateUpdated      = "RateUpdated"
+	TypeRateDeleted      = "RateDeleted"
+	TypeFollowed         = "Followed"
+	TypeUnfollowed       = "Unfollowed"
+	TypeFollowRequested  = "FollowRequested"
+	TypeTargetFollowed   = "TargetFollowed"
+	TypeTargetUnfollowed = "TargetUnfollowed"
+	TypeCommentCreated   = "CommentCreated"
+	TypeCommentUpdated   = "CommentUpdated"
+	TypeCommentDeleted   = "CommentDeleted"
+	TypeCommentReplied   = "CommentReplied"
+	TypeReactionAdded    = "ReactionAdded"
+	TypeReactionChanged  = "ReactionChanged"
+	TypeReactionRemoved  = "ReactionRemoved"
+	TypeSaved            = "Saved"
+	TypeUnsaved          = "Unsaved"
+	TypeResponseCreated  = "ResponseCreated"
+	TypeResponseUpdated  = "ResponseUpdated"
+	TypeUserReacted      = "UserReacted"
+	TypeUserUnreacted    = "UserUnreacted"
+)
+
+type Event interface {
+	EventType() string
+}
+
+// Message is an event as it is stored in the outbox table and delivered to the sink.
+type Message struct {
+	Id      string          `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+	Time    time.Time       `json:"time"`
+}
+
+// Decode unmarshals the payload of the message to the event v.
+func (m Message) Decode(v interface{}) error {
+	return json.Unmarshal(m.Payload, v)
+}
+
+type Rating struct {
+	Rate   float32   `json:"rate"`
+	Rates  []float32 `json:"rates,omitempty"`
+	Review string    `json:"review,omitempty"`
+}
+
+type RateCreated struct {
+	Id        string    `json:"id"`
+	Author    string    `json:"author"`
+	Rating    Rating    `json:"rating"`
+	Anonymous bool      `json:"anonymous,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+type RateUpdated struct {
+	Id        string    `json:"id"`
+	Author    string    `json:"author"`
+	Old       Rating    `json:"old"`
+	New       Rating    `json:"new"`
+	Anonymous bool      `json:"anonymous,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// RateDeleted is published when the rate of Author on Id is removed, with its comments and reactions.
+type RateDeleted struct {
+	Id     string    `json:"id"`
+	Author string    `json:"author"`
+	Rating Rating    `json:"rating"`
+	Time   time.Time `json:"time"`
+}
+
+type Followed struct {
+	Id     string    `json:"id"`
+	Target string    `json:"target"`
+	Time   time.Time `json:"time"`
+}
+
+type Unfollowed struct {
+	Id     string    `json:"id"`
+	Target string    `json:"target"`
+	Time   time.Time `json:"time"`
+}
+
+// FollowRequested is published when Id requests to follow Target, which approves its followers.
+type FollowRequested struct {
+	Id     string    `json:"id"`
+	Target string    `json:"target"`
+	Time   time.Time `json:"time"`
+}
+
+// TargetFollowed is published when Id follows Target, which is not a user but a topic, a place or an item of the type Type.
+type TargetFollowed struct {
+	Id     string    `json:"id"`
+	Type   string    `json:"type"`
+	Target string    `json:"target"`
+	Time   time.Time `json:"time"`
+}
+
+type TargetUnfollowed struct {
+	Id     string    `json:"id"`
+	Type   string    `json:"type"`
+	Target string    `json:"target"`
+	Time   time.Time `json:"time"`
+}
+
+// CommentCreated is published when a comment is added to a rate (Id, Author) or a comment thread is started on Id.
+type CommentCreated struct {
+	CommentId string    `json:"commentId"`
+	Id        string    `json:"id"`
+	Author    string    `json:"author"`
+	UserId    string    `json:"userId,omitempty"`
+	Comment   string    `json:"comment"`
+	Anonymous bool      `json:"anonymous,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+type CommentUpdated struct {
+	CommentId       string    `json:"commentId"`
+	CommentThreadId string    `json:"commentThreadId,omitempty"`
+	Id              string    `json:"id,omitempty"`
+	Author          string    `json:"author"`
+	Old             string    `json:"old"`
+	Comment         string    `json:"comment"`
+	Time            time.Time `json:"time"`
+}
+
+type CommentDeleted struct {
+	CommentId       string    `json:"commentId"`
+	CommentThreadId string    `json:"commentThreadId,omitempty"`
+	Id              string    `json:"id,omitempty"`
+	Author          string    `json:"author"`
+	Time            time.Time `json:"time"`
+}
+
+type CommentReplied struct {
+	CommentThreadId string    `json:"commentThreadId"`
+	CommentId       string    `json:"commentId"`
+	Id              string    `json:"id"`
+	Author          string    `json:"author"`
+	Comment         string    `json:"comment"`
+	Time            time.Time `json:"time"`
+}
+
+type ReactionAdded struct {
+	Id     string    `json:"id"`
+	Author string    `json:"author"`
+	UserId string    `json:"userId"`
+	Type   int8      `json:"type"`
+	Time   time.Time `json:"time"`
+}
+
+type ReactionChanged struct {
+	Id      string    `json:"id"`
+	Author  string    `json:"author"`
+	UserId  string    `json:"userId"`
+	OldType int8      `json:"oldType"`
+	Type    int8      `json:"type"`
+	Time    time.Time `json:"time"`
+}
+
+type ReactionRemoved struct {
+	Id     string    `json:"id"`
+	Author string    `json:"author"`
+	UserId string    `json:"userId"`
+	Type   int8      `json:"type"`
+	Time   time.Time `json:"time"`
+}
+
+type Saved struct {
+	Id   string    `json:"id"`
+	Item string    `json:"item"`
+	Time time.Time `json:"time"`
+}
+
+type Unsaved struct {
+	Id   string    `json:"id"`
+	Item string    `json:"item"`
+	Time time.Time `json:"time"`
+}
+
+type ResponseCreated struct {
+	Id          string    `json:"id"`
+	Author      string    `json:"author"`
+	Description string    `json:"description"`
+	Time        time.Time `json:"time"`
+}
+
+type ResponseUpdated struct {
+	Id          string    `json:"id"`
+	Author      string    `json:"author"`
+	Old         string    `json:"old"`
+	Description string    `json:"description"`
+	Time        time.Time `json:"time"`
+}
+
+type UserReacted struct {
+	Id       string    `json:"id"`
+	Author   string    `json:"author"`
+	Reaction int64     `json:"reaction"`
+	Old      int64     `json:"old,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+type UserUnreacted struct {
+	Id       string    `json:"id"`
+	Author   string    `json:"author"`
+	Reaction int64     `json:"reaction"`
+	Time     time.Time `json:"time"`
+}
+
+func (e RateCreated) EventType() string      { return TypeRateCreated }
+func (e RateUpdated) EventType() string      { return TypeRateUpdated }
+func (e RateDeleted) EventType() string      { return TypeRateDeleted }
+func (e Followed) EventType() string         { return TypeFollowed }
+func (e Unfollowed) EventType() string       { return TypeUnfollowed }
+func (e FollowRequested) EventType() string  { return TypeFollowRequested }
+func (e TargetFollowed) EventType() string   { return TypeTargetFollowed }
+func (e TargetUnfollowed) EventType() string { return TypeTargetUnfollowed }
+func (e CommentCreated) EventType() string   { return TypeCommentCreated }
+func (e CommentUpdated) EventType() string   { return TypeCommentUpdated }
+func (e CommentDeleted) EventType() string   { return TypeCommentDeleted }
+func (e CommentReplied) EventType() string   { return TypeCommentReplied }
+func (e ReactionAdded) EventType() string    { return TypeReactionAdded }
+func (e ReactionChanged) EventType() string  { return TypeReactionChanged }
+func (e ReactionRemoved) EventType() string  { return TypeReactionRemoved }
+func (e Saved) EventType() string            { return TypeSaved }
+func (e Unsaved) EventType() string          { return TypeUnsaved }
+func (e ResponseCreated) EventType() string  { return TypeResponseCreated }
+func (e ResponseUpdated) EventType() string  { return TypeResponseUpdated }
+func (e UserReacted) EventType() string      { return TypeUserReacted }
+func (e UserUnreacted) EventType() string    { return TypeUserUnreacted }
@@ -0,0 +1,66 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/core-go/reaction/dialect"
+	"github.com/google/uuid"
+)
+
+// Publisher publishes the event of a state change inside tx, the transaction of the state change.
+type Publisher interface {
+	Publish(ctx context.Context, tx *sql.Tx, event Event) error
+}
+
+// Publish publishes the event with all publishers, and stops at the first error.
+func Publish(ctx context.Context, tx *sql.Tx, publishers []Publisher, event Event) error {
+	for _, p := range publishers {
+		if err := p.Publish(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewOutbox returns the publisher which writes the events to the outbox table, to be delivered later by a Relay.
+func NewOutbox(db *sql.DB, table string, idCol string, typeCol string, payloadCol string, timeCol string) Publisher {
+	return &outbox{
+		DB:         db,
+		Table:      table,
+		IdCol:      idCol,
+		TypeCol:    typeCol,
+		PayloadCol: payloadCol,
+		TimeCol:    timeCol,
+		Dialect:    dialect.New(db),
+	}
+}
+
+type outbox struct {
+	DB         *sql.DB
+	Table      string
+	IdCol      string
+	TypeCol    string
+	PayloadCol string
+	TimeCol    string
+	Dialect    dialect.Dialect
+}
+
+func (s *outbox) Publish(ctx context.Context, tx *sql.Tx, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	query := s.Dialect.Rebind(fmt.Sprintf("insert into %s(%s, %s, %s, %s) values (?, ?, ?, ?)",
+		s.Table, s.IdCol, s.TypeCol, s.PayloadCol, s.TimeCol))
+	params := []interface{}{uuid.New().String(), event.EventType(), string(payload), time.Now()}
+	if tx == nil {
+		_, err = s.DB.ExecContext(ctx, query, params...)
+	} else {
+		_, err = tx.ExecContext(ctx, query, params...)
+	}
+	return err
+}
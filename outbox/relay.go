@@ -0,0 +1,155 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/core-go/reaction/dialect"
+)
+
+// Sink receives the messages of the outbox. Send must be idempotent, because a message is delivered at least once.
+type Sink interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// NewRelay returns the relay which delivers the messages of the outbox table to each of the sinks, in the order they were written,
+// such as the feed service and a message broker. Only one relay should run per outbox table.
+func NewRelay(db *sql.DB, table string, idCol string, typeCol string, payloadCol string, timeCol string, batchSize int64, sinks ...Sink) *Relay {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &Relay{
+		DB:         db,
+		Table:      table,
+		IdCol:      idCol,
+		TypeCol:    typeCol,
+		PayloadCol: payloadCol,
+		TimeCol:    timeCol,
+		Sinks:      sinks,
+		BatchSize:  batchSize,
+		Dialect:    dialect.New(db),
+	}
+}
+
+type Relay struct {
+	DB         *sql.DB
+	Table      string
+	IdCol      string
+	TypeCol    string
+	PayloadCol string
+	TimeCol    string
+	Sinks      []Sink
+	BatchSize  int64
+	Dialect    dialect.Dialect
+}
+
+// Relay delivers a batch of messages, and removes them from the outbox table once delivered to all sinks.
+// It stops at the first message a sink fails to receive, so that the next call retries it, with all sinks.
+func (r *Relay) Relay(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf("select %s, %s, %s, %s from %s order by %s, %s %s",
+		r.IdCol, r.TypeCol, r.PayloadCol, r.TimeCol, r.Table, r.TimeCol, r.IdCol, r.Dialect.Limit(r.BatchSize))
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	var messages []Message
+	for rows.Next() {
+		var m Message
+		var payload string
+		if err = rows.Scan(&m.Id, &m.Type, &payload, &m.Time); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		m.Payload = []byte(payload)
+		messages = append(messages, m)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	remove := r.Dialect.Rebind(fmt.Sprintf("delete from %s where %s = ?", r.Table, r.IdCol))
+	var count int64
+	for _, m := range messages {
+		for _, sink := range r.Sinks {
+			if err = sink.Send(ctx, m); err != nil {
+				return count, err
+			}
+		}
+		if _, err = r.DB.ExecContext(ctx, remove, m.Id); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Run relays the messages every interval until ctx is done.
+// Errors are passed to onError if it is not nil, and the relay goes on at the next interval.
+func (r *Relay) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			count, err := r.Relay(ctx)
+			if err != nil && onError != nil {
+				onError(err)
+			}
+			if err != nil || count < r.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// MemorySink keeps the messages it receives in memory, for tests.
+type MemorySink struct {
+	mu       sync.RWMutex
+	messages []Message
+}
+
+func (s *MemorySink) Send(ctx context.Context, message Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// Messages returns the received messages of the types, or all received messages if types is empty.
+func (s *MemorySink) Messages(types ...string) []Message {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	messages := make([]Message, 0)
+	for _, m := range s.messages {
+		if len(types) == 0 || contains(types, m.Type) {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// failingSink fails to receive the messages while err is set.
+type failingSink struct {
+	MemorySink
+	err error
+}
+
+func (s *failingSink) Send(ctx context.Context, message Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.MemorySink.Send(ctx, message)
+}
+
+func TestRelaySinks(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	if _, err = db.Exec("create table outbox (id varchar(40) primary key, type varchar(40), payload text, time timestamp)"); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	publisher := NewOutbox(db, "outbox", "id", "type", "payload", "time")
+	now := time.Now()
+	events := []Event{Followed{Id: "a", Target: "b", Time: now}, Followed{Id: "a", Target: "c", Time: now}}
+	for _, event := range events {
+		if err = publisher.Publish(ctx, nil, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, second := NewMemorySink(), &failingSink{err: errors.New("unavailable")}
+	relay := NewRelay(db, "outbox", "id", "type", "payload", "time", 10, first, second)
+	if count, err := relay.Relay(ctx); err != second.err || count != 0 {
+		t.Fatalf("Relay() with a failing sink = %d, %v, want 0 and %v", count, err, second.err)
+	}
+	second.err = nil
+	if count, err := relay.Relay(ctx); err != nil || count != 2 {
+		t.Fatalf("Relay() = %d, %v, want 2", count, err)
+	}
+	// The message which the second sink failed to receive is delivered again to the first one.
+	if n := len(first.Messages()); n != 3 {
+		t.Errorf("first sink received %d messages, want 3", n)
+	}
+	if n := len(second.Messages()); n != 2 {
+		t.Errorf("second sink received %d messages, want 2", n)
+	}
+	if count, err := relay.Relay(ctx); err != nil || count != 0 {
+		t.Errorf("Relay() of an empty outbox = %d, %v, want 0", count, err)
+	}
+}
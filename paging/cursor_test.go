@@ -0,0 +1,19 @@
+package paging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 30, 0, 123456789, time.UTC)
+	got, id, err := DecodeCursor(EncodeCursor(now, "a|b"))
+	if err != nil || !got.Equal(now) || id != "a|b" {
+		t.Errorf("DecodeCursor(EncodeCursor()) = %v, %q, %v, want %v and a|b", got, id, err, now)
+	}
+	for _, cursor := range []string{"!", EncodeCursor(now, "")[:4], "bm90IGEgdGltZXxh"} {
+		if _, _, err = DecodeCursor(cursor); err != ErrInvalidCursor {
+			t.Errorf("DecodeCursor(%q) = %v, want %v", cursor, err, ErrInvalidCursor)
+		}
+	}
+}
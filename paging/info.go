@@ -0,0 +1,108 @@
+// Package paging has the helpers shared by the lists of the services: the cursors of their pages and the infos of their users.
+package paging
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sort"
+
+	"github.com/core-go/reaction/dialect"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	_ "unicode/utf8"
+)
+
+var collator = collate.New(language.Und)
+
+// Info is the url and the name of a user of a list, loaded by the QueryInfo of the services.
+type Info struct {
+	Id   string `json:"id,omitempty" gorm:"column:id;primary_key"`
+	Url  string `json:"url,omitempty" gorm:"column:url"`
+	Name string `json:"name,omitempty" gorm:"column:name"`
+}
+
+type queryInfo struct {
+	db      *sql.DB
+	toArray func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	}
+	table       string
+	url         string
+	id          string
+	name        string
+	displayName string
+	dialect     dialect.Dialect
+}
+
+func NewQueryInfo(db *sql.DB, table string, url string, id string, name string, displayName string, toArray func(interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+}) queryInfo {
+	return queryInfo{db: db, table: table, url: url, id: id, name: name, displayName: displayName, toArray: toArray, dialect: dialect.New(db)}
+}
+
+// Load returns the infos of ids, sorted by id for BinarySearch.
+func (i queryInfo) Load(ids []string) ([]Info, error) {
+	rs := make([]Info, 0)
+	if len(ids) == 0 {
+		return rs, nil
+	}
+	ids = Distinct(ids)
+	in, params := i.dialect.InArray(i.id, ids, i.toArray)
+	querysql := i.dialect.Rebind(fmt.Sprintf(`select %s as id, %s as url, COALESCE(%s,%s) as name from %s where %s and %s is not null order by %s`,
+		i.id, i.url, i.displayName, i.name, i.table, in, i.url, i.id))
+	r := make([]Info, 0)
+	rows, err := i.db.Query(querysql, params...)
+	if err != nil {
+		return rs, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var info Info
+		err := rows.Scan(&info.Id, &info.Url, &info.Name)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, info)
+	}
+	return r, nil
+}
+
+func BinarySearch(ar []Info, el string) int {
+	m := 0
+	n := len(ar) - 1
+
+	for m <= n {
+		k := (n + m) >> 1
+		cmp := compare(el, ar[k].Id)
+		if cmp > 0 {
+			m = k + 1
+		} else if cmp < 0 {
+			n = k - 1
+		} else {
+			return k
+		}
+	}
+	return -m - 1
+}
+
+// Distinct sorts arr and returns its distinct elements.
+func Distinct(arr []string) []string {
+	// Sort the input array
+	sort.Strings(arr)
+	// Create a new array to store distinct elements
+	distinctArr := make([]string, 0, len(arr))
+	// Iterate through the sorted array and append only distinct elements to the new array
+	for i := 0; i < len(arr); i++ {
+		if i == 0 || arr[i] != arr[i-1] {
+			distinctArr = append(distinctArr, arr[i])
+		}
+	}
+	return distinctArr
+}
+
+func compare(a, b string) int {
+	return collator.CompareString(a, b)
+}
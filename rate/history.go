@@ -0,0 +1,37 @@
+package rate
+
+import "errors"
+
+var (
+	ErrNotFound       = errors.New("rate not found")
+	ErrInvalidVersion = errors.New("invalid version")
+)
+
+// History is the timeline of the rate of an author on an item, from the first version to the current one.
+type History struct {
+	Id        string      `json:"id,omitempty"`
+	Author    string      `json:"author,omitempty"`
+	Anonymous bool        `json:"anonymous,omitempty"`
+	Versions  []Histories `json:"versions,omitempty"`
+}
+
+// ToHistory returns the timeline of the rate, whose last version is the current rate and review.
+func ToHistory(rate Rate) History {
+	versions := make([]Histories, 0, len(rate.Histories)+1)
+	versions = append(versions, rate.Histories...)
+	versions = append(versions, Histories{Time: rate.Time, Rate: rate.Rate, Review: rate.Review})
+	return History{Id: rate.Id, Author: rate.Author, Anonymous: rate.Anonymous, Versions: versions}
+}
+
+// ToRevert returns the request which restores the version at index of the history of the rate, or ErrNotFound if rate is nil.
+func ToRevert(rate *Rate, index int) (Request, error) {
+	if rate == nil {
+		return Request{}, ErrNotFound
+	}
+	history := ToHistory(*rate)
+	if index < 0 || index >= len(history.Versions) {
+		return Request{}, ErrInvalidVersion
+	}
+	version := history.Versions[index]
+	return Request{Rate: version.Rate, Review: version.Review, Anonymous: history.Anonymous}, nil
+}
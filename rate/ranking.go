@@ -0,0 +1,54 @@
+package rate
+
+import "math"
+
+// Ranking maintains the ranking scores of the rate info table, so that the rated items can be sorted by them.
+// An item with a few high rates is ranked below an item with many slightly lower rates.
+type Ranking struct {
+	// BayesianCol is the column of the Bayesian average. It is not maintained if it is empty.
+	BayesianCol string
+	// WilsonCol is the column of the Wilson lower bound. It is not maintained if it is empty.
+	WilsonCol string
+	// Prior is the average assumed for an item without rates, and Weight is the number of rates it is worth.
+	Prior  float64
+	Weight float64
+	// Z is the z-score of the confidence of the Wilson lower bound, 1.96 for 95% if it is zero.
+	Z float64
+}
+
+// Bayesian returns the average of the rates, pulled towards prior by weight rates.
+func Bayesian(count int, score float64, prior float64, weight float64) float64 {
+	if float64(count)+weight <= 0 {
+		return 0
+	}
+	return (prior*weight + score) / (weight + float64(count))
+}
+
+// Wilson returns the lower bound of the Wilson score interval of the histogram, whose buckets are ordered from the lowest rate.
+// A rate of the bucket i counts as i / (len(histogram) - 1) of a positive vote, so the result is between 0 and 1.
+func Wilson(histogram []int, z float64) float64 {
+	if len(histogram) <= 1 {
+		return 0
+	}
+	n, positive := 0.0, 0.0
+	for i, c := range histogram {
+		n += float64(c)
+		positive += float64(c) * float64(i) / float64(len(histogram)-1)
+	}
+	if n <= 0 {
+		return 0
+	}
+	p := positive / n
+	z2 := z * z
+	return (p + z2/(2*n) - z*math.Sqrt((p*(1-p)+z2/(4*n))/n)) / (1 + z2/n)
+}
+
+// Rank sets the ranking scores of info.
+func (r Ranking) Rank(info *RateInfo) {
+	z := r.Z
+	if z <= 0 {
+		z = 1.96
+	}
+	info.Bayesian = Bayesian(info.Count, info.Score, r.Prior, r.Weight)
+	info.Wilson = Wilson(info.Histogram, z)
+}
@@ -8,15 +8,15 @@ import (
 )
 
 type Request struct {
-	Rate      int    `json:"rate,omitempty" gorm:"column:rate" bson:"rate,omitempty" dynamodbav:"rate,omitempty" firestore:"rate,omitempty" validate:"required,max=10"`
-	Review    string `json:"review,omitempty" gorm:"column:review" bson:"review,omitempty" dynamodbav:"review,omitempty" firestore:"review,omitempty"`
-	Anonymous bool   `json:"anonymous,omitempty" gorm:"column:anonymous" bson:"anonymous,omitempty" dynamodbav:"anonymous,omitempty" firestore:"anonymous,omitempty"`
+	Rate      float32 `json:"rate,omitempty" gorm:"column:rate" bson:"rate,omitempty" dynamodbav:"rate,omitempty" firestore:"rate,omitempty" validate:"required"`
+	Review    string  `json:"review,omitempty" gorm:"column:review" bson:"review,omitempty" dynamodbav:"review,omitempty" firestore:"review,omitempty"`
+	Anonymous bool    `json:"anonymous,omitempty" gorm:"column:anonymous" bson:"anonymous,omitempty" dynamodbav:"anonymous,omitempty" firestore:"anonymous,omitempty"`
 }
 
 type Rate struct {
 	Id          string      `json:"id,omitempty" gorm:"column:id;primary_key" bson:"id,omitempty" dynamodbav:"id,omitempty" firestore:"id,omitempty" validate:"required,max=255"`
 	Author      string      `json:"author,omitempty" gorm:"column:author;primary_key" bson:"author,omitempty" dynamodbav:"author,omitempty" firestore:"author,omitempty" validate:"required,max=255"`
-	Rate        int         `json:"rate,omitempty" gorm:"column:rate" bson:"rate,omitempty" dynamodbav:"rate,omitempty" firestore:"rate,omitempty" validate:"required,max=10"`
+	Rate        float32     `json:"rate,omitempty" gorm:"column:rate" bson:"rate,omitempty" dynamodbav:"rate,omitempty" firestore:"rate,omitempty" validate:"required"`
 	Review      string      `json:"review,omitempty" gorm:"column:review" bson:"review,omitempty" dynamodbav:"review,omitempty" firestore:"review,omitempty"`
 	Time        *time.Time  `json:"time,omitempty" gorm:"column:time" bson:"time,omitempty" dynamodbav:"time,omitempty" firestore:"time,omitempty"`
 	UsefulCount int         `json:"usefulCount,omitempty" gorm:"column:usefulCount" bson:"usefulCount,omitempty" dynamodbav:"usefulCount,omitempty" firestore:"usefulCount,omitempty"`
@@ -26,25 +26,20 @@ type Rate struct {
 }
 
 type RateInfo struct {
-	Id     string  `gorm:"column:id;primary_key" validate:"required,max=255"`
-	Rate   float32 `gorm:"column:rate;"`
-	Rate1  int     `gorm:"column:rate1;"`
-	Rate2  int     `gorm:"column:rate2;"`
-	Rate3  int     `gorm:"column:rate3;"`
-	Rate4  int     `gorm:"column:rate4;"`
-	Rate5  int     `gorm:"column:rate5;"`
-	Rate6  int     `gorm:"column:rate6;"`
-	Rate7  int     `gorm:"column:rate7;"`
-	Rate8  int     `gorm:"column:rate8;"`
-	Rate9  int     `gorm:"column:rate9;"`
-	Rate10 int     `gorm:"column:rate10;"`
-	Count  int     `gorm:"column:count;"`
-	Score  int     `gorm:"column:score;"`
+	Id    string  `gorm:"column:id;primary_key" validate:"required,max=255"`
+	Rate  float32 `gorm:"column:rate;"`
+	Count int     `gorm:"column:count;"`
+	Score float64 `gorm:"column:score;"`
+	// Histogram has the number of rates of each bucket of the Scale, whether they are stored in columns or in one column.
+	Histogram []int `gorm:"column:histogram;"`
+	// Bayesian and Wilson are the ranking scores, see Ranking.
+	Bayesian float64 `gorm:"column:bayesian;"`
+	Wilson   float64 `gorm:"column:wilson;"`
 }
 
 type Histories struct {
 	Time   *time.Time `json:"time,omitempty" gorm:"column:time" bson:"time,omitempty" dynamodbav:"time,omitempty" firestore:"time,omitempty" validate:"required"`
-	Rate   int        `json:"rate,omitempty" gorm:"column:rate" bson:"rate,omitempty" dynamodbav:"rate,omitempty" firestore:"rate,omitempty" validate:"required"`
+	Rate   float32    `json:"rate,omitempty" gorm:"column:rate" bson:"rate,omitempty" dynamodbav:"rate,omitempty" firestore:"rate,omitempty" validate:"required"`
 	Review string     `json:"review,omitempty" gorm:"column:review" bson:"review,omitempty" dynamodbav:"review,omitempty" firestore:"review,omitempty"`
 }
 
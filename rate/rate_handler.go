@@ -8,17 +8,23 @@ import (
 	"strings"
 )
 
+// NewRateHandler returns the rate handler. viewer returns the user of the request, and isModerator returns whether the user of the request is a moderator.
+// If they are nil, nobody can revert or remove a rate, or see the history of an anonymous rate.
 func NewRateHandler(
 	service RateService,
 	authorIndex int,
 	idIndex int,
-	max int,
+	scale Scale,
+	viewer func(r *http.Request) string,
+	isModerator func(r *http.Request) bool,
 ) Handler {
 	return Handler{
 		service:     service,
 		authorIndex: authorIndex,
 		idIndex:     idIndex,
-		max:         max,
+		scale:       scale,
+		viewer:      viewer,
+		isModerator: isModerator,
 	}
 }
 
@@ -26,7 +32,10 @@ type Handler struct {
 	service     RateService
 	authorIndex int
 	idIndex     int
-	max         int
+	scale       Scale
+	// The history of an anonymous rate is returned to its author and to the moderators only, and only they can revert or remove a rate.
+	viewer      func(r *http.Request) string
+	isModerator func(r *http.Request) bool
 }
 
 func (h *Handler) Rate(w http.ResponseWriter, r *http.Request) {
@@ -36,7 +45,7 @@ func (h *Handler) Rate(w http.ResponseWriter, r *http.Request) {
 	id := GetRequiredParam(w, r, h.idIndex)
 
 	if er1 == nil {
-		errors := Validate(r.Context(), rate, h.max)
+		errors := Validate(r.Context(), rate, h.scale)
 		if len(errors) > 0 {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(422)
@@ -45,6 +54,10 @@ func (h *Handler) Rate(w http.ResponseWriter, r *http.Request) {
 		}
 		result, er3 := h.service.Rate(r.Context(), id, author, rate)
 		if er3 != nil {
+			if er3 == ErrInvalidRate {
+				http.Error(w, er3.Error(), http.StatusBadRequest)
+				return
+			}
 			http.Error(w, er3.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -54,6 +67,98 @@ func (h *Handler) Rate(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Remove removes the rate of the author at authorIndex on the item at idIndex, with its comments and reactions.
+// Only the author and the moderators can remove it.
+func (h *Handler) Remove(w http.ResponseWriter, r *http.Request) {
+	author := GetRequiredParam(w, r, h.authorIndex)
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 || len(author) == 0 {
+		return
+	}
+	if !h.isAuthorOrModerator(r, author) {
+		http.Error(w, "only the author or a moderator can remove the rate", http.StatusForbidden)
+		return
+	}
+	result, err := h.service.Remove(r.Context(), id, author)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result == 0 {
+		http.Error(w, "rate not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+// History returns the versions of the rate of the author at authorIndex on the item at idIndex, the current version last.
+func (h *Handler) History(w http.ResponseWriter, r *http.Request) {
+	author := GetRequiredParam(w, r, h.authorIndex)
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 || len(author) == 0 {
+		return
+	}
+	result, err := h.service.History(r.Context(), id, author)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result == nil {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if result.Anonymous && !h.isAuthorOrModerator(r, author) {
+		http.Error(w, "the rate is anonymous", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *Handler) isAuthorOrModerator(r *http.Request, author string) bool {
+	if h.isModerator != nil && h.isModerator(r) {
+		return true
+	}
+	return h.viewer != nil && h.viewer(r) == author
+}
+
+// Revert restores the version at the query parameter index of the history of the rate of the author at authorIndex on the item at idIndex.
+// Only the author and the moderators can revert it.
+func (h *Handler) Revert(w http.ResponseWriter, r *http.Request) {
+	author := GetRequiredParam(w, r, h.authorIndex)
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 || len(author) == 0 {
+		return
+	}
+	if !h.isAuthorOrModerator(r, author) {
+		http.Error(w, "only the author or a moderator can revert the rate", http.StatusForbidden)
+		return
+	}
+	index, err := strconv.Atoi(r.URL.Query().Get("index"))
+	if err != nil {
+		http.Error(w, "invalid index", http.StatusBadRequest)
+		return
+	}
+	result, err := h.service.Revert(r.Context(), id, author, index)
+	if err != nil {
+		switch err {
+		case ErrNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case ErrInvalidVersion, ErrInvalidRate:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
 func GetRequiredParam(w http.ResponseWriter, r *http.Request, options ...int) string {
 	p := GetParam(r, options...)
 	if len(p) == 0 {
@@ -95,14 +200,30 @@ func Decode(w http.ResponseWriter, r *http.Request, obj interface{}, options ...
 	return nil
 }
 
-func Validate(ctx context.Context, rate Request, max int) []ErrorMessage {
+func Validate(ctx context.Context, rate Request, scale Scale) []ErrorMessage {
 	var errors []ErrorMessage
-	if rate.Rate > max {
+	if rate.Rate < scale.Min {
+		errors = append(errors, ErrorMessage{
+			Field: "rate",
+			Code:  "min",
+			Param: formatRate(scale.Min),
+		})
+	} else if rate.Rate > scale.Max {
 		errors = append(errors, ErrorMessage{
 			Field: "rate",
 			Code:  "max",
-			Param: strconv.Itoa(max),
+			Param: formatRate(scale.Max),
+		})
+	} else if scale.Validate(rate.Rate) != nil {
+		errors = append(errors, ErrorMessage{
+			Field: "rate",
+			Code:  "step",
+			Param: formatRate(scale.step()),
 		})
 	}
 	return errors
 }
+
+func formatRate(rate float32) string {
+	return strconv.FormatFloat(float64(rate), 'f', -1, 32)
+}
@@ -0,0 +1,105 @@
+package rate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRevertAuthorization(t *testing.T) {
+	_, service := newTestService(t, NewScale(5))
+	ctx := context.Background()
+	for _, rate := range []float32{2, 4} {
+		if _, err := service.Rate(ctx, "item", "author", Request{Rate: rate}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	viewer := func(r *http.Request) string {
+		return r.Header.Get("user")
+	}
+	isModerator := func(r *http.Request) bool {
+		return r.Header.Get("moderator") == "true"
+	}
+	handler := NewRateHandler(service, 0, 1, NewScale(5), viewer, isModerator)
+	tests := []struct {
+		name      string
+		user      string
+		moderator bool
+		index     string
+		want      int
+	}{
+		{"another user", "other", false, "0", http.StatusForbidden},
+		{"anonymous request", "", false, "0", http.StatusForbidden},
+		{"author", "author", false, "0", http.StatusOK},
+		{"invalid version", "author", false, "9", http.StatusBadRequest},
+		{"moderator", "moderator", true, "1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/rates/item/author?index="+tt.index, nil)
+		r.Header.Set("user", tt.user)
+		if tt.moderator {
+			r.Header.Set("moderator", "true")
+		}
+		w := httptest.NewRecorder()
+		handler.Revert(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: Revert() = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+	rate, err := service.Load(ctx, "item", "author")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The author reverted to 2, then the moderator reverted to the version 1, which is 4.
+	if rate.Rate != 4 || len(rate.Histories) != 3 {
+		t.Errorf("Load() = rate %v with %d histories, want 4 with 3", rate.Rate, len(rate.Histories))
+	}
+}
+
+func TestRemoveAuthorization(t *testing.T) {
+	_, service := newTestService(t, NewScale(5))
+	ctx := context.Background()
+	for _, author := range []string{"author", "other"} {
+		if _, err := service.Rate(ctx, "item", author, Request{Rate: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	viewer := func(r *http.Request) string {
+		return r.Header.Get("user")
+	}
+	isModerator := func(r *http.Request) bool {
+		return r.Header.Get("moderator") == "true"
+	}
+	handler := NewRateHandler(service, 0, 1, NewScale(5), viewer, isModerator)
+	tests := []struct {
+		name      string
+		user      string
+		moderator bool
+		author    string
+		want      int
+	}{
+		{"another user", "other", false, "author", http.StatusForbidden},
+		{"anonymous request", "", false, "author", http.StatusForbidden},
+		{"author", "author", false, "author", http.StatusOK},
+		{"already removed", "author", false, "author", http.StatusNotFound},
+		{"moderator", "moderator", true, "other", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodDelete, "/rates/item/"+tt.author, nil)
+		r.Header.Set("user", tt.user)
+		if tt.moderator {
+			r.Header.Set("moderator", "true")
+		}
+		w := httptest.NewRecorder()
+		handler.Remove(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: Remove() = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+	for _, author := range []string{"author", "other"} {
+		if rate, err := service.Load(ctx, "item", author); err != nil || rate != nil {
+			t.Errorf("Load(%s) = %v, %v, want no rate", author, rate, err)
+		}
+	}
+}
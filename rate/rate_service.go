@@ -5,11 +5,63 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
 )
 
 type RateService interface {
 	Load(ctx context.Context, id string, author string) (*Rate, error)
 	Rate(ctx context.Context, id string, author string, req Request) (int64, error)
+	// Remove deletes the rate of author on id, with its reactions and comments, and rolls back the rate info of id.
+	Remove(ctx context.Context, id string, author string) (int64, error)
+	// History returns the previous versions and the current version of the rate of author on id, or nil if author did not rate id.
+	History(ctx context.Context, id string, author string) (*History, error)
+	// Revert rates id again with the version at index of the history, so that the rate info is updated as by Rate.
+	Revert(ctx context.Context, id string, author string, index int) (int64, error)
+}
+
+// Option configures the optional features of the rate service.
+type Option func(*rateService)
+
+// WithScale sets the scale of the rates, NewScale(5) by default.
+func WithScale(scale Scale) Option {
+	return func(s *rateService) {
+		s.Scale = scale
+	}
+}
+
+// WithReactions sets the table of the reactions on the rates, which Remove deletes.
+func WithReactions(table string, idCol string, authorCol string) Option {
+	return func(s *rateService) {
+		s.ReactionTable = table
+		s.ReactionIdCol = idCol
+		s.ReactionAuthorCol = authorCol
+	}
+}
+
+// WithComments sets the table of the comments on the rates, which Remove deletes.
+func WithComments(table string, idCol string, rateIdCol string, rateAuthorCol string) Option {
+	return func(s *rateService) {
+		s.CommentTable = table
+		s.CommentIdCol = idCol
+		s.CommentRateIdCol = rateIdCol
+		s.CommentRateAuthorCol = rateAuthorCol
+	}
+}
+
+func WithRanking(ranking *Ranking) Option {
+	return func(s *rateService) {
+		s.Ranking = ranking
+	}
+}
+
+func WithPublishers(publishers ...outbox.Publisher) Option {
+	return func(s *rateService) {
+		s.Publishers = append(s.Publishers, publishers...)
+	}
 }
 
 func NewRateService(
@@ -32,8 +84,9 @@ func NewRateService(
 		driver.Valuer
 		sql.Scanner
 	},
+	options ...Option,
 ) RateService {
-	return &rateService{
+	s := &rateService{
 		DB:             db,
 		RateTable:      rateTable,
 		IdCol:          idCol,
@@ -49,8 +102,14 @@ func NewRateService(
 		InfoRateCol:    infoRateCol,
 		RateCountCol:   rateCountCol,
 		RateScoreCol:   rateScoreCol,
+		Scale:          NewScale(5),
 		ToArray:        toArray,
+		Dialect:        dialect.New(db),
 	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
 }
 
 type rateService struct {
@@ -70,76 +129,439 @@ type rateService struct {
 	InfoRateCol    string
 	RateCountCol   string
 	RateScoreCol   string
-	ToArray        func(interface{}) interface {
+	// Scale defines the valid rates and the histogram of InfoTable.
+	Scale Scale
+	// ReactionTable and CommentTable are the tables of the reactions and the comments on the rates, which Remove deletes.
+	// Remove skips a table which is empty.
+	ReactionTable        string
+	ReactionIdCol        string
+	ReactionAuthorCol    string
+	CommentTable         string
+	CommentIdCol         string
+	CommentRateIdCol     string
+	CommentRateAuthorCol string
+	// Ranking maintains the ranking scores of InfoTable, if it is not nil.
+	Ranking *Ranking
+	ToArray func(interface{}) interface {
 		driver.Valuer
 		sql.Scanner
 	}
+	Dialect    dialect.Dialect
+	Publishers []outbox.Publisher
 }
 
 func (s *rateService) Load(ctx context.Context, id string, author string) (*Rate, error) {
-	query := fmt.Sprintf("select %s, %s, %s, %s, %s, %s, %s, histories from %s where %s = $1 and %s = $2 limit 1",
-		s.IdCol, s.AuthorCol, s.RateCol, s.ReviewCol, s.TimeCol, s.UsefulCountCol, s.ReplyCountCol, s.RateTable, s.IdCol, s.AuthorCol)
-	rows, err := s.DB.QueryContext(ctx, query, id, author)
+	return s.load(ctx, s.DB, id, author, false)
+}
+
+// load returns the rate of the author on id. If lock is true, the rate is locked until the end of the transaction db.
+func (s *rateService) load(ctx context.Context, db interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}, id string, author string, lock bool) (*Rate, error) {
+	columns := []string{s.IdCol, s.AuthorCol, s.AnonymousCol, s.RateCol, s.ReviewCol, s.TimeCol, s.UsefulCountCol, s.ReplyCountCol, "histories"}
+	where := fmt.Sprintf("%s = ? and %s = ?", s.IdCol, s.AuthorCol)
+	query := fmt.Sprintf("select %s from %s where %s", strings.Join(columns, ", "), s.RateTable, where)
+	if lock {
+		query = s.Dialect.ForUpdate(columns, s.RateTable, where)
+	}
+	rows, err := db.QueryContext(ctx, s.Dialect.Rebind(query), id, author)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var rate Rate
-		err = rows.Scan(&rate.Id, &rate.Author, &rate.Rate, &rate.Review, &rate.Time, &rate.UsefulCount, &rate.ReplyCount, s.ToArray(&rate.Histories))
+		err = rows.Scan(&rate.Id, &rate.Author, &rate.Anonymous, &rate.Rate, &rate.Review, &rate.Time, &rate.UsefulCount, &rate.ReplyCount, s.ToArray(&rate.Histories))
 		if err != nil {
 			return nil, err
 		}
 		return &rate, nil
 	}
-	return nil, nil
+	return nil, rows.Err()
 }
 
+// Rate creates or updates the rate of the author on id. The existing rate is locked, so that concurrent rates of the same author are counted once in the rate info.
 func (s *rateService) Rate(ctx context.Context, id string, author string, req Request) (int64, error) {
-	var rate = Rate{Id: id, Author: author, Review: req.Review, Rate: req.Rate, Anonymous: req.Anonymous}
-	oldRate, _ := s.Load(ctx, rate.Id, rate.Author)
-	query1 := fmt.Sprintf("insert into %s(%s, %s, %s%d, %s, %s) values ($1, %d, 1, 1, %d) on conflict (%s) do update set ",
-		s.InfoTable, s.InfoIdCol, s.InfoRateCol, s.InfoRateCol, rate.Rate, s.RateCountCol, s.RateScoreCol, rate.Rate, rate.Rate, s.InfoIdCol)
+	if err := s.Scale.Validate(req.Rate); err != nil {
+		return -1, err
+	}
+	return s.rate(ctx, id, author, func(*Rate) (Request, error) {
+		return req, nil
+	})
+}
+
+// rate sets the rate of the author on id to the request returned by next, from the existing rate locked in the transaction, or nil.
+func (s *rateService) rate(ctx context.Context, id string, author string, next func(old *Rate) (Request, error)) (int64, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+	oldRate, err := s.load(ctx, tx, id, author, true)
+	if err != nil {
+		return -1, err
+	}
+	req, err := next(oldRate)
+	if err != nil {
+		return -1, err
+	}
+	now := time.Now()
+	var rate = Rate{Id: id, Author: author, Review: req.Review, Rate: req.Rate, Time: &now, Anonymous: req.Anonymous}
+	var r int64
+	if oldRate == nil {
+		// The insert is ignored if the author rated id after the select, and the rate is then updated as an existing one.
+		query1 := s.Dialect.Rebind(s.Dialect.Upsert(s.RateTable,
+			[]string{s.IdCol, s.AuthorCol, s.AnonymousCol, s.RateCol, s.ReviewCol, s.TimeCol, "histories"},
+			[]string{"?", "?", "?", "?", "?", "?", "?"},
+			[]string{s.IdCol, s.AuthorCol}, nil))
+		res1, err := tx.ExecContext(ctx, query1, rate.Id, rate.Author, rate.Anonymous, rate.Rate, rate.Review, rate.Time, s.ToArray(rate.Histories))
+		if err != nil {
+			return -1, err
+		}
+		if r, err = res1.RowsAffected(); err != nil {
+			return -1, err
+		}
+		if r > 0 {
+			if err = s.updateInfo(ctx, tx, rate.Id, nil, &rate.Rate); err != nil {
+				return -1, err
+			}
+		} else if oldRate, err = s.load(ctx, tx, rate.Id, rate.Author, true); err != nil {
+			return -1, err
+		} else if oldRate == nil {
+			return -1, fmt.Errorf("cannot insert the rate of %s on %s", rate.Author, rate.Id)
+		}
+	}
 	if oldRate != nil {
-		if oldRate.Rate != rate.Rate {
-			query1 += fmt.Sprintf(
-				"%s%d = %s.%s%d - 1, %s%d = %s.%s%d + 1, %s = %s.%s + %d - %d, %s = (%s.%s + %d - %d) / %s.%s",
-				s.InfoRateCol, oldRate.Rate, s.InfoTable, s.InfoRateCol, oldRate.Rate,
-				s.InfoRateCol, rate.Rate, s.InfoTable, s.InfoRateCol, rate.Rate,
-				s.RateScoreCol, s.InfoTable, s.RateScoreCol, rate.Rate, oldRate.Rate,
-				s.InfoRateCol, s.InfoTable, s.RateScoreCol, rate.Rate, oldRate.Rate, s.InfoTable, s.RateCountCol)
-		} else if oldRate.Rate == rate.Rate && oldRate.Review != rate.Review {
-			query1 = ""
-		} else {
+		if oldRate.Rate == rate.Rate && oldRate.Review == rate.Review {
 			return 0, nil
 		}
 		rate.Histories = append(oldRate.Histories, Histories{Time: oldRate.Time, Rate: oldRate.Rate, Review: oldRate.Review})
-	} else {
-		query1 += fmt.Sprintf(
-			"%s = %s.%s + 1, %s%d = %s.%s%d + 1, %s = %s.%s + %d, %s = (%s.%s + %d) / (%s.%s + 1)",
-			s.RateCountCol, s.InfoTable, s.RateCountCol,
-			s.InfoRateCol, rate.Rate, s.InfoTable, s.InfoRateCol, rate.Rate,
-			s.RateScoreCol, s.InfoTable, s.RateScoreCol, rate.Rate,
-			s.InfoRateCol, s.InfoTable, s.RateScoreCol, rate.Rate, s.InfoTable, s.RateCountCol)
+		query2 := s.Dialect.Rebind(fmt.Sprintf("update %s set %s = ?, %s = ?, %s = ?, %s = ?, histories = ? where %s = ? and %s = ?",
+			s.RateTable, s.AnonymousCol, s.RateCol, s.ReviewCol, s.TimeCol, s.IdCol, s.AuthorCol))
+		res2, err := tx.ExecContext(ctx, query2, rate.Anonymous, rate.Rate, rate.Review, rate.Time, s.ToArray(rate.Histories), rate.Id, rate.Author)
+		if err != nil {
+			return -1, err
+		}
+		if r, err = res2.RowsAffected(); err != nil {
+			return -1, err
+		}
+		if oldRate.Rate != rate.Rate {
+			if err = s.updateInfo(ctx, tx, rate.Id, &oldRate.Rate, &rate.Rate); err != nil {
+				return -1, err
+			}
+		}
+	}
+	newRating := outbox.Rating{Rate: rate.Rate, Review: rate.Review}
+	var event outbox.Event = outbox.RateCreated{Id: rate.Id, Author: rate.Author, Rating: newRating, Anonymous: rate.Anonymous, Time: now}
+	if oldRate != nil {
+		event = outbox.RateUpdated{Id: rate.Id, Author: rate.Author, Old: outbox.Rating{Rate: oldRate.Rate, Review: oldRate.Review}, New: newRating, Anonymous: rate.Anonymous, Time: now}
+	}
+	if err = outbox.Publish(ctx, tx, s.Publishers, event); err != nil {
+		return -1, err
 	}
+	if err = tx.Commit(); err != nil {
+		return -1, err
+	}
+	return r, nil
+}
+
+func (s *rateService) History(ctx context.Context, id string, author string) (*History, error) {
+	rate, err := s.Load(ctx, id, author)
+	if err != nil || rate == nil {
+		return nil, err
+	}
+	history := ToHistory(*rate)
+	return &history, nil
+}
+
+// Revert looks up the version in the transaction which rates it, so that it is the version of the rate being replaced.
+func (s *rateService) Revert(ctx context.Context, id string, author string, index int) (int64, error) {
+	return s.rate(ctx, id, author, func(old *Rate) (Request, error) {
+		req, err := ToRevert(old, index)
+		if err != nil {
+			return req, err
+		}
+		return req, s.Scale.Validate(req.Rate)
+	})
+}
 
-	stmt1, err := s.DB.Prepare(query1)
+func (s *rateService) Remove(ctx context.Context, id string, author string) (int64, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return -1, err
 	}
-	stmt1.ExecContext(ctx, rate.Id)
-
-	query2 := fmt.Sprintf(
-		"insert into %s(%s, %s, %s, %s, %s, %s, histories) values ($1, $2, $3, $4, $5, $6, $7) on conflict (%s, %s) do update set %s = $3,  %s = $4, %s = $5, %s = $6, histories = $7",
-		s.RateTable, s.IdCol, s.AuthorCol, s.AnonymousCol, s.RateCol, s.ReviewCol, s.TimeCol, s.IdCol, s.AuthorCol, s.AnonymousCol, s.RateCol, s.ReviewCol, s.TimeCol)
-	stmt, err := s.DB.Prepare(query2)
+	defer tx.Rollback()
+	query1 := s.Dialect.Rebind(s.Dialect.ForUpdate([]string{s.RateCol, s.ReviewCol}, s.RateTable, fmt.Sprintf("%s = ? and %s = ?", s.IdCol, s.AuthorCol)))
+	var rate float32
+	var review sql.NullString
+	err = tx.QueryRowContext(ctx, query1, id, author).Scan(&rate, &review)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	query2 := s.Dialect.Rebind(fmt.Sprintf("delete from %s where %s = ? and %s = ?", s.RateTable, s.IdCol, s.AuthorCol))
+	res, err := tx.ExecContext(ctx, query2, id, author)
 	if err != nil {
 		return -1, err
 	}
-	res2, err := stmt.ExecContext(ctx, rate.Id, rate.Author, rate.Anonymous, rate.Rate, rate.Review, rate.Time, s.ToArray(rate.Histories))
+	r, err := res.RowsAffected()
 	if err != nil {
 		return -1, err
 	}
+	if r == 0 {
+		return 0, nil
+	}
+	if err = s.updateInfo(ctx, tx, id, &rate, nil); err != nil {
+		return -1, err
+	}
+	if len(s.ReactionTable) > 0 {
+		query4 := s.Dialect.Rebind(fmt.Sprintf("delete from %s where %s = ? and %s = ?", s.ReactionTable, s.ReactionIdCol, s.ReactionAuthorCol))
+		if _, err = tx.ExecContext(ctx, query4, id, author); err != nil {
+			return -1, err
+		}
+	}
+	now := time.Now()
+	if len(s.CommentTable) > 0 {
+		query5 := s.Dialect.Rebind(fmt.Sprintf("select %s from %s where %s = ? and %s = ?", s.CommentIdCol, s.CommentTable, s.CommentRateIdCol, s.CommentRateAuthorCol))
+		rows, err := tx.QueryContext(ctx, query5, id, author)
+		if err != nil {
+			return -1, err
+		}
+		var commentIds []string
+		for rows.Next() {
+			var commentId string
+			if err = rows.Scan(&commentId); err != nil {
+				rows.Close()
+				return -1, err
+			}
+			commentIds = append(commentIds, commentId)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return -1, err
+		}
+		query6 := s.Dialect.Rebind(fmt.Sprintf("delete from %s where %s = ? and %s = ?", s.CommentTable, s.CommentRateIdCol, s.CommentRateAuthorCol))
+		if _, err = tx.ExecContext(ctx, query6, id, author); err != nil {
+			return -1, err
+		}
+		for _, commentId := range commentIds {
+			if err = outbox.Publish(ctx, tx, s.Publishers, outbox.CommentDeleted{CommentId: commentId, Id: id, Author: author, Time: now}); err != nil {
+				return -1, err
+			}
+		}
+	}
+	event := outbox.RateDeleted{Id: id, Author: author, Rating: outbox.Rating{Rate: rate, Review: review.String}, Time: now}
+	if err = outbox.Publish(ctx, tx, s.Publishers, event); err != nil {
+		return -1, err
+	}
+	if err = tx.Commit(); err != nil {
+		return -1, err
+	}
+	return r, nil
+}
 
-	return res2.RowsAffected()
+// updateInfo updates the rate info of id, when the rate old is replaced by the rate new. old is nil for a new rate, and new is nil for a removed rate.
+func (s *rateService) updateInfo(ctx context.Context, tx *sql.Tx, id string, old *float32, new *float32) error {
+	// The buckets are checked first, so that a rate out of the scale does not corrupt the histogram.
+	// An old rate out of the scale, stored before the scale was narrowed, is in no bucket, so only its count and score are updated.
+	oldBucket, newBucket := -1, -1
+	var err error
+	if old != nil {
+		if oldBucket, err = s.Scale.BucketOf(*old); err != nil && err != ErrOutOfScale {
+			return err
+		}
+	}
+	if new != nil {
+		if newBucket, err = s.Scale.BucketOf(*new); err != nil {
+			return err
+		}
+	}
+	if len(s.Scale.HistogramCol) > 0 {
+		return s.updateHistogram(ctx, tx, id, old, oldBucket, new, newBucket)
+	}
+	var query string
+	t := s.InfoTable
+	// The average is set first, because MySQL assigns the columns from left to right.
+	if old == nil {
+		v, bucket := literal(*new), s.Scale.Column(s.InfoRateCol, newBucket)
+		query = s.Dialect.Upsert(t, []string{s.InfoIdCol, s.InfoRateCol, bucket, s.RateCountCol, s.RateScoreCol}, []string{"?", v, "1", "1", v}, []string{s.InfoIdCol}, []string{
+			fmt.Sprintf("%s = (%s.%s + %s) / (%s.%s + 1)", s.InfoRateCol, t, s.RateScoreCol, v, t, s.RateCountCol),
+			fmt.Sprintf("%s = %s.%s + 1", s.RateCountCol, t, s.RateCountCol),
+			fmt.Sprintf("%s = %s.%s + 1", bucket, t, bucket),
+			fmt.Sprintf("%s = %s.%s + %s", s.RateScoreCol, t, s.RateScoreCol, v),
+		})
+	} else if new == nil {
+		v := literal(*old)
+		sets := []string{fmt.Sprintf("%s = case when %s > 1 then (%s - %s) / (%s - 1) else 0 end", s.InfoRateCol, s.RateCountCol, s.RateScoreCol, v, s.RateCountCol)}
+		if oldBucket >= 0 {
+			bucket := s.Scale.Column(s.InfoRateCol, oldBucket)
+			sets = append(sets, fmt.Sprintf("%s = %s - 1", bucket, bucket))
+		}
+		sets = append(sets, fmt.Sprintf("%s = %s - 1", s.RateCountCol, s.RateCountCol), fmt.Sprintf("%s = %s - %s", s.RateScoreCol, s.RateScoreCol, v))
+		query = fmt.Sprintf("update %s set %s where %s = ? and %s > 0", t, strings.Join(sets, ", "), s.InfoIdCol, s.RateCountCol)
+	} else {
+		diff := fmt.Sprintf("%s - %s", literal(*new), literal(*old))
+		sets := []string{fmt.Sprintf("%s = (%s + %s) / %s", s.InfoRateCol, s.RateScoreCol, diff, s.RateCountCol)}
+		if oldBucket != newBucket {
+			if oldBucket >= 0 {
+				oldCol := s.Scale.Column(s.InfoRateCol, oldBucket)
+				sets = append(sets, fmt.Sprintf("%s = %s - 1", oldCol, oldCol))
+			}
+			newCol := s.Scale.Column(s.InfoRateCol, newBucket)
+			sets = append(sets, fmt.Sprintf("%s = %s + 1", newCol, newCol))
+		}
+		sets = append(sets, fmt.Sprintf("%s = %s + %s", s.RateScoreCol, s.RateScoreCol, diff))
+		query = fmt.Sprintf("update %s set %s where %s = ?", t, strings.Join(sets, ", "), s.InfoIdCol)
+	}
+	if _, err = tx.ExecContext(ctx, s.Dialect.Rebind(query), id); err != nil {
+		return err
+	}
+	return s.rank(ctx, tx, id)
+}
+
+// updateHistogram updates the rate info of id when the histogram is stored in one column, which cannot be updated by an expression.
+func (s *rateService) updateHistogram(ctx context.Context, tx *sql.Tx, id string, old *float32, oldBucket int, new *float32, newBucket int) error {
+	// The histogram is read, then written, so the row is created if needed and locked before it is read.
+	query1 := s.Dialect.Rebind(s.Dialect.Upsert(s.InfoTable, []string{s.InfoIdCol, s.InfoRateCol, s.RateCountCol, s.RateScoreCol}, []string{"?", "0", "0", "0"}, []string{s.InfoIdCol}, nil))
+	if _, err := tx.ExecContext(ctx, query1, id); err != nil {
+		return err
+	}
+	info, err := s.loadInfo(ctx, tx, id, true)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		info = &RateInfo{Id: id}
+	}
+	histogram := make([]int, s.Scale.Size())
+	copy(histogram, info.Histogram)
+	if old != nil && info.Count > 0 {
+		if oldBucket >= 0 {
+			histogram[oldBucket]--
+		}
+		info.Count--
+		info.Score -= float64(*old)
+	}
+	if new != nil {
+		histogram[newBucket]++
+		info.Count++
+		info.Score += float64(*new)
+	}
+	info.Histogram = histogram
+	info.Rate = 0
+	if info.Count > 0 {
+		info.Rate = float32(info.Score / float64(info.Count))
+	}
+	if s.Ranking != nil {
+		s.Ranking.Rank(info)
+	}
+	sets := []string{s.InfoRateCol + " = ?", s.RateCountCol + " = ?", s.RateScoreCol + " = ?", s.Scale.HistogramCol + " = ?"}
+	params := []interface{}{info.Rate, info.Count, info.Score, s.histogram(info.Histogram)}
+	if s.Ranking != nil && len(s.Ranking.BayesianCol) > 0 {
+		sets = append(sets, s.Ranking.BayesianCol+" = ?")
+		params = append(params, info.Bayesian)
+	}
+	if s.Ranking != nil && len(s.Ranking.WilsonCol) > 0 {
+		sets = append(sets, s.Ranking.WilsonCol+" = ?")
+		params = append(params, info.Wilson)
+	}
+	query2 := s.Dialect.Rebind(fmt.Sprintf("update %s set %s where %s = ?", s.InfoTable, strings.Join(sets, ", "), s.InfoIdCol))
+	_, err = tx.ExecContext(ctx, query2, append(params, id)...)
+	return err
+}
+
+// loadInfo returns the count, the score and the histogram of id, or nil if id has no rate info.
+// If lock is true, the rate info is locked until the end of the transaction.
+func (s *rateService) loadInfo(ctx context.Context, tx *sql.Tx, id string, lock bool) (*RateInfo, error) {
+	info := RateInfo{Id: id}
+	var score sql.NullFloat64
+	var err error
+	where := s.InfoIdCol + " = ?"
+	if len(s.Scale.HistogramCol) > 0 {
+		columns := []string{s.RateCountCol, s.RateScoreCol, s.Scale.HistogramCol}
+		query := s.selectInfo(columns, where, lock)
+		if s.Scale.Array {
+			var histogram []int64
+			err = tx.QueryRowContext(ctx, query, id).Scan(&info.Count, &score, s.ToArray(&histogram))
+			for _, c := range histogram {
+				info.Histogram = append(info.Histogram, int(c))
+			}
+		} else {
+			var histogram Histogram
+			err = tx.QueryRowContext(ctx, query, id).Scan(&info.Count, &score, &histogram)
+			info.Histogram = histogram
+		}
+	} else {
+		columns := make([]string, s.Scale.Size())
+		info.Histogram = make([]int, len(columns))
+		dest := []interface{}{&info.Count, &score}
+		for i := range columns {
+			columns[i] = s.Scale.Column(s.InfoRateCol, i)
+			dest = append(dest, &info.Histogram[i])
+		}
+		query := s.selectInfo(append([]string{s.RateCountCol, s.RateScoreCol}, columns...), where, lock)
+		err = tx.QueryRowContext(ctx, query, id).Scan(dest...)
+	}
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.Score = score.Float64
+	return &info, nil
+}
+
+func (s *rateService) selectInfo(columns []string, where string, lock bool) string {
+	if lock {
+		return s.Dialect.Rebind(s.Dialect.ForUpdate(columns, s.InfoTable, where))
+	}
+	return s.Dialect.Rebind(fmt.Sprintf("select %s from %s where %s", strings.Join(columns, ", "), s.InfoTable, where))
+}
+
+func (s *rateService) histogram(histogram []int) interface{} {
+	if !s.Scale.Array {
+		return Histogram(histogram)
+	}
+	values := make([]int64, len(histogram))
+	for i, c := range histogram {
+		values[i] = int64(c)
+	}
+	return s.ToArray(values)
+}
+
+// literal formats the rate for a SQL expression. A negative rate is in parentheses, so that it cannot start a comment after a minus.
+func literal(rate float32) string {
+	v := formatRate(rate)
+	if rate < 0 {
+		return "(" + v + ")"
+	}
+	return v
+}
+
+// rank updates the ranking scores of id from its rate info, in the transaction which updated the rate info.
+func (s *rateService) rank(ctx context.Context, tx *sql.Tx, id string) error {
+	if s.Ranking == nil || (len(s.Ranking.BayesianCol) == 0 && len(s.Ranking.WilsonCol) == 0) {
+		return nil
+	}
+	info, err := s.loadInfo(ctx, tx, id, false)
+	if err != nil || info == nil {
+		return err
+	}
+	s.Ranking.Rank(info)
+	sets := make([]string, 0, 2)
+	params := make([]interface{}, 0, 3)
+	if len(s.Ranking.BayesianCol) > 0 {
+		sets = append(sets, s.Ranking.BayesianCol+" = ?")
+		params = append(params, info.Bayesian)
+	}
+	if len(s.Ranking.WilsonCol) > 0 {
+		sets = append(sets, s.Ranking.WilsonCol+" = ?")
+		params = append(params, info.Wilson)
+	}
+	query2 := s.Dialect.Rebind(fmt.Sprintf("update %s set %s where %s = ?", s.InfoTable, strings.Join(sets, ", "), s.InfoIdCol))
+	_, err = tx.ExecContext(ctx, query2, append(params, id)...)
+	return err
 }
@@ -0,0 +1,314 @@
+package rate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/core-go/reaction/dialect"
+)
+
+type jsonArray struct {
+	v interface{}
+}
+
+func toArray(v interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+} {
+	return jsonArray{v: v}
+}
+
+func (a jsonArray) Value() (driver.Value, error) {
+	b, err := json.Marshal(a.v)
+	return string(b), err
+}
+
+func (a jsonArray) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), a.v)
+	case []byte:
+		return json.Unmarshal(v, a.v)
+	default:
+		return fmt.Errorf("cannot scan %T into an array", value)
+	}
+}
+
+// newTestService opens a database file, so that the transactions of the goroutines run on their own connections.
+// The transactions are deferred, so that the rates contend: SQLite returns busy to a transaction which cannot upgrade its lock.
+func newTestService(t *testing.T, scale Scale, options ...Option) (*sql.DB, RateService) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "rate.db")+"?_busy_timeout=10000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range []string{
+		"create table rates (id varchar(40), author varchar(40), anonymous boolean default false, rate real, review text, time timestamp, usefulcount integer default 0, replycount integer default 0, histories text, primary key (id, author))",
+		"create table rateinfo (id varchar(40) primary key, rate real default 0, rate1 integer default 0, rate2 integer default 0, rate3 integer default 0, rate4 integer default 0, rate5 integer default 0, count integer default 0, score real default 0, histogram text)",
+		"create table reactions (id varchar(40), author varchar(40), userid varchar(40), reaction integer, primary key (id, author, userid))",
+		"create table comments (commentid varchar(40) primary key, id varchar(40), author varchar(40), comment text)",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	service := NewRateService(db, "rates", "id", "author", "anonymous", "rate", "review", "time", "usefulcount", "replycount",
+		"rateinfo", "id", "rate", "count", "score", toArray, append([]Option{WithScale(scale)}, options...)...)
+	return db, service
+}
+
+// checkInfo checks that the histogram, the score and the count of the rate info agree with the rates.
+func checkInfo(t *testing.T, db *sql.DB, scale Scale, id string) {
+	t.Helper()
+	want := make([]int, scale.Size())
+	var count int
+	var score float64
+	rows, err := db.Query("select rate from rates where id = ?", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rate float32
+		if err = rows.Scan(&rate); err != nil {
+			t.Fatal(err)
+		}
+		bucket, err := scale.BucketOf(rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[bucket]++
+		count++
+		score += float64(rate)
+	}
+	info := readInfo(t, db, scale, id)
+	got := info.Histogram
+	if info.Count != count || math.Abs(info.Score-score) > 1e-3 || math.Abs(float64(info.Rate)-score/float64(count)) > 1e-3 {
+		t.Errorf("rate info = %d rates, score %v and rate %v, want %d and %v", info.Count, info.Score, info.Rate, count, score)
+	}
+	sum := 0
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Errorf("histogram = %v, want %v", got, want)
+			break
+		}
+		sum += got[i]
+	}
+	if sum != info.Count {
+		t.Errorf("histogram = %v has %d rates, want %d", got, sum, info.Count)
+	}
+}
+
+// readInfo returns the rate info of id, with the histogram of the columns or of the histogram column of the scale.
+func readInfo(t *testing.T, db *sql.DB, scale Scale, id string) RateInfo {
+	t.Helper()
+	var info RateInfo
+	var histogram sql.NullString
+	got := make([]int, 5)
+	err := db.QueryRow("select count, score, rate, rate1, rate2, rate3, rate4, rate5, histogram from rateinfo where id = ?", id).
+		Scan(&info.Count, &info.Score, &info.Rate, &got[0], &got[1], &got[2], &got[3], &got[4], &histogram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scale.HistogramCol) > 0 {
+		got = nil
+		if err = json.Unmarshal([]byte(histogram.String), &got); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info.Histogram = got
+	return info
+}
+
+// busy returns true if the transaction of err was rolled back because another transaction holds the lock, so it can be retried.
+func busy(err error) bool {
+	var e sqlite3.Error
+	return errors.As(err, &e) && (e.Code == sqlite3.ErrBusy || e.Code == sqlite3.ErrLocked)
+}
+
+func TestRateConcurrently(t *testing.T) {
+	scales := map[string]Scale{
+		"columns":   NewScale(5),
+		"histogram": {Min: 1, Max: 5, Step: 1, HistogramCol: "histogram"},
+	}
+	for name, scale := range scales {
+		t.Run(name, func(t *testing.T) {
+			db, service := newTestService(t, scale)
+			ctx := context.Background()
+			var wg sync.WaitGroup
+			var retries int64
+			errs := make(chan error, 64)
+			// Each author rates the same item from several goroutines, so the first rate and the re-rates race.
+			for a := 0; a < 4; a++ {
+				for g := 0; g < 4; g++ {
+					wg.Add(1)
+					go func(author string, g int) {
+						defer wg.Done()
+						for i := 0; i < 5; i++ {
+							rate := float32((g+i)%5 + 1)
+							_, err := service.Rate(ctx, "item", author, Request{Rate: rate, Review: fmt.Sprintf("review %d", i)})
+							for busy(err) {
+								atomic.AddInt64(&retries, 1)
+								time.Sleep(time.Millisecond)
+								_, err = service.Rate(ctx, "item", author, Request{Rate: rate, Review: fmt.Sprintf("review %d", i)})
+							}
+							if err != nil {
+								errs <- err
+								return
+							}
+						}
+					}(fmt.Sprintf("author%d", a), g)
+				}
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Fatal(err)
+			}
+			t.Logf("%d rates retried", retries)
+			checkInfo(t, db, scale, "item")
+			if _, err := service.Remove(ctx, "item", "author0"); err != nil {
+				t.Fatal(err)
+			}
+			checkInfo(t, db, scale, "item")
+		})
+	}
+}
+
+func TestRemoveOutOfScale(t *testing.T) {
+	scales := map[string]Scale{
+		"columns":   NewScale(5),
+		"histogram": {Min: 1, Max: 5, Step: 1, HistogramCol: "histogram"},
+	}
+	for name, scale := range scales {
+		t.Run(name, func(t *testing.T) {
+			db, service := newTestService(t, scale)
+			// The rate 7 was stored with a scale of 10.
+			for _, stmt := range []string{
+				"insert into rates(id, author, rate, review, histories) values ('item', 'author', 7, '', '[]')",
+				"insert into rateinfo(id, rate, count, score, histogram) values ('item', 7, 1, 7, '[0,0,0,0,0]')",
+			} {
+				if _, err := db.Exec(stmt); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := context.Background()
+			// The rate is re-rated and removed, without a bucket for the old rate.
+			if _, err := service.Rate(ctx, "item", "author", Request{Rate: 3}); err != nil {
+				t.Fatal(err)
+			}
+			if info := readInfo(t, db, scale, "item"); info.Count != 1 || info.Score != 3 || !reflect.DeepEqual(info.Histogram, []int{0, 0, 1, 0, 0}) {
+				t.Errorf("rate info after re-rating = %+v, want 1 rate of 3", info)
+			}
+			if _, err := db.Exec("update rates set rate = 7 where id = 'item'"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec("update rateinfo set score = 7 where id = 'item'"); err != nil {
+				t.Fatal(err)
+			}
+			if n, err := service.Remove(ctx, "item", "author"); err != nil || n != 1 {
+				t.Fatalf("Remove() = %d, %v, want 1", n, err)
+			}
+			var rates int
+			if err := db.QueryRow("select count(*) from rates").Scan(&rates); err != nil {
+				t.Fatal(err)
+			}
+			if rates != 0 {
+				t.Errorf("got %d rates after Remove(), want 0", rates)
+			}
+			// The histogram keeps the bucket of 3, since the removed rate was in no bucket.
+			if info := readInfo(t, db, scale, "item"); info.Count != 0 || info.Score != 0 || !reflect.DeepEqual(info.Histogram, []int{0, 0, 1, 0, 0}) {
+				t.Errorf("rate info after Remove() = %+v, want no rate and the histogram unchanged", info)
+			}
+		})
+	}
+}
+
+func TestRemoveReactionsAndComments(t *testing.T) {
+	db, service := newTestService(t, NewScale(5), WithReactions("reactions", "id", "author"), WithComments("comments", "commentid", "id", "author"))
+	ctx := context.Background()
+	for _, author := range []string{"author", "other"} {
+		if _, err := service.Rate(ctx, "item", author, Request{Rate: 4}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("insert into reactions(id, author, userid, reaction) values ('item', ?, 'user', 1)", author); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("insert into comments(commentid, id, author, comment) values (?, 'item', ?, 'comment')", "c"+author, author); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := service.Remove(ctx, "item", "author"); err != nil || n != 1 {
+		t.Fatalf("Remove() = %d, %v, want 1", n, err)
+	}
+	// Only the reactions and the comments of the removed rate are deleted.
+	for _, table := range []string{"reactions", "comments"} {
+		var authors []string
+		rows, err := db.Query("select author from " + table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var author string
+			if err = rows.Scan(&author); err != nil {
+				t.Fatal(err)
+			}
+			authors = append(authors, author)
+		}
+		rows.Close()
+		if !reflect.DeepEqual(authors, []string{"other"}) {
+			t.Errorf("%s after Remove() = the rows of %v, want other", table, authors)
+		}
+	}
+	checkInfo(t, db, NewScale(5), "item")
+}
+
+// queries records the query of load, and fails it.
+type queries []string
+
+func (q *queries) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	*q = append(*q, query)
+	return nil, sql.ErrConnDone
+}
+
+func TestLoadForUpdate(t *testing.T) {
+	columns := "id, author, anonymous, rate, review, time, usefulcount, replycount, histories"
+	tests := []struct {
+		driver string
+		want   string
+	}{
+		{dialect.DriverPostgres, "select " + columns + " from rates where id = $1 and author = $2 for update"},
+		{dialect.DriverMysql, "select " + columns + " from rates where id = ? and author = ? for update"},
+		{dialect.DriverOracle, "select " + columns + " from rates where id = :val1 and author = :val2 for update"},
+		{dialect.DriverMssql, "select " + columns + " from rates with (updlock, rowlock) where id = @p1 and author = @p2"},
+		// SQLite locks the database file instead of the rows.
+		{dialect.DriverSqlite3, "select " + columns + " from rates where id = ? and author = ?"},
+	}
+	for _, tt := range tests {
+		s := &rateService{RateTable: "rates", IdCol: "id", AuthorCol: "author", AnonymousCol: "anonymous", RateCol: "rate", ReviewCol: "review",
+			TimeCol: "time", UsefulCountCol: "usefulcount", ReplyCountCol: "replycount", Dialect: dialect.Dialect{Driver: tt.driver}}
+		var q queries
+		if _, err := s.load(context.Background(), &q, "item", "author", true); err != sql.ErrConnDone {
+			t.Fatalf("%s: load() = %v, want the error of the query", tt.driver, err)
+		}
+		if len(q) != 1 || q[0] != tt.want {
+			t.Errorf("%s: load() queried %q, want %q", tt.driver, q, tt.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package rate
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+var ErrInvalidRate = errors.New("invalid rate")
+
+// ErrOutOfScale is returned when a rate is not in any bucket of the Scale, as a stored rate after the Scale was narrowed.
+var ErrOutOfScale = errors.New("rate out of the scale")
+
+// Scale defines the valid rates, from Min to Max by Step, and the buckets of the histogram of the rate info table.
+// The rates 0.5 to 5 by 0.5 are a scale of half stars.
+type Scale struct {
+	Min  float32
+	Max  float32
+	Step float32
+	// Bucket returns the bucket of the rate, from 0 to Size() - 1. By default, the bucket is the number of steps from Min.
+	Bucket func(rate float32) int
+	// HistogramCol is the column which stores the histogram as a JSON array, or as an array if Array is true.
+	// If it is empty, the bucket i is stored in the column of the rate info table with the suffix i + 1, as in rate1 to rate10.
+	HistogramCol string
+	Array        bool
+}
+
+// NewScale returns the scale of the integer rates from 1 to max, as the columns rate1 to rate{max}.
+func NewScale(max int) Scale {
+	return Scale{Min: 1, Max: float32(max), Step: 1}
+}
+
+func (s Scale) step() float32 {
+	if s.Step <= 0 {
+		return 1
+	}
+	return s.Step
+}
+
+// Size returns the number of buckets.
+func (s Scale) Size() int {
+	if s.Bucket != nil {
+		return s.Bucket(s.Max) + 1
+	}
+	return s.steps(s.Max) + 1
+}
+
+func (s Scale) steps(rate float32) int {
+	return int(math.Round(float64((rate - s.Min) / s.step())))
+}
+
+// Validate returns ErrInvalidRate if rate is not between Min and Max, or not a multiple of Step from Min.
+func (s Scale) Validate(rate float32) error {
+	if rate < s.Min || rate > s.Max {
+		return ErrInvalidRate
+	}
+	n := float64((rate - s.Min) / s.step())
+	if math.Abs(n-math.Round(n)) > 1e-3 {
+		return ErrInvalidRate
+	}
+	return nil
+}
+
+// Rates returns the valid rates, from Min to Max by Step.
+func (s Scale) Rates() []float32 {
+	n := s.steps(s.Max)
+	rates := make([]float32, 0, n+1)
+	for i := 0; i <= n; i++ {
+		rates = append(rates, s.Min+float32(i)*s.step())
+	}
+	return rates
+}
+
+// BucketOf returns the bucket of the rate, or ErrOutOfScale if the bucket is not between 0 and Size() - 1.
+func (s Scale) BucketOf(rate float32) (int, error) {
+	bucket := s.steps(rate)
+	if s.Bucket != nil {
+		bucket = s.Bucket(rate)
+	}
+	if bucket < 0 || bucket >= s.Size() {
+		return -1, ErrOutOfScale
+	}
+	return bucket, nil
+}
+
+// Column returns the column of the bucket, when the histogram is stored in one column per bucket.
+func (s Scale) Column(prefix string, bucket int) string {
+	return prefix + strconv.Itoa(bucket+1)
+}
+
+// Histogram is the histogram of a rate info, stored as a JSON array.
+type Histogram []int
+
+func (h Histogram) Value() (driver.Value, error) {
+	return json.Marshal(h)
+}
+
+func (h *Histogram) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*h = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, h)
+	case string:
+		return json.Unmarshal([]byte(v), h)
+	default:
+		return fmt.Errorf("cannot scan %T into Histogram", value)
+	}
+}
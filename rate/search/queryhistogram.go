@@ -0,0 +1,85 @@
+package search
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/rate"
+)
+
+type queryHistogram struct {
+	db      *sql.DB
+	toArray func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	}
+	table   string
+	id      string
+	rate    string
+	scale   rate.Scale
+	dialect dialect.Dialect
+}
+
+// NewQueryHistogram returns the loader of the histograms of the rate info table, stored in the columns rate plus the bucket,
+// or in the HistogramCol of the scale.
+func NewQueryHistogram(db *sql.DB, table string, id string, rate string, scale rate.Scale, toArray func(interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+}) queryHistogram {
+	return queryHistogram{db: db, table: table, id: id, rate: rate, scale: scale, toArray: toArray, dialect: dialect.New(db)}
+}
+
+// Load returns the histograms of the ids, by id. The ids without rate info are not in the result.
+func (q queryHistogram) Load(ids []string) (map[string][]int, error) {
+	histograms := make(map[string][]int)
+	if len(ids) == 0 {
+		return histograms, nil
+	}
+	columns := []string{q.scale.HistogramCol}
+	if len(q.scale.HistogramCol) == 0 {
+		columns = make([]string, q.scale.Size())
+		for i := range columns {
+			columns[i] = q.scale.Column(q.rate, i)
+		}
+	}
+	in, params := q.dialect.InArray(q.id, distinct(ids), q.toArray)
+	query := q.dialect.Rebind(fmt.Sprintf("select %s, %s from %s where %s", q.id, strings.Join(columns, ", "), q.table, in))
+	rows, err := q.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var histogram []int
+		if len(q.scale.HistogramCol) > 0 && q.scale.Array {
+			var values []int64
+			if err = rows.Scan(&id, q.toArray(&values)); err != nil {
+				return nil, err
+			}
+			for _, c := range values {
+				histogram = append(histogram, int(c))
+			}
+		} else if len(q.scale.HistogramCol) > 0 {
+			var values rate.Histogram
+			if err = rows.Scan(&id, &values); err != nil {
+				return nil, err
+			}
+			histogram = values
+		} else {
+			histogram = make([]int, len(columns))
+			dest := []interface{}{&id}
+			for i := range histogram {
+				dest = append(dest, &histogram[i])
+			}
+			if err = rows.Scan(dest...); err != nil {
+				return nil, err
+			}
+		}
+		histograms[id] = histogram
+	}
+	return histograms, rows.Err()
+}
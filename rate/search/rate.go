@@ -36,21 +36,16 @@ type Rates struct {
 	UserURL     *string     `json:"authorURL" gorm:"column:imageurl"`
 }
 
+// RateInfo is the rate summary of a rated item. Sort on bayesian or wilson to rank the items by their rates and their number of rates.
 type RateInfo struct {
-	Id     string  `gorm:"column:id;primary_key" validate:"required,max=255"`
-	Rate   float32 `gorm:"column:rate;"`
-	Rate1  int     `gorm:"column:rate1;"`
-	Rate2  int     `gorm:"column:rate2;"`
-	Rate3  int     `gorm:"column:rate3;"`
-	Rate4  int     `gorm:"column:rate4;"`
-	Rate5  int     `gorm:"column:rate5;"`
-	Rate6  int     `gorm:"column:rate6;"`
-	Rate7  int     `gorm:"column:rate7;"`
-	Rate8  int     `gorm:"column:rate8;"`
-	Rate9  int     `gorm:"column:rate9;"`
-	Rate10 int     `gorm:"column:rate10;"`
-	Count  int     `gorm:"column:count;"`
-	Score  int     `gorm:"column:score;"`
+	Id    string  `json:"id,omitempty" gorm:"column:id;primary_key" validate:"required,max=255"`
+	Rate  float32 `json:"rate" gorm:"column:rate;"`
+	Count int     `json:"count" gorm:"column:count;"`
+	Score float64 `json:"score" gorm:"column:score;"`
+	// Histogram has the number of rates of each bucket of the scale, loaded by QueryHistogram.
+	Histogram []int   `json:"histogram" gorm:"column:-"`
+	Bayesian  float64 `json:"bayesian" gorm:"column:bayesian;"`
+	Wilson    float64 `json:"wilson" gorm:"column:wilson;"`
 }
 
 type Histories struct {
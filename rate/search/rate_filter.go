@@ -18,6 +18,11 @@ type RateFilter struct {
 	UserId      string            `mapstructure:"userId" json:"userId,omitempty" gorm:"column:userId;primary_key" bson:"userId" dynamodbav:"userId" firestore:"userId" match:"equal" validate:"max=255"`
 }
 
+type RateInfoFilter struct {
+	*search.Filter
+	Id string `mapstructure:"id" json:"id,omitempty" gorm:"column:id;primary_key" bson:"id" dynamodbav:"id" firestore:"id" match:"equal" validate:"max=255"`
+}
+
 type RatesFilter struct {
 	*search.Filter
 	Id          string     `mapstructure:"id" json:"id,omitempty" gorm:"column:id;primary_key" bson:"id,omitempty" dynamodbav:"id,omitempty" firestore:"id,omitempty" validate:"required,max=255" match:"equal"`
@@ -0,0 +1,85 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+
+	. "github.com/core-go/sql"
+)
+
+// RateInfoSearchService searches the rate summaries of the rated items, to rank them by rate, bayesian or wilson.
+type RateInfoSearchService interface {
+	Search(ctx context.Context, filter *RateInfoFilter) ([]RateInfo, int64, error)
+}
+
+type rateInfoSearchService struct {
+	Database       *sql.DB
+	BuildQuery     func(sm interface{}) (string, []interface{})
+	BuildFromQuery func(ctx context.Context, db *sql.DB, fieldsIndex map[string]int, models interface{}, query string, params []interface{}, limit int64, offset int64, toArray func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	}, options ...func(context.Context, interface{}) (interface{}, error)) (int64, error)
+	fieldsIndex map[string]int
+	ToArray     func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	}
+	getOffset func(limit int64, page int64, opts ...int64) int64
+	// QueryHistogram loads the histograms of the rate infos, as NewQueryHistogram(...).Load. If it is nil, the histograms are empty.
+	QueryHistogram func(ids []string) (map[string][]int, error)
+}
+
+func NewRateInfoSearchService(Database *sql.DB,
+	BuildQuery func(sm interface{}) (string, []interface{}),
+	ToArray func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	},
+	buildFromQuery func(ctx context.Context, db *sql.DB, fieldsIndex map[string]int, models interface{}, query string, params []interface{}, limit int64, offset int64, toArray func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	}, options ...func(context.Context, interface{}) (interface{}, error)) (int64, error),
+	getOffset func(limit int64, page int64, opts ...int64) int64,
+	queryHistogram func(ids []string) (map[string][]int, error),
+) (*rateInfoSearchService, error) {
+	fieldsIndex, err := GetColumnIndexes(reflect.TypeOf(RateInfo{}))
+	if err != nil {
+		return nil, err
+	}
+	return &rateInfoSearchService{
+		Database:       Database,
+		BuildQuery:     BuildQuery,
+		BuildFromQuery: buildFromQuery,
+		fieldsIndex:    fieldsIndex,
+		ToArray:        ToArray,
+		getOffset:      getOffset,
+		QueryHistogram: queryHistogram,
+	}, nil
+}
+
+func (f *rateInfoSearchService) Search(ctx context.Context, filter *RateInfoFilter) ([]RateInfo, int64, error) {
+	query, params := f.BuildQuery(filter)
+	infos := make([]RateInfo, 0)
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	offset := f.getOffset(filter.Limit, filter.Page)
+	total, err := f.BuildFromQuery(ctx, f.Database, f.fieldsIndex, &infos, query, params, filter.Limit, offset, f.ToArray)
+	if err != nil || f.QueryHistogram == nil || len(infos) == 0 {
+		return infos, total, err
+	}
+	ids := make([]string, len(infos))
+	for i := range infos {
+		ids[i] = infos[i].Id
+	}
+	histograms, err := f.QueryHistogram(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range infos {
+		infos[i].Histogram = histograms[infos[i].Id]
+	}
+	return infos, total, nil
+}
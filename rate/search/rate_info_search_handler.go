@@ -0,0 +1,39 @@
+package search
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/core-go/search"
+)
+
+func NewRateInfoSearchHandler(service RateInfoSearchService) *RateInfoSearchHandler {
+	return &RateInfoSearchHandler{service: service}
+}
+
+type RateInfoSearchResult struct {
+	List  []RateInfo `json:"list"`
+	Total int64      `json:"total"`
+}
+
+type RateInfoSearchHandler struct {
+	service RateInfoSearchService
+}
+
+func (h *RateInfoSearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	var filter RateInfoFilter
+	if err := Decode(w, r, &filter); err != nil {
+		return
+	}
+	if filter.Filter != nil {
+		search.RepairFilter(filter.Filter)
+	}
+	list, total, err := h.service.Search(r.Context(), &filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(RateInfoSearchResult{List: list, Total: total})
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewLimiter returns the limiter of the requests of the user identified by userId.
+// If userId is nil or returns an empty string, the requests are limited by the client IP.
+func NewLimiter(store Store, config Config, userId func(r *http.Request) string) *Limiter {
+	return &Limiter{Store: store, Config: config, UserId: userId}
+}
+
+type Limiter struct {
+	Store  Store
+	Config Config
+	UserId func(r *http.Request) string
+	// OnError is called when the store fails, and the request is let through.
+	OnError func(r *http.Request, err error)
+}
+
+// Wrap limits the requests of action per user.
+func (l *Limiter) Wrap(action string, next http.HandlerFunc) http.HandlerFunc {
+	return l.WrapToggle(action, nil, next)
+}
+
+// WrapToggle limits the requests of action per user, and the requests of action per user on the target, with the toggle limit.
+// Wrap both handlers of a toggle, such as follow and unfollow, with the same action, so that they share their buckets.
+// The toggle bucket is taken first, so that the toggling rejected on a target does not spend the tokens of the action on the other targets.
+func (l *Limiter) WrapToggle(action string, target func(r *http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := l.user(r)
+		if target != nil && !l.Config.Toggle.Unlimited() {
+			if t := target(r); len(t) > 0 && !l.take(w, r, "toggle:"+action+":"+user+":"+t, l.Config.Toggle) {
+				return
+			}
+		}
+		if !l.take(w, r, action+":"+user, l.limit(action)) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (l *Limiter) limit(action string) Limit {
+	if limit, ok := l.Config.Actions[action]; ok {
+		return limit
+	}
+	return l.Config.Default
+}
+
+func (l *Limiter) take(w http.ResponseWriter, r *http.Request, key string, limit Limit) bool {
+	if limit.Unlimited() {
+		return true
+	}
+	ok, wait, err := l.Store.Take(r.Context(), key, limit, time.Now())
+	if err != nil {
+		if l.OnError != nil {
+			l.OnError(r, err)
+		}
+		return true
+	}
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+	}
+	return ok
+}
+
+func (l *Limiter) user(r *http.Request) string {
+	if l.UserId != nil {
+		if id := l.UserId(r); len(id) > 0 {
+			return id
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// FromPath returns the path parameters at the offsets from the end of the path, joined by "/".
+func FromPath(offsets ...int) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		params := strings.Split(r.URL.Path, "/")
+		values := make([]string, 0, len(offsets))
+		for _, offset := range offsets {
+			i := len(params) - 1 - offset
+			if i < 0 {
+				return ""
+			}
+			values = append(values, params[i])
+		}
+		return strings.Join(values, "/")
+	}
+}
+
+func FromHeader(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func ok(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// serve sends a request of the client addr to path, and returns the status code and the Retry-After header.
+func serve(handler http.HandlerFunc, addr string, path string) (int, string) {
+	r := httptest.NewRequest(http.MethodPost, path, nil)
+	r.RemoteAddr = addr
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w.Code, w.Header().Get("Retry-After")
+}
+
+func TestTooManyRequests(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(), Config{Default: PerMinute(2)}, nil)
+	handler := limiter.Wrap("rate", ok)
+	for i := 0; i < 2; i++ {
+		if code, _ := serve(handler, "10.0.0.1:1234", "/rates/item"); code != http.StatusOK {
+			t.Fatalf("request %d = %d, want %d", i, code, http.StatusOK)
+		}
+	}
+	code, retry := serve(handler, "10.0.0.1:5678", "/rates/item")
+	if code != http.StatusTooManyRequests || retry != "30" {
+		t.Errorf("third request = %d with Retry-After %q, want %d with 30", code, retry, http.StatusTooManyRequests)
+	}
+	if code, _ = serve(handler, "10.0.0.2:1234", "/rates/item"); code != http.StatusOK {
+		t.Errorf("request of another client = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestActionLimits(t *testing.T) {
+	config := Config{Default: PerMinute(1), Actions: map[string]Limit{"rate": PerMinute(3)}}
+	limiter := NewLimiter(NewMemoryStore(), config, FromHeader("user"))
+	handlers := map[string]http.HandlerFunc{"rate": limiter.Wrap("rate", ok), "comment": limiter.Wrap("comment", ok)}
+	for action, want := range map[string]int{"rate": 3, "comment": 1} {
+		allowed := 0
+		for i := 0; i < 5; i++ {
+			r := httptest.NewRequest(http.MethodPost, "/"+action, nil)
+			r.Header.Set("user", "u1")
+			w := httptest.NewRecorder()
+			handlers[action](w, r)
+			if w.Code == http.StatusOK {
+				allowed++
+			}
+		}
+		if allowed != want {
+			t.Errorf("%s allowed %d requests, want %d", action, allowed, want)
+		}
+	}
+}
+
+func TestToggleLimit(t *testing.T) {
+	config := Config{Default: PerMinute(4), Toggle: PerMinute(2)}
+	limiter := NewLimiter(NewMemoryStore(), config, nil)
+	handler := limiter.WrapToggle("follow", FromPath(0), ok)
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/follow/t1", http.StatusOK},
+		{"/follow/t1", http.StatusOK},
+		// The toggle bucket of t1 is empty, and the action bucket is not spent.
+		{"/follow/t1", http.StatusTooManyRequests},
+		{"/follow/t1", http.StatusTooManyRequests},
+		{"/follow/t2", http.StatusOK},
+		{"/follow/t2", http.StatusOK},
+		// The action bucket is empty.
+		{"/follow/t3", http.StatusTooManyRequests},
+	}
+	for i, tt := range tests {
+		if code, _ := serve(handler, "10.0.0.1:1234", tt.path); code != tt.want {
+			t.Errorf("request %d to %s = %d, want %d", i, tt.path, code, tt.want)
+		}
+	}
+}
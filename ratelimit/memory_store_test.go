@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRefill(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	take := func(at time.Duration, limit Limit, want bool, wantWait time.Duration) {
+		t.Helper()
+		ok, wait, err := store.Take(ctx, "key", limit, now.Add(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want || wait != wantWait {
+			t.Errorf("Take() at %v = %v and wait %v, want %v and %v", at, ok, wait, want, wantWait)
+		}
+	}
+	limit := PerSecond(2)
+	take(0, limit, true, 0)
+	take(0, limit, true, 0)
+	take(0, limit, false, 500*time.Millisecond)
+	take(250*time.Millisecond, limit, false, 250*time.Millisecond)
+	take(500*time.Millisecond, limit, true, 0)
+	// The bucket does not refill over its capacity.
+	take(10*time.Second, limit, true, 0)
+	take(10*time.Second, limit, true, 0)
+	take(10*time.Second, limit, false, 500*time.Millisecond)
+}
+
+func TestBurst(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	limit := Limit{Requests: 1, Per: time.Second, Burst: 3}
+	for i := 0; i < 3; i++ {
+		if ok, _, _ := store.Take(ctx, "key", limit, now); !ok {
+			t.Fatalf("Take() %d of the burst was rejected", i)
+		}
+	}
+	if ok, wait, _ := store.Take(ctx, "key", limit, now); ok || wait != time.Second {
+		t.Errorf("Take() after the burst = %v and wait %v, want false and 1s", ok, wait)
+	}
+}
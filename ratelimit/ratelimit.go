@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit is a token bucket which allows Requests requests per Per, with bursts of up to Burst requests.
+// If Burst is 0, it is Requests. If Requests is 0, there is no limit.
+type Limit struct {
+	Requests int           `yaml:"requests" mapstructure:"requests" json:"requests,omitempty"`
+	Per      time.Duration `yaml:"per" mapstructure:"per" json:"per,omitempty"`
+	Burst    int           `yaml:"burst" mapstructure:"burst" json:"burst,omitempty"`
+}
+
+func PerSecond(requests int) Limit {
+	return Limit{Requests: requests, Per: time.Second}
+}
+func PerMinute(requests int) Limit {
+	return Limit{Requests: requests, Per: time.Minute}
+}
+func PerHour(requests int) Limit {
+	return Limit{Requests: requests, Per: time.Hour}
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Per <= 0
+}
+
+// Rate is the number of tokens added to the bucket per second.
+func (l Limit) Rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+func (l Limit) Capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// Config is the default limit of the actions, the limits of specific actions,
+// and the limit of the requests of an action by a user on the same target, such as follow/unfollow toggling.
+type Config struct {
+	Default Limit            `yaml:"default" mapstructure:"default" json:"default,omitempty"`
+	Actions map[string]Limit `yaml:"actions" mapstructure:"actions" json:"actions,omitempty"`
+	Toggle  Limit            `yaml:"toggle" mapstructure:"toggle" json:"toggle,omitempty"`
+}
+
+// Store keeps the token buckets. A shared store, such as Redis, lets several instances enforce the same limits.
+type Store interface {
+	// Take takes a token from the bucket of key. If the bucket is empty, it returns false and the time to wait for the next token.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error)
+}
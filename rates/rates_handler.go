@@ -48,6 +48,10 @@ func (h *RatesHandler) Rate(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		result, er3 := h.service.Rate(r.Context(), id, author, &req)
+		if er3 == ErrInvalidRate {
+			http.Error(w, er3.Error(), http.StatusBadRequest)
+			return
+		}
 		if er3 != nil {
 			http.Error(w, er3.Error(), http.StatusInternalServerError)
 			return
@@ -68,7 +72,7 @@ func validate(rate *Request, max int) ([]ErrorMessage, error) {
 	}
 	for i := 0; i < len(rate.Rates); i++ {
 		if rate.Rates[i] > float32(max) {
-			errors = append(errors, ErrorMessage{Field: fmt.Sprintf("rate$1", i), Code: "max", Param: strconv.Itoa(max)})
+			errors = append(errors, ErrorMessage{Field: fmt.Sprintf("rate%d", i), Code: "max", Param: strconv.Itoa(max)})
 		}
 	}
 	return errors, nil
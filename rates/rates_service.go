@@ -4,13 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
-	"github.com/lib/pq"
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
 )
 
+// ErrInvalidRate is returned if the number of rates is not the number of criteria.
+var ErrInvalidRate = errors.New("invalid rate")
+
 type RatesService interface {
 	Rate(ctx context.Context, id string, author string, rate *Request) (int64, error)
 }
@@ -44,6 +49,7 @@ func NewRatesService(
 		driver.Valuer
 		sql.Scanner
 	},
+	publishers ...outbox.Publisher,
 ) RatesService {
 	return &ratesService{
 		DB:           db,
@@ -71,6 +77,8 @@ func NewRatesService(
 		InfoCountCol:   infoCountCol,
 		InfoScoreCol:   infoScoreCol,
 		ToArray:        ToArray,
+		Dialect:        dialect.New(db),
+		Publishers:     publishers,
 	}
 }
 
@@ -104,9 +112,14 @@ type ratesService struct {
 		driver.Valuer
 		sql.Scanner
 	}
+	Dialect    dialect.Dialect
+	Publishers []outbox.Publisher
 }
 
 func (s *ratesService) Rate(ctx context.Context, id string, author string, req *Request) (int64, error) {
+	if len(req.Rates) != s.Max {
+		return -1, ErrInvalidRate
+	}
 	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return -1, err
@@ -115,13 +128,16 @@ func (s *ratesService) Rate(ctx context.Context, id string, author string, req *
 	defer tx.Rollback()
 
 	t := time.Now()
-	rate := Rates{Id: id, Author: author, Rate: req.Rate, Rates: req.Rates, Review: req.Review, Anonymous: req.Anonymous, Time: &t}
-	if req.Rates != nil && len(req.Rates) > 0 {
-		rate.Rate = avg(req.Rates)
+	rate := Rates{Id: id, Author: author, Rate: avg(req.Rates), Rates: req.Rates, Review: req.Review, Anonymous: req.Anonymous, Time: &t}
+	// The old rate is locked, so that concurrent rates of the same author are counted once in the info tables.
+	oldRate, err := s.load(ctx, tx, rate.Id, rate.Author, true)
+	if err != nil {
+		return -1, err
 	}
-	// load rates
-	oldRate, _ := s.load(ctx, rate.Id, rate.Author)
 	existRate := oldRate != nil
+	if existRate {
+		rate.Histories = append(oldRate.Histories, Histories{Time: oldRate.Time, Rate: oldRate.Rate, Review: oldRate.Review})
+	}
 	//  loop all rate and then upsert info table
 	_, err = s.upsertInfoTables(ctx, tx, oldRate, rate, s.Max, s.InfoTablesName)
 	if err != nil {
@@ -133,9 +149,11 @@ func (s *ratesService) Rate(ctx context.Context, id string, author string, req *
 		return -1, err
 	}
 	// upsert table rate
-	queryRate := fmt.Sprintf(
-		"insert into %s(%s, %s, %s, %s, %s, %s, %s, histories) values ($1, $2, $3, $4, $5, $6, $7, $8) on conflict (%s, %s) do update set %s = $3,  %s = $4, %s = $5, %s = $6, %s = $7, histories = $8",
-		s.TableName, s.IdCol, s.AuthorCol, s.AnonymousCol, s.RateCol, s.RatesCol, s.ReviewCol, s.TimeCol, s.IdCol, s.AuthorCol, s.AnonymousCol, s.RateCol, s.RatesCol, s.ReviewCol, s.TimeCol)
+	queryRate := s.Dialect.Rebind(s.Dialect.Upsert(s.TableName,
+		[]string{s.IdCol, s.AuthorCol, s.AnonymousCol, s.RateCol, s.RatesCol, s.ReviewCol, s.TimeCol, "histories"},
+		[]string{"?", "?", "?", "?", "?", "?", "?", "?"},
+		[]string{s.IdCol, s.AuthorCol},
+		s.Dialect.UpdateSets(s.AnonymousCol, s.RateCol, s.RatesCol, s.ReviewCol, s.TimeCol, "histories")))
 	stmt, err := tx.Prepare(queryRate)
 	if err != nil {
 		return -1, err
@@ -145,32 +163,49 @@ func (s *ratesService) Rate(ctx context.Context, id string, author string, req *
 		return -1, err
 	}
 
-	r, err1 := res2.RowsAffected()
+	r, err := res2.RowsAffected()
+	if err != nil {
+		return -1, err
+	}
+	newRating := outbox.Rating{Rate: rate.Rate, Rates: rate.Rates, Review: rate.Review}
+	var event outbox.Event = outbox.RateCreated{Id: rate.Id, Author: rate.Author, Rating: newRating, Anonymous: rate.Anonymous, Time: t}
+	if existRate {
+		event = outbox.RateUpdated{Id: rate.Id, Author: rate.Author, Old: outbox.Rating{Rate: oldRate.Rate, Rates: oldRate.Rates, Review: oldRate.Review}, New: newRating, Anonymous: rate.Anonymous, Time: t}
+	}
+	if err = outbox.Publish(ctx, tx, s.Publishers, event); err != nil {
+		return -1, err
+	}
 	if err = tx.Commit(); err != nil {
 		return -1, err
 	}
-
-	return r, err1
+	return r, nil
 }
-func (s *ratesService) load(ctx context.Context, id string, author string) (*Rates, error) {
-	query := fmt.Sprintf("select %s,%s,%s,%s,%s,%s,%s,%s,histories from %s where %s = $1 and %s = $2",
-		s.IdCol, s.AuthorCol, s.RateCol, s.RatesCol, s.TimeCol, s.ReviewCol, s.UsefulCol, s.ReplyCol,
-		s.TableName, s.IdCol, s.AuthorCol)
-	rows, err := s.DB.QueryContext(ctx, query, id, author)
+// load returns the rate of the author on id. If lock is true, the rate is locked until the end of the transaction db.
+func (s *ratesService) load(ctx context.Context, db interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}, id string, author string, lock bool) (*Rates, error) {
+	columns := []string{s.IdCol, s.AuthorCol, s.RateCol, s.RatesCol, s.TimeCol, s.ReviewCol, s.UsefulCol, s.ReplyCol, "histories"}
+	where := fmt.Sprintf("%s = ? and %s = ?", s.IdCol, s.AuthorCol)
+	query := fmt.Sprintf("select %s from %s where %s", strings.Join(columns, ", "), s.TableName, where)
+	if lock {
+		query = s.Dialect.ForUpdate(columns, s.TableName, where)
+	}
+	rows, err := db.QueryContext(ctx, s.Dialect.Rebind(query), id, author)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var rate Rates
-		err := rows.Scan(&rate.Id, &rate.Author, &rate.Rate, pq.Array(&rate.Rates), &rate.Time, &rate.Review, &rate.UsefulCount, &rate.ReplyCount, pq.Array(&rate.Histories))
+		err := rows.Scan(&rate.Id, &rate.Author, &rate.Rate, s.ToArray(&rate.Rates), &rate.Time, &rate.Review, &rate.UsefulCount, &rate.ReplyCount, s.ToArray(&rate.Histories))
 		if err != nil {
 			return nil, err
 		}
 		return &rate, nil
 	}
-	return nil, nil
+	return nil, rows.Err()
 }
+
 func (s *ratesService) upsertInfoTables(ctx context.Context, tx *sql.Tx, oldRate *Rates, rate Rates, max int, infoTablesNames []string) (int64, error) {
 	queries := make([]string, 0)
 	params := make([][]interface{}, 0)
@@ -179,35 +214,34 @@ func (s *ratesService) upsertInfoTables(ctx context.Context, tx *sql.Tx, oldRate
 		rateValue := int(rate.Rates[index])
 		infoTable := infoTablesNames[index]
 
-		query1 := fmt.Sprintf("insert into %s(%s, %s, %s%d, %s, %s) values ($1, %d, 1, 1, %d) on conflict (%s) do update set ",
-			infoTable, s.InfoIdCol, s.InfoRateCol, s.InfoRateCol, rateValue, s.InfoCountCol, s.InfoScoreCol, rateValue, rateValue, s.InfoIdCol)
+		columns := []string{s.InfoIdCol, s.InfoRateCol, fmt.Sprintf("%s%d", s.InfoRateCol, rateValue), s.InfoCountCol, s.InfoScoreCol}
+		values := []string{"?", fmt.Sprint(rateValue), "1", "1", fmt.Sprint(rateValue)}
+		keys := []string{s.InfoIdCol}
+		query1 := ""
 		if oldRate != nil {
-			if oldRate.Rates[index] != rate.Rates[index] {
-				oRate := int(oldRate.Rates[index])
-				query1 += fmt.Sprintf(
-					"%s%d = %s.%s%d - 1, %s%d = %s.%s%d + 1, %s = %s.%s + %d - %d, %s = (%s.%s + %d - %d) / %s.%s",
-					s.InfoRateCol, oRate, infoTable, s.InfoRateCol, oRate,
-					s.InfoRateCol, rateValue, infoTable, s.InfoRateCol, rateValue,
-					s.InfoScoreCol, infoTable, s.InfoScoreCol, rateValue, oRate,
-					s.InfoRateCol, infoTable, s.InfoScoreCol, rateValue, oRate, infoTable, s.InfoCountCol)
-			} else if oldRate.Rates[index] != rate.Rates[index] && oldRate.Review != rate.Review {
-				query1 = ""
-			} else {
-				break
+			// The info table of an unchanged criterion is not updated, and the next criteria are.
+			if oldRate.Rates[index] == rate.Rates[index] {
+				continue
 			}
-			rate.Histories = append(oldRate.Histories, Histories{Time: oldRate.Time, Rate: oldRate.Rate, Review: oldRate.Review})
+			oRate := int(oldRate.Rates[index])
+			query1 = s.Dialect.Rebind(s.Dialect.Upsert(infoTable, columns, values, keys, []string{
+				fmt.Sprintf("%s%d = %s.%s%d - 1", s.InfoRateCol, oRate, infoTable, s.InfoRateCol, oRate),
+				fmt.Sprintf("%s%d = %s.%s%d + 1", s.InfoRateCol, rateValue, infoTable, s.InfoRateCol, rateValue),
+				fmt.Sprintf("%s = %s.%s + %d - %d", s.InfoScoreCol, infoTable, s.InfoScoreCol, rateValue, oRate),
+				fmt.Sprintf("%s = (%s.%s + %d - %d) / %s.%s", s.InfoRateCol, infoTable, s.InfoScoreCol, rateValue, oRate, infoTable, s.InfoCountCol),
+			}))
 		} else {
-			query1 += fmt.Sprintf(
-				"%s = %s.%s + 1, %s%d = %s.%s%d + 1, %s = %s.%s + %d, %s = (%s.%s + %d) / (%s.%s + 1)",
-				s.InfoCountCol, infoTable, s.InfoCountCol,
-				s.InfoRateCol, rateValue, infoTable, s.InfoRateCol, rateValue,
-				s.InfoScoreCol, infoTable, s.InfoScoreCol, rateValue,
-				s.InfoRateCol, infoTable, s.InfoScoreCol, rateValue, infoTable, s.InfoCountCol)
+			query1 = s.Dialect.Rebind(s.Dialect.Upsert(infoTable, columns, values, keys, []string{
+				fmt.Sprintf("%s = %s.%s + 1", s.InfoCountCol, infoTable, s.InfoCountCol),
+				fmt.Sprintf("%s%d = %s.%s%d + 1", s.InfoRateCol, rateValue, infoTable, s.InfoRateCol, rateValue),
+				fmt.Sprintf("%s = %s.%s + %d", s.InfoScoreCol, infoTable, s.InfoScoreCol, rateValue),
+				fmt.Sprintf("%s = (%s.%s + %d) / (%s.%s + 1)", s.InfoRateCol, infoTable, s.InfoScoreCol, rateValue, infoTable, s.InfoCountCol),
+			}))
 		}
 		queries = append(queries, query1)
 		params = append(params, []interface{}{rate.Id})
 	}
-	return s.ExecBatch(ctx, queries, params)
+	return s.ExecBatch(ctx, tx, queries, params)
 }
 func (s *ratesService) upsertFullInfoTable(ctx context.Context, tx *sql.Tx, oldRate *Rates, rate Rates, max int, fullInfoTableName string, infoTablesName []string, existRate bool) (int64, error) {
 	updatedRateRangeAverage := sum(rate.Rates)
@@ -217,10 +251,9 @@ func (s *ratesService) upsertFullInfoTable(ctx context.Context, tx *sql.Tx, oldR
 		countOfUserRatedMore = 0
 	}
 	updatedRateRangeAverage = updatedRateRangeAverage / float32(max)
-	queryi, paramsi := s.buildQueryInsertFullInfo(&rate, fullInfoTableName, infoTablesName)
-	nextIndex := len(paramsi) + 1
-	queryu, paramsu := s.buildQueryUpdateFullInfo(&rate, updatedRateRangeAverage, countOfUserRatedMore, fullInfoTableName, infoTablesName, nextIndex)
-	queryMerged := fmt.Sprintf("%s  on conflict (%s) do %s", queryi, s.FullInfoIdCol, queryu)
+	columns, values, paramsi := s.buildQueryInsertFullInfo(&rate, infoTablesName)
+	sets, paramsu := s.buildQueryUpdateFullInfo(&rate, updatedRateRangeAverage, countOfUserRatedMore, fullInfoTableName, infoTablesName)
+	queryMerged := s.Dialect.Rebind(s.Dialect.Upsert(fullInfoTableName, columns, values, []string{s.FullInfoIdCol}, sets))
 	stmt, err := tx.Prepare(queryMerged)
 	if err != nil {
 		return -1, err
@@ -233,47 +266,38 @@ func (s *ratesService) upsertFullInfoTable(ctx context.Context, tx *sql.Tx, oldR
 
 	return rs.RowsAffected()
 }
-func (s *ratesService) buildQueryInsertFullInfo(rate *Rates, fullInfoTableName string, infoTablesName []string) (string, []interface{}) {
-	rateCols := []string{}
-	params := []interface{}{rate.Id, rate.Rate, rate.Rate, rate.Id}
-	rateQuerys := []string{}
+func (s *ratesService) buildQueryInsertFullInfo(rate *Rates, infoTablesName []string) ([]string, []string, []interface{}) {
+	columns := []string{s.FullInfoIdCol, s.FullInfoRateCol, s.FullCountCol, s.FullScoreCol}
+	values := []string{"?", "?", "1", "?"}
+	params := []interface{}{rate.Id, rate.Rate, rate.Rate}
 	for i := 1; i <= len(infoTablesName); i++ {
-		rateCols = append(rateCols, fmt.Sprintf("%s%d", s.RateCol, i))
-		rateQuerys = append(rateQuerys, fmt.Sprintf("(select avg(%s) from %s where %s = $4 group by %s)",
+		columns = append(columns, fmt.Sprintf("%s%d", s.RateCol, i))
+		values = append(values, fmt.Sprintf("(select avg(%s) from %s where %s = ? group by %s)",
 			s.InfoRateCol, infoTablesName[i-1], s.InfoIdCol, s.InfoIdCol))
+		params = append(params, rate.Id)
 	}
-	query := fmt.Sprintf("insert into %s(%s, %s, %s, %s, %s)values ($1, $2, 1, $3, %s)",
-		fullInfoTableName, s.FullInfoIdCol, s.FullInfoRateCol, s.FullCountCol, s.FullScoreCol,
-		strings.Join(rateCols, ", "), strings.Join(rateQuerys, ","))
-	return query, params
+	return columns, values, params
 }
-func (s *ratesService) buildQueryUpdateFullInfo(rate *Rates, score float32, count int, fullInfoTableName string, infoTablesName []string, index int) (string, []interface{}) {
+func (s *ratesService) buildQueryUpdateFullInfo(rate *Rates, score float32, count int, fullInfoTableName string, infoTablesName []string) ([]string, []interface{}) {
 	if len(infoTablesName) > 0 {
-		ss := []string{}
-		params := []interface{}{}
+		sets := []string{
+			fmt.Sprintf("%s = (%s.%s + ?)/(%s.%s + %d)", s.FullInfoRateCol, fullInfoTableName, s.FullScoreCol, fullInfoTableName, s.FullCountCol, count),
+			fmt.Sprintf("%s = %s.%s + ?", s.FullScoreCol, fullInfoTableName, s.FullScoreCol),
+			fmt.Sprintf("%s = %s.%s + %d", s.FullCountCol, fullInfoTableName, s.FullCountCol, count),
+		}
+		params := []interface{}{score, score}
 		for i := 1; i <= len(infoTablesName); i++ {
-			ss = append(ss, fmt.Sprintf("%s%d = (select avg(%s) from %s where %s = $%d group by %s)", s.InfoRateCol, i, s.InfoRateCol, infoTablesName[i-1],
-				s.InfoIdCol, index+1, s.InfoIdCol))
+			sets = append(sets, fmt.Sprintf("%s%d = (select avg(%s) from %s where %s = ? group by %s)", s.InfoRateCol, i, s.InfoRateCol, infoTablesName[i-1],
+				s.InfoIdCol, s.InfoIdCol))
+			params = append(params, rate.Id)
 		}
-
-		sql := fmt.Sprintf("update set %[1]s = (%[2]s.%[3]s + $%[4]d)/(%[2]s.%[5]s + %[11]d), %[6]s = %[2]s.%[7]s + $%[4]d, %[8]s = %[2]s.%[9]s + %[11]d, %[10]s",
-			s.FullInfoRateCol, fullInfoTableName, s.FullScoreCol, index, s.FullCountCol, s.FullScoreCol,
-			s.FullScoreCol, s.FullCountCol, s.FullCountCol, strings.Join(ss, ", "), count)
-		params = append(params, score, rate.Id)
-		return sql, params
+		return sets, params
 	}
-	return "", nil
+	return nil, nil
 }
-func (s *ratesService) ExecBatch(ctx context.Context, stmts []string, params [][]interface{}) (int64, error) {
+func (s *ratesService) ExecBatch(ctx context.Context, tx *sql.Tx, stmts []string, params [][]interface{}) (int64, error) {
 	var rowResult int64
 	rowResult = 0
-	tx, err := s.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return -1, err
-	}
-
-	defer tx.Rollback()
-
 	for index, _ := range stmts {
 		stmt, err := tx.PrepareContext(ctx, stmts[index])
 		if err != nil {
@@ -285,11 +309,11 @@ func (s *ratesService) ExecBatch(ctx context.Context, stmts []string, params [][
 			return -1, err
 		}
 		rowAffected, err := res.RowsAffected()
+		if err != nil {
+			return -1, err
+		}
 		rowResult += rowAffected
 	}
-	if err = tx.Commit(); err != nil {
-		return -1, err
-	}
 	return rowResult, nil
 }
 func sum(arr []float32) float32 {
@@ -11,3 +11,52 @@ type Reaction struct {
 	Time   *time.Time `json:"time,omitempty" gorm:"column:time" bson:"time,omitempty" dynamodbav:"time,omitempty" firestore:"time,omitempty"`
 	Type   int8       `json:"type,omitempty" gorm:"column:type" bson:"type,omitempty" dynamodbav:"type,omitempty" firestore:"type,omitempty" validate:"max=10"`
 }
+
+type Status string
+
+const (
+	Created        Status = "created"
+	AlreadyExisted Status = "already_existed"
+	Changed        Status = "changed"
+	Removed        Status = "removed"
+	NotFound       Status = "not_found"
+)
+
+type Result struct {
+	Status Status `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+type Kind struct {
+	Type   int8   `json:"type"`
+	Name   string `json:"name"`
+	Column string `json:"column"`
+}
+
+// MaxLimit is the maximum number of reactors of a page of ListReactors.
+const MaxLimit = 100
+
+type Reactor struct {
+	UserId string     `json:"userId,omitempty"`
+	Type   int8       `json:"type,omitempty"`
+	Time   *time.Time `json:"time,omitempty"`
+	Name   *string    `json:"name,omitempty"`
+	Url    *string    `json:"url,omitempty"`
+}
+
+type Reactors struct {
+	List  []Reactor `json:"list"`
+	Total int64     `json:"total"`
+	Next  string    `json:"next,omitempty"`
+}
+
+type Key struct {
+	Id     string `json:"id"`
+	Author string `json:"author"`
+}
+
+type State struct {
+	Id     string `json:"id"`
+	Author string `json:"author"`
+	Type   int8   `json:"type"`
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/core-go/reaction/block"
 )
 
 func NewReactionHandler(
@@ -47,11 +50,27 @@ func (h *ReactionHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	result, er3 := h.service.Insert(r.Context(), &reaction)
 	if er3 != nil {
+		if er3 == ErrInvalidType {
+			http.Error(w, er3.Error(), http.StatusBadRequest)
+			return
+		}
+		if er3 == block.ErrBlocked {
+			http.Error(w, er3.Error(), http.StatusForbidden)
+			return
+		}
+		if er3 == ErrConflict {
+			http.Error(w, er3.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, er3.Error(), 500)
 		return
 	}
+	status := http.StatusOK
+	if result.Status == Created {
+		status = http.StatusCreated
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(200)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(result)
 	return
 
@@ -82,10 +101,86 @@ func (h *ReactionHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if result.Status == NotFound {
+		http.Error(w, "reaction not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+func (h *ReactionHandler) Summary(w http.ResponseWriter, r *http.Request) {
+	author := GetRequiredParam(w, r, h.authorIndex)
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) > 0 && len(author) > 0 {
+		result, err := h.service.Summary(r.Context(), id, author)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		json.NewEncoder(w).Encode(result)
+	}
+}
+func (h *ReactionHandler) ListReactors(w http.ResponseWriter, r *http.Request) {
+	author := GetRequiredParam(w, r, h.authorIndex)
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 || len(author) == 0 {
+		return
+	}
+	query := r.URL.Query()
+	var kind int64
+	var limit int64
+	var err error
+	if k := query.Get("kind"); len(k) > 0 {
+		kind, err = strconv.ParseInt(k, 10, 8)
+		if err != nil {
+			http.Error(w, "invalid kind", http.StatusBadRequest)
+			return
+		}
+	}
+	if l := query.Get("limit"); len(l) > 0 {
+		limit, err = strconv.ParseInt(l, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	result, err := h.service.ListReactors(r.Context(), id, author, int8(kind), query.Get("cursor"), limit)
+	if err != nil {
+		if err == ErrInvalidCursor {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	json.NewEncoder(w).Encode(result)
-	return
+}
+func (h *ReactionHandler) CheckReactions(w http.ResponseWriter, r *http.Request) {
+	var keys []Key
+	er1 := Decode(w, r, &keys)
+	if er1 != nil {
+		return
+	}
+	userId := GetRequiredParam(w, r, h.userIdIndex)
+	if len(userId) == 0 {
+		return
+	}
+	result, err := h.service.CheckReactions(r.Context(), userId, keys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	states := make([]State, 0, len(keys))
+	for _, k := range keys {
+		states = append(states, State{Id: k.Id, Author: k.Author, Type: result[k]})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(states)
 }
 func GetParam(r *http.Request, options ...int) string {
 	offset := 0
@@ -3,12 +3,76 @@ package reaction
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
+	"github.com/core-go/reaction/paging"
+)
+
+var (
+	ErrInvalidType = errors.New("invalid reaction type")
+	// ErrConflict is returned when the reaction was changed by a concurrent request, which can be retried.
+	ErrConflict      = errors.New("the reaction was changed concurrently")
+	ErrInvalidCursor = paging.ErrInvalidCursor
 )
 
 type ReactionService interface {
-	Insert(ctx context.Context, reaction *Reaction) (int64, error)
-	Delete(ctx context.Context, reaction *Reaction) (int64, error)
+	Insert(ctx context.Context, reaction *Reaction) (*Result, error)
+	Delete(ctx context.Context, reaction *Reaction) (*Result, error)
+	Summary(ctx context.Context, id string, author string) (map[string]int64, error)
+	ListReactors(ctx context.Context, id string, author string, kind int8, cursor string, limit int64) (*Reactors, error)
+	CheckReactions(ctx context.Context, userId string, keys []Key) (map[Key]int8, error)
+}
+
+// Option configures the optional features of the reaction service.
+type Option func(*reactionService)
+
+// WithKinds adds reaction kinds, each counted in its own column of the rate table. The type 1 replaces the default useful kind.
+func WithKinds(kinds ...Kind) Option {
+	return func(s *reactionService) {
+		for _, k := range kinds {
+			if k.Type == 1 {
+				s.Kinds[0] = k
+			} else {
+				s.Kinds = append(s.Kinds, k)
+			}
+		}
+	}
+}
+
+// WithQueryInfo enriches the reactors of ListReactors with the names and the urls of the users.
+func WithQueryInfo(queryInfo func(ids []string) ([]Info, error)) Option {
+	return func(s *reactionService) {
+		s.QueryInfo = queryInfo
+	}
+}
+
+// WithToArray binds the ids of CheckReactions as one array parameter on Postgres.
+func WithToArray(toArray func(interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+}) Option {
+	return func(s *reactionService) {
+		s.ToArray = toArray
+	}
+}
+
+func WithGuard(guard block.Guard) Option {
+	return func(s *reactionService) {
+		s.Guard = guard
+	}
+}
+
+func WithPublishers(publishers ...outbox.Publisher) Option {
+	return func(s *reactionService) {
+		s.Publishers = append(s.Publishers, publishers...)
+	}
 }
 
 func NewReactionService(
@@ -23,8 +87,9 @@ func NewReactionService(
 	rateId string,
 	rateAuthor string,
 	usefulCount string,
+	options ...Option,
 ) ReactionService {
-	return &reactionService{
+	s := &reactionService{
 		DB:          db,
 		Table:       table,
 		Id:          id,
@@ -36,7 +101,13 @@ func NewReactionService(
 		RateId:      rateId,
 		RateAuthor:  rateAuthor,
 		UsefulCount: usefulCount,
+		Kinds:       []Kind{{Type: 1, Name: "useful", Column: usefulCount}},
+		Dialect:     dialect.New(db),
 	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
 }
 
 type reactionService struct {
@@ -51,50 +122,317 @@ type reactionService struct {
 	RateId      string
 	RateAuthor  string
 	UsefulCount string
+	Kinds       []Kind
+	QueryInfo   func(ids []string) ([]Info, error)
+	ToArray     func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	}
+	Guard      block.Guard
+	Dialect    dialect.Dialect
+	Publishers []outbox.Publisher
 }
 
-func (s *reactionService) Insert(ctx context.Context, reaction *Reaction) (int64, error) {
-	query1 := fmt.Sprintf("insert into %s(%s , %s, %s, %s,  %s) values ($1, $2, $3, $4, $5)",
-		s.Table, s.Id, s.Author, s.UserId, s.Time, s.Reaction)
-	stmt1, err := s.DB.Prepare(query1)
+func (s *reactionService) Insert(ctx context.Context, reaction *Reaction) (*Result, error) {
+	column, ok := s.column(reaction.Type)
+	if !ok {
+		return nil, ErrInvalidType
+	}
+	if err := block.Check(ctx, s.Guard, reaction.Author, reaction.UserId); err != nil {
+		return nil, err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
-	res1, err := stmt1.ExecContext(ctx, reaction.Id, reaction.Author, reaction.UserId, reaction.Time, reaction.Type)
+	defer tx.Rollback()
+	old, exist, err := s.load(ctx, tx, reaction.Id, reaction.Author, reaction.UserId)
 	if err != nil {
-		return -1, err
+		return nil, err
+	}
+	result := &Result{Status: AlreadyExisted}
+	if !exist {
+		query1 := s.Dialect.Rebind(s.Dialect.Upsert(s.Table,
+			[]string{s.Id, s.Author, s.UserId, s.Time, s.Reaction},
+			[]string{"?", "?", "?", "?", "?"},
+			[]string{s.Id, s.Author, s.UserId}, nil))
+		res1, err := tx.ExecContext(ctx, query1, reaction.Id, reaction.Author, reaction.UserId, reaction.Time, reaction.Type)
+		if err != nil {
+			return nil, err
+		}
+		r, err := res1.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if r > 0 {
+			result.Status = Created
+			query2 := s.Dialect.Rebind(fmt.Sprintf("update %s set %s = %s + 1 where %s = ? and %s = ?",
+				s.RateTable, column, column, s.RateId, s.RateAuthor))
+			_, err = tx.ExecContext(ctx, query2, reaction.Id, reaction.Author)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else if old != reaction.Type {
+		// The reaction is switched only if it is still old, so that a concurrent switch does not move the counters twice.
+		query1 := s.Dialect.Rebind(fmt.Sprintf("update %s set %s = ?, %s = ? where %s = ? and %s = ? and %s = ? and %s = ?",
+			s.Table, s.Reaction, s.Time, s.Id, s.Author, s.UserId, s.Reaction))
+		res1, err := tx.ExecContext(ctx, query1, reaction.Type, reaction.Time, reaction.Id, reaction.Author, reaction.UserId, old)
+		if err != nil {
+			return nil, err
+		}
+		r, err := res1.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if r != 1 {
+			return nil, ErrConflict
+		}
+		result.Status = Changed
+		query2 := fmt.Sprintf("update %s set %s = %s + 1", s.RateTable, column, column)
+		if oldColumn, ok := s.column(old); ok {
+			query2 += fmt.Sprintf(", %s = %s - 1", oldColumn, oldColumn)
+		}
+		query2 += fmt.Sprintf(" where %s = ? and %s = ?", s.RateId, s.RateAuthor)
+		_, err = tx.ExecContext(ctx, s.Dialect.Rebind(query2), reaction.Id, reaction.Author)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result.Count, err = s.count(ctx, tx, column, reaction.Id, reaction.Author)
+	if err != nil {
+		return nil, err
+	}
+	switch result.Status {
+	case Created:
+		err = outbox.Publish(ctx, tx, s.Publishers, outbox.ReactionAdded{Id: reaction.Id, Author: reaction.Author, UserId: reaction.UserId, Type: reaction.Type, Time: time.Now()})
+	case Changed:
+		err = outbox.Publish(ctx, tx, s.Publishers, outbox.ReactionChanged{Id: reaction.Id, Author: reaction.Author, UserId: reaction.UserId, OldType: old, Type: reaction.Type, Time: time.Now()})
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	query2 := fmt.Sprintf("update %s set %s = %s.%s + 1 where %s = $1 and %s = $2;",
-		s.RateTable, s.UsefulCount, s.RateTable, s.UsefulCount, s.RateId, s.RateAuthor)
-	stmt2, err := s.DB.Prepare(query2)
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *reactionService) Delete(ctx context.Context, reaction *Reaction) (*Result, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return -1, err
+		return nil, err
+	}
+	defer tx.Rollback()
+	old, exist, err := s.load(ctx, tx, reaction.Id, reaction.Author, reaction.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return &Result{Status: NotFound}, nil
+	}
+	query1 := s.Dialect.Rebind(fmt.Sprintf("delete from %s where %s = ? and %s = ? and %s = ? and %s = ?",
+		s.Table, s.Id, s.Author, s.UserId, s.Reaction))
+	res1, err := tx.ExecContext(ctx, query1, reaction.Id, reaction.Author, reaction.UserId, old)
+	if err != nil {
+		return nil, err
+	}
+	r, err := res1.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if r == 0 {
+		return &Result{Status: NotFound}, nil
+	}
+	result := &Result{Status: Removed}
+	column, ok := s.column(old)
+	if ok {
+		query2 := s.Dialect.Rebind(fmt.Sprintf("update %s set %s = %s - 1 where %s = ? and %s = ?",
+			s.RateTable, column, column, s.RateId, s.RateAuthor))
+		_, err = tx.ExecContext(ctx, query2, reaction.Id, reaction.Author)
+		if err != nil {
+			return nil, err
+		}
+		result.Count, err = s.count(ctx, tx, column, reaction.Id, reaction.Author)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = outbox.Publish(ctx, tx, s.Publishers, outbox.ReactionRemoved{Id: reaction.Id, Author: reaction.Author, UserId: reaction.UserId, Type: old, Time: time.Now()})
+	if err != nil {
+		return nil, err
 	}
-	stmt2.ExecContext(ctx, reaction.Id, reaction.Author)
 
-	return res1.RowsAffected()
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *reactionService) Summary(ctx context.Context, id string, author string) (map[string]int64, error) {
+	columns := make([]string, len(s.Kinds))
+	counts := make([]sql.NullInt64, len(s.Kinds))
+	values := make([]interface{}, len(s.Kinds))
+	for i, k := range s.Kinds {
+		columns[i] = k.Column
+		values[i] = &counts[i]
+	}
+	query := s.Dialect.Rebind(fmt.Sprintf("select %s from %s where %s = ? and %s = ?", strings.Join(columns, ", "), s.RateTable, s.RateId, s.RateAuthor))
+	err := s.DB.QueryRowContext(ctx, query, id, author).Scan(values...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	summary := make(map[string]int64, len(s.Kinds))
+	for i, k := range s.Kinds {
+		summary[k.Name] = counts[i].Int64
+	}
+	return summary, nil
 }
 
-func (s *reactionService) Delete(ctx context.Context, reaction *Reaction) (int64, error) {
-	query1 := fmt.Sprintf("delete from %s where %s = $1 and %s = $2 and %s = $3;",
-		s.Table, s.Id, s.Author, s.UserId)
-	stmt1, err := s.DB.Prepare(query1)
+func (s *reactionService) ListReactors(ctx context.Context, id string, author string, kind int8, cursor string, limit int64) (*Reactors, error) {
+	if limit <= 0 {
+		limit = 20
+	} else if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	where := fmt.Sprintf("%s = ? and %s = ?", s.Id, s.Author)
+	params := []interface{}{id, author}
+	if kind != 0 {
+		params = append(params, kind)
+		where += fmt.Sprintf(" and %s = ?", s.Reaction)
+	}
+	result := &Reactors{List: make([]Reactor, 0)}
+	query1 := s.Dialect.Rebind(fmt.Sprintf("select count(*) from %s where %s", s.Table, where))
+	err := s.DB.QueryRowContext(ctx, query1, params...).Scan(&result.Total)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
-	res1, err := stmt1.ExecContext(ctx, reaction.Id, reaction.Author, reaction.UserId)
+	if len(cursor) > 0 {
+		t, userId, err := paging.DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, t, t, userId)
+		where += fmt.Sprintf(" and (%s < ? or (%s = ? and %s < ?))", s.Time, s.Time, s.UserId)
+	}
+	query2 := s.Dialect.Rebind(fmt.Sprintf("select %s, %s, %s from %s where %s order by %s desc, %s desc %s",
+		s.UserId, s.Reaction, s.Time, s.Table, where, s.Time, s.UserId, s.Dialect.Limit(limit+1)))
+	rows, err := s.DB.QueryContext(ctx, query2, params...)
 	if err != nil {
-		return -1, err
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var reactor Reactor
+		err = rows.Scan(&reactor.UserId, &reactor.Type, &reactor.Time)
+		if err != nil {
+			return nil, err
+		}
+		result.List = append(result.List, reactor)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if int64(len(result.List)) > limit {
+		result.List = result.List[:limit]
+		last := result.List[limit-1]
+		if last.Time != nil {
+			result.Next = paging.EncodeCursor(*last.Time, last.UserId)
+		}
+	}
+	if s.QueryInfo == nil || len(result.List) == 0 {
+		return result, nil
+	}
+	ids := make([]string, 0)
+	for _, r := range result.List {
+		ids = append(ids, r.UserId)
 	}
+	infos, err := s.QueryInfo(ids)
+	if err != nil {
+		return nil, err
+	}
+	for k := range result.List {
+		i := paging.BinarySearch(infos, result.List[k].UserId)
+		if i >= 0 && infos[i].Id == result.List[k].UserId {
+			result.List[k].Url = &infos[i].Url
+			result.List[k].Name = &infos[i].Name
+		}
+	}
+	return result, nil
+}
 
-	query2 := fmt.Sprintf("update %s set %s = %s.%s - 1 where %s = $1 and %s = $2;",
-		s.RateTable, s.UsefulCount, s.RateTable, s.UsefulCount, s.RateId, s.RateAuthor)
-	stmt2, err := s.DB.Prepare(query2)
+func (s *reactionService) CheckReactions(ctx context.Context, userId string, keys []Key) (map[Key]int8, error) {
+	result := make(map[Key]int8)
+	if len(keys) == 0 {
+		return result, nil
+	}
+	requested := make(map[Key]bool, len(keys))
+	ids := make([]string, 0, len(keys))
+	authors := make([]string, 0, len(keys))
+	for _, k := range keys {
+		requested[k] = true
+		ids = append(ids, k.Id)
+		authors = append(authors, k.Author)
+	}
+	inIds, idParams := s.Dialect.InArray(s.Id, paging.Distinct(ids), s.ToArray)
+	inAuthors, authorParams := s.Dialect.InArray(s.Author, paging.Distinct(authors), s.ToArray)
+	query := s.Dialect.Rebind(fmt.Sprintf("select %s, %s, %s from %s where %s = ? and %s and %s",
+		s.Id, s.Author, s.Reaction, s.Table, s.UserId, inIds, inAuthors))
+	params := append([]interface{}{userId}, idParams...)
+	params = append(params, authorParams...)
+	rows, err := s.DB.QueryContext(ctx, query, params...)
 	if err != nil {
-		return -1, err
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k Key
+		var t int8
+		err = rows.Scan(&k.Id, &k.Author, &t)
+		if err != nil {
+			return nil, err
+		}
+		if requested[k] {
+			result[k] = t
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *reactionService) column(t int8) (string, bool) {
+	for _, k := range s.Kinds {
+		if k.Type == t {
+			return k.Column, true
+		}
+	}
+	return "", false
+}
+
+// load returns the type of the reaction of userId, locked until the end of tx.
+func (s *reactionService) load(ctx context.Context, tx *sql.Tx, id string, author string, userId string) (int8, bool, error) {
+	where := fmt.Sprintf("%s = ? and %s = ? and %s = ?", s.Id, s.Author, s.UserId)
+	query := s.Dialect.Rebind(s.Dialect.ForUpdate([]string{s.Reaction}, s.Table, where))
+	var t int8
+	err := tx.QueryRowContext(ctx, query, id, author, userId).Scan(&t)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
 	}
-	stmt2.ExecContext(ctx, reaction.Id, reaction.Author)
+	if err != nil {
+		return 0, false, err
+	}
+	return t, true, nil
+}
 
-	return res1.RowsAffected()
+func (s *reactionService) count(ctx context.Context, tx *sql.Tx, column string, id string, author string) (int64, error) {
+	query := s.Dialect.Rebind(fmt.Sprintf("select %s from %s where %s = ? and %s = ?", column, s.RateTable, s.RateId, s.RateAuthor))
+	var count sql.NullInt64
+	err := tx.QueryRowContext(ctx, query, id, author).Scan(&count)
+	if err != nil && err != sql.ErrNoRows {
+		return -1, err
+	}
+	return count.Int64, nil
 }
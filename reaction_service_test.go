@@ -0,0 +1,161 @@
+package reaction
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestService(t *testing.T, options ...Option) (*sql.DB, ReactionService) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range []string{
+		"create table reactions (id varchar(40), author varchar(40), userid varchar(40), time timestamp, reaction integer, primary key (id, author, userid))",
+		"create table rates (id varchar(40), author varchar(40), usefulcount integer default 0, funnycount integer default 0, primary key (id, author))",
+		"insert into rates(id, author, usefulcount, funnycount) values ('item', 'author', 0, 0)",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	service := NewReactionService(db, "reactions", "id", "author", "userid", "time", "reaction", "rates", "id", "author", "usefulcount", options...)
+	return db, service
+}
+
+// failCounter makes every update of the counter fail, as the second statement of Insert and Delete.
+func failCounter(t *testing.T, db *sql.DB) {
+	t.Helper()
+	if _, err := db.Exec("create trigger fail_counter before update on rates begin select raise(abort, 'counter update failed'); end"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func state(t *testing.T, db *sql.DB) (reactions int, count int) {
+	t.Helper()
+	if err := db.QueryRow("select count(*) from reactions").Scan(&reactions); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("select usefulcount from rates where id = 'item' and author = 'author'").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return reactions, count
+}
+
+func newReaction() *Reaction {
+	now := time.Now()
+	return &Reaction{Id: "item", Author: "author", UserId: "user", Time: &now, Type: 1}
+}
+
+func TestInsertDelete(t *testing.T) {
+	db, service := newTestService(t)
+	ctx := context.Background()
+	result, err := service.Insert(ctx, newReaction())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != Created || result.Count != 1 {
+		t.Errorf("Insert() = %+v, want created with count 1", *result)
+	}
+	result, err = service.Insert(ctx, newReaction())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != AlreadyExisted || result.Count != 1 {
+		t.Errorf("second Insert() = %+v, want already_existed with count 1", *result)
+	}
+	result, err = service.Delete(ctx, newReaction())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != Removed || result.Count != 0 {
+		t.Errorf("Delete() = %+v, want removed with count 0", *result)
+	}
+	if reactions, count := state(t, db); reactions != 0 || count != 0 {
+		t.Errorf("got %d reactions and count %d, want 0 and 0", reactions, count)
+	}
+}
+
+func TestInsertRollsBackWhenCounterFails(t *testing.T) {
+	db, service := newTestService(t)
+	failCounter(t, db)
+	if _, err := service.Insert(context.Background(), newReaction()); err == nil {
+		t.Fatal("Insert() succeeded, want the error of the counter update")
+	}
+	if reactions, count := state(t, db); reactions != 0 || count != 0 {
+		t.Errorf("got %d reactions and count %d, want 0 and 0", reactions, count)
+	}
+}
+
+func TestDeleteRollsBackWhenCounterFails(t *testing.T) {
+	db, service := newTestService(t)
+	ctx := context.Background()
+	if _, err := service.Insert(ctx, newReaction()); err != nil {
+		t.Fatal(err)
+	}
+	failCounter(t, db)
+	if _, err := service.Delete(ctx, newReaction()); err == nil {
+		t.Fatal("Delete() succeeded, want the error of the counter update")
+	}
+	if reactions, count := state(t, db); reactions != 1 || count != 1 {
+		t.Errorf("got %d reactions and count %d, want 1 and 1", reactions, count)
+	}
+}
+
+func TestSwitchKinds(t *testing.T) {
+	db, service := newTestService(t, WithKinds(Kind{Type: 2, Name: "funny", Column: "funnycount"}))
+	ctx := context.Background()
+	insert := func(userId string, kind int8, status Status, count int64) {
+		t.Helper()
+		r := newReaction()
+		r.UserId, r.Type = userId, kind
+		result, err := service.Insert(ctx, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != status || result.Count != count {
+			t.Errorf("Insert(%s, %d) = %+v, want %s with count %d", userId, kind, *result, status, count)
+		}
+	}
+	summary := func(useful int64, funny int64) {
+		t.Helper()
+		got, err := service.Summary(ctx, "item", "author")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got["useful"] != useful || got["funny"] != funny {
+			t.Errorf("Summary() = %v, want %d useful and %d funny", got, useful, funny)
+		}
+	}
+	insert("u1", 1, Created, 1)
+	insert("u2", 1, Created, 2)
+	insert("u3", 2, Created, 1)
+	summary(2, 1)
+	insert("u1", 2, Changed, 2)
+	summary(1, 2)
+	insert("u1", 2, AlreadyExisted, 2)
+	summary(1, 2)
+	insert("u1", 1, Changed, 2)
+	summary(2, 1)
+	r := newReaction()
+	r.UserId = "u3"
+	result, err := service.Delete(ctx, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != Removed || result.Count != 0 {
+		t.Errorf("Delete(u3) = %+v, want removed with count 0 of its kind", *result)
+	}
+	summary(2, 0)
+	var kind int8
+	if err = db.QueryRow("select reaction from reactions where userid = 'u1'").Scan(&kind); err != nil || kind != 1 {
+		t.Errorf("reaction of u1 = %d, %v, want 1", kind, err)
+	}
+}
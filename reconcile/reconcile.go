@@ -0,0 +1,176 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/rate"
+)
+
+// Counter describes a denormalized counter column and how to recompute it from its source table.
+// The counter of the row of Table identified by Keys is Expr of the rows of Source whose SourceKeys are equal to Keys and which match Where.
+// Expr is an aggregate, and defaults to count(*). It may be a sum of floats, as the score of the rates.
+// The helpers below name their counters table.column.
+// If Derive is set, the columns it returns are set in the update which repairs the counter.
+type Counter struct {
+	Name       string   `json:"name"`
+	Table      string   `json:"table"`
+	Keys       []string `json:"keys"`
+	Column     string   `json:"column"`
+	Source     string   `json:"source"`
+	SourceKeys []string `json:"sourceKeys"`
+	Expr       string   `json:"expr,omitempty"`
+	Where      string   `json:"where,omitempty"`
+	Derive     Derive   `json:"-"`
+}
+
+// Derive returns the columns of the row of keys which are computed from the repaired counter, such as an average, with their values.
+type Derive func(ctx context.Context, tx *sql.Tx, d dialect.Dialect, keys []string) ([]string, []interface{}, error)
+
+type Drift struct {
+	Keys     []string `json:"keys"`
+	Actual   float64  `json:"actual"`
+	Expected float64  `json:"expected"`
+}
+
+type Report struct {
+	Counter  string  `json:"counter"`
+	Drift    int64   `json:"drift"`
+	Repaired int64   `json:"repaired"`
+	Samples  []Drift `json:"samples,omitempty"`
+}
+
+// ReactionCounter is the counter of the reactions of type t to the rates, such as usefulCount.
+func ReactionCounter(rateTable string, rateId string, rateAuthor string, column string, reactionTable string, id string, author string, reaction string, t int8) Counter {
+	return Counter{Name: rateTable + "." + column, Table: rateTable, Keys: []string{rateId, rateAuthor}, Column: column,
+		Source: reactionTable, SourceKeys: []string{id, author}, Where: fmt.Sprintf("%s = %d", reaction, t)}
+}
+
+// CommentCounter is the counter of the comments of the rates, such as replyCount.
+func CommentCounter(rateTable string, rateId string, rateAuthor string, column string, commentTable string, id string, author string) Counter {
+	return Counter{Name: rateTable + "." + column, Table: rateTable, Keys: []string{rateId, rateAuthor}, Column: column,
+		Source: commentTable, SourceKeys: []string{id, author}}
+}
+
+// FollowCounters are the follower and following counters of the user info table, with the tables of follow.NewFollowService:
+// the rows of a user in the follower table are the users it follows, and its rows in the following table are its followers.
+func FollowCounters(userInfoTable string, userInfoId string, followerCount string, followingCount string, followerTable string, followerId string, followingTable string, followingId string) []Counter {
+	return []Counter{
+		{Name: userInfoTable + "." + followerCount, Table: userInfoTable, Keys: []string{userInfoId}, Column: followerCount, Source: followingTable, SourceKeys: []string{followingId}},
+		{Name: userInfoTable + "." + followingCount, Table: userInfoTable, Keys: []string{userInfoId}, Column: followingCount, Source: followerTable, SourceKeys: []string{followerId}},
+	}
+}
+
+// RateCounters are the count and score counters of the rate info table, and the bucket counters of the scale,
+// as the rate service of the scale stores them. A histogram stored in the HistogramCol of the scale is not a counter column,
+// so only the count and the score are reconciled.
+// The repair of a counter also sets the average rate and, if ranking is not nil, the ranking scores, recomputed from the rates.
+func RateCounters(infoTable string, infoId string, infoRate string, count string, score string, rateTable string, id string, rateCol string, scale rate.Scale, ranking *rate.Ranking) []Counter {
+	derive := rateDerive(infoRate, rateTable, id, rateCol, scale, ranking)
+	counters := []Counter{
+		{Name: infoTable + "." + count, Table: infoTable, Keys: []string{infoId}, Column: count, Source: rateTable, SourceKeys: []string{id}, Derive: derive},
+		{Name: infoTable + "." + score, Table: infoTable, Keys: []string{infoId}, Column: score, Source: rateTable, SourceKeys: []string{id}, Expr: fmt.Sprintf("sum(%s)", rateCol), Derive: derive},
+	}
+	if len(scale.HistogramCol) > 0 {
+		return counters
+	}
+	for bucket, where := range bucketRanges(scale, rateCol) {
+		column := scale.Column(infoRate, bucket)
+		counters = append(counters, Counter{Name: infoTable + "." + column, Table: infoTable, Keys: []string{infoId}, Column: column,
+			Source: rateTable, SourceKeys: []string{id}, Where: where, Derive: derive})
+	}
+	return counters
+}
+
+// rateDerive recomputes the average rate and the ranking scores of an item from its rates, rather than from the rate info,
+// so that they are right whichever counter of the item is repaired first. The rates out of the scale are not in the histogram.
+func rateDerive(infoRate string, rateTable string, id string, rateCol string, scale rate.Scale, ranking *rate.Ranking) Derive {
+	return func(ctx context.Context, tx *sql.Tx, d dialect.Dialect, keys []string) ([]string, []interface{}, error) {
+		query := d.Rebind(fmt.Sprintf("select %s from %s where %s = ?", rateCol, rateTable, id))
+		rows, err := tx.QueryContext(ctx, query, keys[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		defer rows.Close()
+		info := rate.RateInfo{Id: keys[0], Histogram: make([]int, scale.Size())}
+		for rows.Next() {
+			var r float64
+			if err = rows.Scan(&r); err != nil {
+				return nil, nil, err
+			}
+			info.Count++
+			info.Score += r
+			if bucket, err := scale.BucketOf(float32(r)); err == nil {
+				info.Histogram[bucket]++
+			}
+		}
+		if err = rows.Err(); err != nil {
+			return nil, nil, err
+		}
+		if info.Count > 0 {
+			info.Rate = float32(info.Score / float64(info.Count))
+		}
+		columns, values := []string{infoRate}, []interface{}{info.Rate}
+		if ranking != nil {
+			ranking.Rank(&info)
+			if len(ranking.BayesianCol) > 0 {
+				columns, values = append(columns, ranking.BayesianCol), append(values, info.Bayesian)
+			}
+			if len(ranking.WilsonCol) > 0 {
+				columns, values = append(columns, ranking.WilsonCol), append(values, info.Wilson)
+			}
+		}
+		return columns, values, nil
+	}
+}
+
+// bucketRanges returns the condition on the rate of each bucket. The rates are compared within half a step, since they may be stored as floats.
+func bucketRanges(scale rate.Scale, rateCol string) []string {
+	half := scale.Step / 2
+	if half <= 0 {
+		half = 0.5
+	}
+	ranges := make([][]string, scale.Size())
+	for _, r := range scale.Rates() {
+		bucket, err := scale.BucketOf(r)
+		if err != nil {
+			continue
+		}
+		ranges[bucket] = append(ranges[bucket], fmt.Sprintf("(%s > %s and %s < %s)", rateCol, literal(r-half), rateCol, literal(r+half)))
+	}
+	where := make([]string, len(ranges))
+	for i, r := range ranges {
+		if len(r) == 0 {
+			where[i] = "1 = 0"
+		} else {
+			where[i] = strings.Join(r, " or ")
+		}
+	}
+	return where
+}
+
+// literal formats the rate for a SQL expression, with the negative rates in parentheses.
+func literal(rate float32) string {
+	v := strconv.FormatFloat(float64(rate), 'f', -1, 32)
+	if rate < 0 {
+		return "(" + v + ")"
+	}
+	return v
+}
+
+// UserReactionCounters are the reaction counter and the level counters of the user info table.
+func UserReactionCounters(userInfoTable string, infoId string, reactionCount string, prefix string, suffix string, levels int, userReactionTable string, id string, reaction string) []Counter {
+	counters := []Counter{
+		{Name: userInfoTable + "." + reactionCount, Table: userInfoTable, Keys: []string{infoId}, Column: reactionCount, Source: userReactionTable, SourceKeys: []string{id}},
+	}
+	for i := 1; i <= levels; i++ {
+		column := fmt.Sprintf("%s%d%s", prefix, i, suffix)
+		counters = append(counters, Counter{Name: userInfoTable + "." + column, Table: userInfoTable, Keys: []string{infoId}, Column: column,
+			Source: userReactionTable, SourceKeys: []string{id}, Where: fmt.Sprintf("%s = %d", reaction, i)})
+	}
+	return counters
+}
@@ -0,0 +1,65 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+func NewReconcileHandler(service ReconcileService) ReconcileHandler {
+	return ReconcileHandler{service: service}
+}
+
+type ReconcileHandler struct {
+	service ReconcileService
+}
+
+func (h *ReconcileHandler) Counters(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.service.Counters())
+}
+
+func (h *ReconcileHandler) Check(w http.ResponseWriter, r *http.Request) {
+	reports, err := h.service.Check(r.Context(), GetCounters(r)...)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reports)
+}
+
+func (h *ReconcileHandler) Repair(w http.ResponseWriter, r *http.Request) {
+	reports, err := h.service.Repair(r.Context(), GetCounters(r)...)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reports)
+}
+
+// GetCounters returns the counter names of the comma separated query parameter "counter".
+func GetCounters(r *http.Request) []string {
+	names := make([]string, 0)
+	for _, v := range r.URL.Query()["counter"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func handleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrUnknownCounter) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, code int, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(result)
+}
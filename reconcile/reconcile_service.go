@@ -0,0 +1,260 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/core-go/reaction/dialect"
+)
+
+var ErrUnknownCounter = errors.New("unknown counter")
+
+const tolerance = "0.0001"
+
+type ReconcileService interface {
+	Counters() []Counter
+	Check(ctx context.Context, names ...string) ([]Report, error)
+	Repair(ctx context.Context, names ...string) ([]Report, error)
+}
+
+func NewReconcileService(db *sql.DB, batchSize int64, counters ...Counter) ReconcileService {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	return &reconcileService{
+		DB:        db,
+		BatchSize: batchSize,
+		counters:  counters,
+		Dialect:   dialect.New(db),
+	}
+}
+
+type reconcileService struct {
+	DB        *sql.DB
+	BatchSize int64
+	counters  []Counter
+	Dialect   dialect.Dialect
+}
+
+func (s *reconcileService) Counters() []Counter {
+	return s.counters
+}
+
+// Check reports the drift of the counters, with at most BatchSize samples per counter.
+// If names is empty, all counters are checked.
+func (s *reconcileService) Check(ctx context.Context, names ...string) ([]Report, error) {
+	counters, err := s.find(names)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]Report, 0, len(counters))
+	for _, c := range counters {
+		report := Report{Counter: c.Name}
+		from := s.from(c)
+		err = s.DB.QueryRowContext(ctx, fmt.Sprintf("select count(*) %s", from)).Scan(&report.Drift)
+		if err != nil {
+			return nil, err
+		}
+		if report.Drift > 0 {
+			report.Samples, err = s.drifts(ctx, s.DB, c, from, nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// Repair sets the drifted counters to their recomputed values, BatchSize rows per transaction.
+// Each batch starts after the keys of the previous one, so that the rows which still drift after their update,
+// such as the rows changed back by a trigger, are not loaded again.
+// If names is empty, all counters are repaired.
+func (s *reconcileService) Repair(ctx context.Context, names ...string) ([]Report, error) {
+	counters, err := s.find(names)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]Report, 0, len(counters))
+	for _, c := range counters {
+		report := Report{Counter: c.Name}
+		var last []string
+		for {
+			drifts, repaired, err := s.repair(ctx, c, last)
+			if err != nil {
+				return nil, err
+			}
+			report.Drift += int64(len(drifts))
+			report.Repaired += repaired
+			if len(report.Samples) < int(s.BatchSize) {
+				report.Samples = append(report.Samples, drifts...)
+				if len(report.Samples) > int(s.BatchSize) {
+					report.Samples = report.Samples[:s.BatchSize]
+				}
+			}
+			if int64(len(drifts)) < s.BatchSize {
+				break
+			}
+			last = drifts[len(drifts)-1].Keys
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func (s *reconcileService) repair(ctx context.Context, c Counter, last []string) ([]Drift, int64, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+	drifts, err := s.drifts(ctx, tx, c, s.from(c), last)
+	if err != nil {
+		return nil, 0, err
+	}
+	where := make([]string, len(c.Keys))
+	for i, k := range c.Keys {
+		where[i] = k + " = ?"
+	}
+	var repaired int64
+	for _, d := range drifts {
+		sets := []string{c.Column + " = ?"}
+		params := []interface{}{value(d.Expected)}
+		if c.Derive != nil {
+			columns, values, err := c.Derive(ctx, tx, s.Dialect, d.Keys)
+			if err != nil {
+				return nil, 0, err
+			}
+			for _, column := range columns {
+				sets = append(sets, column+" = ?")
+			}
+			params = append(params, values...)
+		}
+		for _, k := range d.Keys {
+			params = append(params, k)
+		}
+		query := s.Dialect.Rebind(fmt.Sprintf("update %s set %s where %s", c.Table, strings.Join(sets, ", "), strings.Join(where, " and ")))
+		res, err := tx.ExecContext(ctx, query, params...)
+		if err != nil {
+			return nil, 0, err
+		}
+		r, err := res.RowsAffected()
+		if err != nil {
+			return nil, 0, err
+		}
+		repaired += r
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, 0, err
+	}
+	return drifts, repaired, nil
+}
+
+// value returns the counter as an integer if it is one, so that it can be set to an integer column.
+func value(counter float64) interface{} {
+	if counter == math.Trunc(counter) {
+		return int64(counter)
+	}
+	return counter
+}
+
+func (s *reconcileService) drifts(ctx context.Context, db interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}, c Counter, from string, last []string) ([]Drift, error) {
+	columns := make([]string, len(c.Keys))
+	for i, k := range c.Keys {
+		columns[i] = "c." + k
+	}
+	keys := strings.Join(columns, ", ")
+	var params []interface{}
+	if len(last) > 0 {
+		var seek string
+		seek, params = after(columns, last)
+		from += " and " + seek
+	}
+	query := s.Dialect.Rebind(fmt.Sprintf("select %s, coalesce(c.%s, 0), coalesce(s.n, 0) %s order by %s %s", keys, c.Column, from, keys, s.Dialect.Limit(s.BatchSize)))
+	rows, err := db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	drifts := make([]Drift, 0)
+	for rows.Next() {
+		d := Drift{Keys: make([]string, len(c.Keys))}
+		values := make([]interface{}, 0, len(c.Keys)+2)
+		for i := range d.Keys {
+			values = append(values, &d.Keys[i])
+		}
+		values = append(values, &d.Actual, &d.Expected)
+		if err = rows.Scan(values...); err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, d)
+	}
+	return drifts, rows.Err()
+}
+
+// after returns the condition of the rows whose keys come after the values in the order of the keys, with its parameters.
+func after(keys []string, values []string) (string, []interface{}) {
+	ors := make([]string, len(keys))
+	params := make([]interface{}, 0)
+	for i := range keys {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, keys[j]+" = ?")
+			params = append(params, values[j])
+		}
+		ands = append(ands, keys[i]+" > ?")
+		params = append(params, values[i])
+		ors[i] = "(" + strings.Join(ands, " and ") + ")"
+	}
+	return "(" + strings.Join(ors, " or ") + ")", params
+}
+
+// from builds the from clause joining the counter table to the counters recomputed from the source table,
+// filtered to the rows whose counter drifted.
+func (s *reconcileService) from(c Counter) string {
+	expr := c.Expr
+	if len(expr) == 0 {
+		expr = "count(*)"
+	}
+	selects := make([]string, len(c.SourceKeys))
+	on := make([]string, len(c.Keys))
+	for i, k := range c.SourceKeys {
+		selects[i] = fmt.Sprintf("%s as k%d", k, i)
+		on[i] = fmt.Sprintf("c.%s = s.k%d", c.Keys[i], i)
+	}
+	source := fmt.Sprintf("select %s, %s as n from %s", strings.Join(selects, ", "), expr, c.Source)
+	if len(c.Where) > 0 {
+		source += " where " + c.Where
+	}
+	source += " group by " + strings.Join(c.SourceKeys, ", ")
+	// The counters are compared with a tolerance, for the sums of floats.
+	return fmt.Sprintf("from %s c left join (%s) s on %s where abs(coalesce(c.%s, 0) - coalesce(s.n, 0)) > %s",
+		c.Table, source, strings.Join(on, " and "), c.Column, tolerance)
+}
+
+func (s *reconcileService) find(names []string) ([]Counter, error) {
+	if len(names) == 0 {
+		return s.counters, nil
+	}
+	counters := make([]Counter, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, c := range s.counters {
+			if c.Name == name {
+				counters = append(counters, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCounter, name)
+		}
+	}
+	return counters, nil
+}
@@ -0,0 +1,254 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/core-go/reaction/rate"
+)
+
+func openSqlite(t *testing.T, stmts ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range stmts {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// halfStars is the scale of the rates 0.5 to 2 by 0.5, in the columns rate1 to rate4.
+var halfStars = rate.Scale{Min: 0.5, Max: 2, Step: 0.5}
+
+var ranking = &rate.Ranking{BayesianCol: "bayesian", WilsonCol: "wilson", Prior: 1, Weight: 2}
+
+func newRateService(t *testing.T) (*sql.DB, ReconcileService) {
+	db := openSqlite(t,
+		"create table rates (id varchar(40), author varchar(40), rate real, primary key (id, author))",
+		"create table rateinfo (id varchar(40) primary key, rate real default 0, rate1 integer default 0, rate2 integer default 0, rate3 integer default 0, rate4 integer default 0, count integer default 0, score real default 0, bayesian real default 0, wilson real default 0)",
+		"insert into rates(id, author, rate) values ('item', 'a', 0.5), ('item', 'b', 1.5), ('item', 'c', 1.5)",
+		// The counters drifted: the score is 3.5 and the histogram is 1, 0, 2, 0.
+		"insert into rateinfo(id, rate, rate1, rate2, rate3, rate4, count, score, bayesian, wilson) values ('item', 1, 0, 1, 2, 0, 3, 3, 1, 0)",
+	)
+	counters := RateCounters("rateinfo", "id", "rate", "count", "score", "rates", "id", "rate", halfStars, ranking)
+	return db, NewReconcileService(db, 10, counters...)
+}
+
+func TestRateCounters(t *testing.T) {
+	counters := RateCounters("rateinfo", "id", "rate", "count", "score", "rates", "id", "rate", halfStars, nil)
+	if len(counters) != 6 || counters[2].Column != "rate1" || counters[5].Column != "rate4" {
+		t.Fatalf("RateCounters() = %+v, want count, score and rate1 to rate4", counters)
+	}
+	histogram := halfStars
+	histogram.HistogramCol = "histogram"
+	if counters = RateCounters("rateinfo", "id", "rate", "count", "score", "rates", "id", "rate", histogram, nil); len(counters) != 2 {
+		t.Errorf("RateCounters() with a histogram column = %+v, want count and score", counters)
+	}
+}
+
+func TestRepairRateCounters(t *testing.T) {
+	db, service := newRateService(t)
+	ctx := context.Background()
+	reports, err := service.Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drifts := make(map[string]Report)
+	for _, r := range reports {
+		drifts[r.Counter] = r
+	}
+	if drifts["rateinfo.count"].Drift != 0 || drifts["rateinfo.score"].Drift != 1 || drifts["rateinfo.rate1"].Drift != 1 ||
+		drifts["rateinfo.rate2"].Drift != 1 || drifts["rateinfo.rate3"].Drift != 0 {
+		t.Errorf("Check() = %+v, want the drift of score, rate1 and rate2", reports)
+	}
+	if score := drifts["rateinfo.score"]; len(score.Samples) != 1 || score.Samples[0].Actual != 3 || score.Samples[0].Expected != 3.5 {
+		t.Errorf("score drift = %+v, want 3 instead of 3.5", score.Samples)
+	}
+	if _, err = service.Repair(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var score, average, bayesian, wilson float64
+	var rate1, rate2, rate3 int
+	if err = db.QueryRow("select score, rate1, rate2, rate3, rate, bayesian, wilson from rateinfo where id = 'item'").
+		Scan(&score, &rate1, &rate2, &rate3, &average, &bayesian, &wilson); err != nil {
+		t.Fatal(err)
+	}
+	if score != 3.5 || rate1 != 1 || rate2 != 0 || rate3 != 2 {
+		t.Errorf("repaired score %v and histogram %d, %d, %d, want 3.5 and 1, 0, 2", score, rate1, rate2, rate3)
+	}
+	// The average and the ranking scores are recomputed with the counters.
+	info := rate.RateInfo{Count: 3, Score: 3.5, Histogram: []int{1, 0, 2, 0}}
+	ranking.Rank(&info)
+	if math.Abs(average-3.5/3) > 1e-6 || math.Abs(bayesian-info.Bayesian) > 1e-9 || math.Abs(wilson-info.Wilson) > 1e-9 {
+		t.Errorf("repaired rate %v, bayesian %v and wilson %v, want %v, %v and %v", average, bayesian, wilson, 3.5/3, info.Bayesian, info.Wilson)
+	}
+	if reports, err = service.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range reports {
+		if r.Drift != 0 {
+			t.Errorf("Check() after Repair() = %+v, want no drift", r)
+		}
+	}
+}
+
+// drifts returns the number of drifted rows per counter.
+func drifts(t *testing.T, service ReconcileService) map[string]int64 {
+	t.Helper()
+	reports, err := service.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(map[string]int64)
+	for _, r := range reports {
+		result[r.Counter] = r.Drift
+	}
+	return result
+}
+
+// values returns the integer columns of the row of the table which matches where.
+func values(t *testing.T, db *sql.DB, table string, columns string, where string) []int64 {
+	t.Helper()
+	rows, err := db.Query("select " + columns + " from " + table + " where " + where)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make([]int64, len(names))
+	if !rows.Next() {
+		t.Fatalf("no row in %s where %s", table, where)
+	}
+	pointers := make([]interface{}, len(result))
+	for i := range result {
+		pointers[i] = &result[i]
+	}
+	if err = rows.Scan(pointers...); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestRepairFollowCounters(t *testing.T) {
+	db := openSqlite(t,
+		"create table follower (id varchar(40), follower varchar(40), primary key (id, follower))",
+		"create table following (id varchar(40), following varchar(40), primary key (id, following))",
+		"create table userinfo (id varchar(40) primary key, followercount integer default 0, followingcount integer default 0)",
+		// a and c follow b.
+		"insert into follower(id, follower) values ('a', 'b'), ('c', 'b')",
+		"insert into following(id, following) values ('b', 'a'), ('b', 'c')",
+		// The follower counter of b and z and the following counter of a drifted.
+		"insert into userinfo(id, followercount, followingcount) values ('a', 0, 0), ('b', 5, 0), ('c', 0, 1), ('z', 3, 0)",
+	)
+	service := NewReconcileService(db, 10, FollowCounters("userinfo", "id", "followercount", "followingcount", "follower", "id", "following", "id")...)
+	if d := drifts(t, service); d["userinfo.followercount"] != 2 || d["userinfo.followingcount"] != 1 {
+		t.Errorf("Check() = %v, want 2 follower counters and 1 following counter", d)
+	}
+	if _, err := service.Repair(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]int64{"a": {0, 1}, "b": {2, 0}, "c": {0, 1}, "z": {0, 0}}
+	for id, counters := range want {
+		if got := values(t, db, "userinfo", "followercount, followingcount", "id = '"+id+"'"); got[0] != counters[0] || got[1] != counters[1] {
+			t.Errorf("counters of %s = %v, want %v", id, got, counters)
+		}
+	}
+}
+
+func TestRepairReactionAndCommentCounters(t *testing.T) {
+	db := openSqlite(t,
+		"create table rates (id varchar(40), author varchar(40), usefulcount integer default 0, replycount integer default 0, primary key (id, author))",
+		"create table reactions (id varchar(40), author varchar(40), userid varchar(40), reaction integer, primary key (id, author, userid))",
+		"create table comments (commentid varchar(40) primary key, id varchar(40), author varchar(40))",
+		"insert into rates(id, author, usefulcount, replycount) values ('i1', 'a', 0, 0), ('i1', 'b', 2, 1), ('i2', 'a', 1, 0)",
+		"insert into reactions(id, author, userid, reaction) values ('i1', 'a', 'u1', 1), ('i1', 'a', 'u2', 1), ('i1', 'a', 'u3', 2), ('i1', 'b', 'u1', 1)",
+		"insert into comments(commentid, id, author) values ('c1', 'i1', 'a'), ('c2', 'i2', 'a')",
+	)
+	// One row per batch, so that the batches seek by the two keys.
+	service := NewReconcileService(db, 1,
+		ReactionCounter("rates", "id", "author", "usefulcount", "reactions", "id", "author", "reaction", 1),
+		CommentCounter("rates", "id", "author", "replycount", "comments", "id", "author"))
+	if d := drifts(t, service); d["rates.usefulcount"] != 3 || d["rates.replycount"] != 3 {
+		t.Errorf("Check() = %v, want 3 drifts of each counter", d)
+	}
+	reports, err := service.Repair(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range reports {
+		if r.Drift != 3 || r.Repaired != 3 {
+			t.Errorf("Repair() of %s = %d drifts and %d repaired, want 3 and 3", r.Counter, r.Drift, r.Repaired)
+		}
+	}
+	want := map[string][]int64{"id = 'i1' and author = 'a'": {2, 1}, "id = 'i1' and author = 'b'": {1, 0}, "id = 'i2' and author = 'a'": {0, 1}}
+	for where, counters := range want {
+		if got := values(t, db, "rates", "usefulcount, replycount", where); got[0] != counters[0] || got[1] != counters[1] {
+			t.Errorf("counters of the rate where %s = %v, want %v", where, got, counters)
+		}
+	}
+}
+
+func TestRepairUserReactionCounters(t *testing.T) {
+	db := openSqlite(t,
+		"create table userreactions (id varchar(40), author varchar(40), reaction integer, primary key (id, author))",
+		"create table userinfo (id varchar(40) primary key, reactioncount integer default 0, level1count integer default 0, level2count integer default 0)",
+		"insert into userreactions(id, author, reaction) values ('u', 'a', 1), ('u', 'b', 2), ('u', 'c', 2), ('v', 'a', 1)",
+		"insert into userinfo(id, reactioncount, level1count, level2count) values ('u', 1, 1, 0), ('v', 1, 0, 1)",
+	)
+	service := NewReconcileService(db, 10, UserReactionCounters("userinfo", "id", "reactioncount", "level", "count", 2, "userreactions", "id", "reaction")...)
+	if d := drifts(t, service); d["userinfo.reactioncount"] != 1 || d["userinfo.level1count"] != 1 || d["userinfo.level2count"] != 2 {
+		t.Errorf("Check() = %v, want the drift of the reaction counter of u, the level 1 counter of v and both level 2 counters", d)
+	}
+	if _, err := service.Repair(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]int64{"u": {3, 1, 2}, "v": {1, 1, 0}}
+	for id, counters := range want {
+		got := values(t, db, "userinfo", "reactioncount, level1count, level2count", "id = '"+id+"'")
+		if got[0] != counters[0] || got[1] != counters[1] || got[2] != counters[2] {
+			t.Errorf("counters of %s = %v, want %v", id, got, counters)
+		}
+	}
+}
+
+func TestRepairInBatches(t *testing.T) {
+	db := openSqlite(t,
+		"create table following (id varchar(40), following varchar(40), primary key (id, following))",
+		"create table userinfo (id varchar(40) primary key, followercount integer default 0)",
+		// The follower counters of the users s1 to s3 are set back by the trigger, so they drift after their update.
+		"create trigger stuck after update on userinfo when new.id like 's%' begin update userinfo set followercount = 9 where id = new.id; end",
+	)
+	for _, id := range []string{"s1", "s2", "s3", "u1", "u2", "u3", "u4", "u5"} {
+		if _, err := db.Exec("insert into userinfo(id, followercount) values (?, 9)", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	service := NewReconcileService(db, 2, Counter{Name: "userinfo.followercount", Table: "userinfo", Keys: []string{"id"}, Column: "followercount",
+		Source: "following", SourceKeys: []string{"id"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	reports, err := service.Repair(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reports[0].Drift != 8 || len(reports[0].Samples) != 2 {
+		t.Errorf("Repair() = %+v, want 8 drifts in 4 batches, with 2 samples", reports[0])
+	}
+	if d := drifts(t, service); d["userinfo.followercount"] != 3 {
+		t.Errorf("Check() after Repair() = %v, want only the 3 stuck counters", d)
+	}
+}
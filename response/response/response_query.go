@@ -2,14 +2,25 @@ package response
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
+
+	"github.com/core-go/reaction/dialect"
 )
 
 func BuildDollarParam(i int) string {
-	return "$" + strconv.Itoa(i)
+	return dialect.BuildDollarParam(i)
 }
 func BuildResponseQuery(filter interface{}) (query string, params []interface{}) {
+	return NewResponseQueryBuilder(dialect.Postgres)(filter)
+}
+
+func NewResponseQueryBuilder(d dialect.Dialect) func(interface{}) (string, []interface{}) {
+	return func(filter interface{}) (string, []interface{}) {
+		return buildResponseQuery(d, filter)
+	}
+}
+
+func buildResponseQuery(d dialect.Dialect, filter interface{}) (query string, params []interface{}) {
 	query = `select * from response`
 	s := filter.(*ResponseFilter)
 	var where []string
@@ -17,38 +28,38 @@ func BuildResponseQuery(filter interface{}) (query string, params []interface{})
 	i := 1
 	if s.Time != nil {
 		if s.Time.Min != nil {
-			where = append(where, fmt.Sprintf(`time >= %s`, BuildDollarParam(i)))
+			where = append(where, fmt.Sprintf(`time >= %s`, d.Param(i)))
 			params = append(params, s.Time.Min)
 			i++
 		}
 		if s.Time.Max != nil {
-			where = append(where, fmt.Sprintf(`time <= %s`, BuildDollarParam(i)))
+			where = append(where, fmt.Sprintf(`time <= %s`, d.Param(i)))
 			params = append(params, s.Time.Max)
 			i++
 		}
 	}
 	if len(s.Id) > 0 {
-		where = append(where, fmt.Sprintf(`id = %s`, BuildDollarParam(i)))
+		where = append(where, fmt.Sprintf(`id = %s`, d.Param(i)))
 		params = append(params, s.Id)
 		i++
 	}
 	if len(s.Author) > 0 {
-		where = append(where, fmt.Sprintf(`author = %s`, BuildDollarParam(i)))
+		where = append(where, fmt.Sprintf(`author = %s`, d.Param(i)))
 		params = append(params, s.Author)
 		i++
 	}
 	if len(s.Desciption) > 0 {
-		where = append(where, fmt.Sprintf(`review ilike %s`, BuildDollarParam(i)))
+		where = append(where, d.ILike("review", d.Param(i)))
 		params = append(params, "%"+s.Desciption+"%")
 		i++
 	}
 	if len(s.UsefulCount) > 0 {
-		where = append(where, fmt.Sprintf(`usefulCount = %s`, BuildDollarParam(i)))
+		where = append(where, fmt.Sprintf(`usefulCount = %s`, d.Param(i)))
 		params = append(params, s.UsefulCount)
 		i++
 	}
 	if len(s.CommentCount) > 0 {
-		where = append(where, fmt.Sprintf(`commentCount = %s`, BuildDollarParam(i)))
+		where = append(where, fmt.Sprintf(`commentCount = %s`, d.Param(i)))
 		params = append(params, s.CommentCount)
 		i++
 	}
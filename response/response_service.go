@@ -5,6 +5,11 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
 )
 
 type ResponseService interface {
@@ -28,6 +33,7 @@ func NewResponseService(
 		driver.Valuer
 		sql.Scanner
 	},
+	publishers ...outbox.Publisher,
 ) ResponseService {
 	return &responseService{
 		DB:               db,
@@ -42,6 +48,8 @@ func NewResponseService(
 		InfoIdCol:        infoIdCol,
 		ResponseCountCol: responseCountCol,
 		ToArray:          toArray,
+		Dialect:          dialect.New(db),
+		Publishers:       publishers,
 	}
 }
 
@@ -61,12 +69,25 @@ type responseService struct {
 		driver.Valuer
 		sql.Scanner
 	}
+	Dialect    dialect.Dialect
+	Publishers []outbox.Publisher
 }
 
 func (s *responseService) Load(ctx context.Context, id string, author string) (*Response, error) {
-	query := fmt.Sprintf("select %s, %s, %s, %s, %s, %s, histories from %s where %s = $1 and %s = $2 limit 1",
-		s.IdCol, s.AuthorCol, s.DescriptionCol, s.TimeCol, s.UsefulCountCol, s.CommentCountCol, s.ResponseTable, s.IdCol, s.AuthorCol)
-	rows, err := s.DB.QueryContext(ctx, query, id, author)
+	return s.load(ctx, s.DB, id, author, false)
+}
+
+// load returns the response of the author on id. If lock is true, the response is locked until the end of the transaction db.
+func (s *responseService) load(ctx context.Context, db interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}, id string, author string, lock bool) (*Response, error) {
+	columns := []string{s.IdCol, s.AuthorCol, s.DescriptionCol, s.TimeCol, s.UsefulCountCol, s.CommentCountCol, "histories"}
+	where := fmt.Sprintf("%s = ? and %s = ?", s.IdCol, s.AuthorCol)
+	query := fmt.Sprintf("select %s from %s where %s", strings.Join(columns, ", "), s.ResponseTable, where)
+	if lock {
+		query = s.Dialect.ForUpdate(columns, s.ResponseTable, where)
+	}
+	rows, err := db.QueryContext(ctx, s.Dialect.Rebind(query), id, author)
 	if err != nil {
 		return nil, err
 	}
@@ -79,38 +100,55 @@ func (s *responseService) Load(ctx context.Context, id string, author string) (*
 		}
 		return &response, nil
 	}
-	return nil, nil
+	return nil, rows.Err()
 }
 
 func (s *responseService) Response(ctx context.Context, response *Response) (int64, error) {
-	oldResponse, _ := s.Load(ctx, response.Id, response.Author)
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+	// The old response is locked, so that concurrent responses of the same author are counted once.
+	oldResponse, err := s.load(ctx, tx, response.Id, response.Author, true)
+	if err != nil {
+		return -1, err
+	}
+	var event outbox.Event = outbox.ResponseCreated{Id: response.Id, Author: response.Author, Description: response.Description, Time: time.Now()}
 	if oldResponse != nil {
 		if oldResponse.Description == response.Description {
 			return 0, nil
 		}
 		response.Histories = append(oldResponse.Histories, Histories{Time: oldResponse.Time, Description: oldResponse.Description})
+		event = outbox.ResponseUpdated{Id: response.Id, Author: response.Author, Old: oldResponse.Description, Description: response.Description, Time: time.Now()}
 	} else {
-		query1 := fmt.Sprintf(
-			"insert into %s(%s, %s) values ($1, 1) on conflict (%s) do update set %s = %s.%s + 1",
-			s.InfoTable, s.InfoIdCol, s.ResponseCountCol, s.InfoIdCol, s.ResponseCountCol, s.InfoTable, s.ResponseCountCol)
-		stmt1, err := s.DB.Prepare(query1)
+		query1 := s.Dialect.Rebind(s.Dialect.Upsert(s.InfoTable,
+			[]string{s.InfoIdCol, s.ResponseCountCol}, []string{"?", "1"}, []string{s.InfoIdCol},
+			[]string{fmt.Sprintf("%s = %s.%s + 1", s.ResponseCountCol, s.InfoTable, s.ResponseCountCol)}))
+		_, err = tx.ExecContext(ctx, query1, response.Id)
 		if err != nil {
 			return -1, err
 		}
-		stmt1.ExecContext(ctx, response.Id)
 	}
 
-	query2 := fmt.Sprintf(
-		"insert into %s(%s, %s, %s, %s, histories) values ($1, $2, $3, $4, $5) on conflict (%s, %s) do update set %s = $3, %s = $4, histories = $5",
-		s.ResponseTable, s.IdCol, s.AuthorCol, s.DescriptionCol, s.TimeCol, s.IdCol, s.AuthorCol, s.DescriptionCol, s.TimeCol)
-	stmt, err := s.DB.Prepare(query2)
+	query2 := s.Dialect.Rebind(s.Dialect.Upsert(s.ResponseTable,
+		[]string{s.IdCol, s.AuthorCol, s.DescriptionCol, s.TimeCol, "histories"},
+		[]string{"?", "?", "?", "?", "?"},
+		[]string{s.IdCol, s.AuthorCol},
+		s.Dialect.UpdateSets(s.DescriptionCol, s.TimeCol, "histories")))
+	res2, err := tx.ExecContext(ctx, query2, response.Id, response.Author, response.Description, response.Time, s.ToArray(response.Histories))
 	if err != nil {
 		return -1, err
 	}
-	res2, err := stmt.ExecContext(ctx, response.Id, response.Author, response.Description, response.Time, s.ToArray(response.Histories))
+	r, err := res2.RowsAffected()
 	if err != nil {
 		return -1, err
 	}
-
-	return res2.RowsAffected()
+	if err = outbox.Publish(ctx, tx, s.Publishers, event); err != nil {
+		return -1, err
+	}
+	if err = tx.Commit(); err != nil {
+		return -1, err
+	}
+	return r, nil
 }
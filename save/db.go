@@ -5,19 +5,10 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"errors"
-	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
-)
 
-const (
-	driverPostgres   = "postgres"
-	driverMysql      = "mysql"
-	driverMssql      = "mssql"
-	driverOracle     = "oracle"
-	driverSqlite3    = "sqlite3"
-	driverNotSupport = "no support"
+	"github.com/core-go/reaction/dialect"
 )
 
 func getColumnIndexes(modelType reflect.Type) (map[string]int, error) {
@@ -250,71 +241,17 @@ func swapValuesToBool(s interface{}, swap *map[int]interface{}) {
 	}
 }
 func GetBuildByDriver(driver string) func(i int) string {
-	switch driver {
-	case driverPostgres:
-		return BuildDollarParam
-	case driverOracle:
-		return BuildOracleParam
-	case driverMssql:
-		return BuildMsSqlParam
-	default:
-		return BuildParam
-	}
-}
-func getDriver(db *sql.DB) string {
-	if db == nil {
-		return driverNotSupport
-	}
-	driver := reflect.TypeOf(db.Driver()).String()
-	switch driver {
-	case "*pq.Driver":
-		return driverPostgres
-	case "*godror.drv":
-		return driverOracle
-	case "*mysql.MySQLDriver":
-		return driverMysql
-	case "*mssql.Driver":
-		return driverMssql
-	case "*sqlite3.SQLiteDriver":
-		return driverSqlite3
-	default:
-		return driverNotSupport
-	}
-}
-func replaceQueryArgs(driver string, query string) string {
-	if driver == driverOracle || driver == driverPostgres || driver == driverMssql {
-		var x string
-		if driver == driverOracle {
-			x = ":val"
-		} else if driver == driverPostgres {
-			x = "$"
-		} else if driver == driverMssql {
-			x = "@p"
-		}
-		i := 1
-		k := strings.Index(query, "?")
-		if k >= 0 {
-			for {
-				query = strings.Replace(query, "?", x+fmt.Sprintf("%v", i), 1)
-				i = i + 1
-				k := strings.Index(query, "?")
-				if k < 0 {
-					return query
-				}
-			}
-		}
-	}
-	return query
+	return dialect.GetBuildByDriver(driver)
 }
 func BuildParam(i int) string {
-	return "?"
+	return dialect.BuildParam(i)
 }
 func BuildOracleParam(i int) string {
-	return ":" + strconv.Itoa(i)
+	return dialect.BuildOracleParam(i)
 }
 func BuildMsSqlParam(i int) string {
-	return "@p" + strconv.Itoa(i)
+	return dialect.BuildMsSqlParam(i)
 }
 func BuildDollarParam(i int) string {
-	return "$" + strconv.Itoa(i)
+	return dialect.BuildDollarParam(i)
 }
@@ -6,6 +6,10 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"reflect"
+	"time"
+
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
 )
 
 type SaveService interface {
@@ -27,7 +31,7 @@ func NewSaveService(
 		driver.Valuer
 		sql.Scanner
 	},
-
+	publishers ...outbox.Publisher,
 ) SaveService {
 	return &saveService{
 		DB:          db,
@@ -39,6 +43,8 @@ func NewSaveService(
 		targetTable: targetTable,
 		idTargetCol: idTargetCol,
 		toArray:     toArray,
+		dialect:     dialect.New(db),
+		publishers:  publishers,
 	}
 }
 
@@ -55,11 +61,13 @@ type saveService struct {
 		driver.Valuer
 		sql.Scanner
 	}
+	dialect    dialect.Dialect
+	publishers []outbox.Publisher
 }
 
 func (s *saveService) Load(ctx context.Context, id string, listResult interface{}) error {
 	var saveList []Items
-	query := fmt.Sprintf("select %s as id, %s as items from %s where %s = $1", s.idCol, s.itemCol, s.table, s.idCol)
+	query := s.dialect.Rebind(fmt.Sprintf("select %s as id, %s as items from %s where %s = ?", s.idCol, s.itemCol, s.table, s.idCol))
 	rows, err := s.DB.QueryContext(ctx, query, id)
 	if err != nil {
 		return err
@@ -80,12 +88,13 @@ func (s *saveService) Load(ctx context.Context, id string, listResult interface{
 		return nil
 	}
 
-	query2 := fmt.Sprintf("SELECT * FROM %s WHERE %s = ANY($1)", s.targetTable, s.idTargetCol)
+	in, params := s.dialect.InArray(s.idTargetCol, saveList[0].Items, s.toArray)
+	query2 := s.dialect.Rebind(fmt.Sprintf("SELECT * FROM %s WHERE %s", s.targetTable, in))
 	stmt, err0 := s.DB.PrepareContext(ctx, query2)
 	if err0 != nil {
 		return err0
 	}
-	rows, err1 := stmt.QueryContext(ctx, s.toArray(saveList[0].Items))
+	rows, err1 := stmt.QueryContext(ctx, params...)
 	if err1 != nil {
 		return err1
 	}
@@ -102,72 +111,82 @@ func (s *saveService) Load(ctx context.Context, id string, listResult interface{
 }
 
 func (s *saveService) Save(ctx context.Context, id string, item string) (int64, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
 	var items []string
-	query0 := fmt.Sprintf("select %s as items from %s where %s = $1", s.itemCol, s.table, s.idCol)
-	rows, err0 := s.DB.QueryContext(ctx, query0, id)
+	query0 := s.dialect.Rebind(fmt.Sprintf("select %s as items from %s where %s = ?", s.itemCol, s.table, s.idCol))
+	rows, err0 := tx.QueryContext(ctx, query0, id)
 	if err0 != nil {
 		return -1, err0
 	}
-
-	defer rows.Close()
 	for rows.Next() {
 		err := rows.Scan(s.toArray(&items))
 		if err != nil {
+			rows.Close()
 			return -1, err
 		}
 	}
+	rows.Close()
 
+	var res sql.Result
 	if items == nil {
-		query := fmt.Sprintf("insert into %s(%s, %s) values ($1, $2)", s.table, s.idCol, s.itemCol)
-		stmt, er0 := s.DB.Prepare(query)
-		if er0 != nil {
-			return -1, er0
-		}
+		query := s.dialect.Rebind(fmt.Sprintf("insert into %s(%s, %s) values (?, ?)", s.table, s.idCol, s.itemCol))
 		items = append(items, item)
-		res, err := stmt.ExecContext(ctx, id, s.toArray(items))
-		if err != nil {
-			return -1, err
-		}
-		return res.RowsAffected()
+		res, err = tx.ExecContext(ctx, query, id, s.toArray(items))
 	} else {
-		query := fmt.Sprintf("update %s set %s = $1 where %s = $2", s.table, s.itemCol, s.idCol)
-		stmt, er0 := s.DB.Prepare(query)
-		if er0 != nil {
-			return -1, er0
-		}
 		for _, v := range items {
 			if v == item {
 				return -1, nil
 			}
 		}
+		query := s.dialect.Rebind(fmt.Sprintf("update %s set %s = ? where %s = ?", s.table, s.itemCol, s.idCol))
 		items = append(items, item)
 		if len(items) > s.max {
 			items = items[1:]
 		}
-		res, err := stmt.ExecContext(ctx, s.toArray(items), id)
-		if err != nil {
-			return -1, err
-		}
-		return res.RowsAffected()
+		res, err = tx.ExecContext(ctx, query, s.toArray(items), id)
 	}
+	if err != nil {
+		return -1, err
+	}
+	r, err := res.RowsAffected()
+	if err != nil {
+		return -1, err
+	}
+	if err = outbox.Publish(ctx, tx, s.publishers, outbox.Saved{Id: id, Item: item, Time: time.Now()}); err != nil {
+		return -1, err
+	}
+	if err = tx.Commit(); err != nil {
+		return -1, err
+	}
+	return r, nil
 }
 
 func (s *saveService) Remove(ctx context.Context, id string, item string) (int64, error) {
-	query0 := fmt.Sprintf("select %s as id, %s as items from %s where %s = $1", s.idCol, s.itemCol, s.table, s.idCol)
-	rows, err0 := s.DB.QueryContext(ctx, query0, id)
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+	query0 := s.dialect.Rebind(fmt.Sprintf("select %s as id, %s as items from %s where %s = ?", s.idCol, s.itemCol, s.table, s.idCol))
+	rows, err0 := tx.QueryContext(ctx, query0, id)
 	if err0 != nil {
 		return -1, err0
 	}
 	var items []Items
-	defer rows.Close()
 	for rows.Next() {
 		var item Items
 		err := rows.Scan(&item.Id, s.toArray(&item.Items))
 		if err != nil {
+			rows.Close()
 			return -1, err
 		}
 		items = append(items, item)
 	}
+	rows.Close()
 	if items == nil {
 		return 0, nil
 	}
@@ -177,15 +196,22 @@ func (s *saveService) Remove(ctx context.Context, id string, item string) (int64
 			newItems = append(newItems, items[0].Items[i])
 		}
 	}
-	query := fmt.Sprintf("update %s set %s = $1 where %s = $2", s.table, s.itemCol, s.idCol)
-	stmt, er0 := s.DB.Prepare(query)
-	if er0 != nil {
-		return -1, er0
+	query := s.dialect.Rebind(fmt.Sprintf("update %s set %s = ? where %s = ?", s.table, s.itemCol, s.idCol))
+	res, err := tx.ExecContext(ctx, query, s.toArray(&newItems), id)
+	if err != nil {
+		return -1, err
 	}
-	res, err := stmt.ExecContext(ctx, s.toArray(&newItems), id)
+	r, err := res.RowsAffected()
 	if err != nil {
 		return -1, err
 	}
-
-	return res.RowsAffected()
+	if len(newItems) < len(items[0].Items) {
+		if err = outbox.Publish(ctx, tx, s.publishers, outbox.Unsaved{Id: id, Item: item, Time: time.Now()}); err != nil {
+			return -1, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return -1, err
+	}
+	return r, nil
 }
@@ -0,0 +1,10 @@
+package suggest
+
+type Suggestion struct {
+	Id string `json:"id"`
+	// Score is the number of the users followed by the user who follow Id.
+	Score     int64   `json:"score"`
+	Followers int64   `json:"followers"`
+	Name      *string `json:"name,omitempty"`
+	Url       *string `json:"url,omitempty"`
+}
@@ -0,0 +1,63 @@
+package suggest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func NewSuggestHandler(service SuggestService, idIndex int) SuggestHandler {
+	return SuggestHandler{service: service, idIndex: idIndex}
+}
+
+type SuggestHandler struct {
+	service SuggestService
+	idIndex int
+}
+
+func (h *SuggestHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	id := GetRequiredParam(w, r, h.idIndex)
+	if len(id) == 0 {
+		return
+	}
+	var limit int64
+	var err error
+	if l := r.URL.Query().Get("limit"); len(l) > 0 {
+		limit, err = strconv.ParseInt(l, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	result, err := h.service.Suggest(r.Context(), id, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+func GetParam(r *http.Request, options ...int) string {
+	offset := 0
+	if len(options) > 0 && options[0] > 0 {
+		offset = options[0]
+	}
+	s := r.URL.Path
+	params := strings.Split(s, "/")
+	i := len(params) - 1 - offset
+	if i >= 0 {
+		return params[i]
+	} else {
+		return ""
+	}
+}
+func GetRequiredParam(w http.ResponseWriter, r *http.Request, options ...int) string {
+	p := GetParam(r, options...)
+	if len(p) == 0 {
+		http.Error(w, "parameter is required", http.StatusBadRequest)
+		return ""
+	}
+	return p
+}
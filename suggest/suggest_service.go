@@ -0,0 +1,136 @@
+package suggest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/paging"
+)
+
+type SuggestService interface {
+	// Suggest returns the users followed by the users id follows, ranked by the number of them, then by their follower count.
+	// The users id already follows, and the users blocked with id, are excluded.
+	Suggest(ctx context.Context, id string, limit int64) ([]Suggestion, error)
+}
+
+// NewSuggestService returns the suggestions from the following table, which has a row (id, following) for each user followed by id,
+// as the follower table of follow.NewFollowService. If blockTable is empty, the blocked users are not excluded.
+// maxCandidates caps the candidates to the users with the most overlap, before they are ranked by their follower count,
+// so that the follower counts are read for maxCandidates users at most. It also caps the number of suggestions of a request.
+func NewSuggestService(
+	db *sql.DB,
+	followingTable string,
+	followingIdCol string,
+	followingCol string,
+	userInfoTable string,
+	userInfoIdCol string,
+	followerCountCol string,
+	blockTable string,
+	blockIdCol string,
+	blockTargetCol string,
+	maxCandidates int64,
+	queryInfo func(ids []string) ([]Info, error),
+) SuggestService {
+	if maxCandidates <= 0 {
+		maxCandidates = 100
+	}
+	return &suggestService{
+		DB:               db,
+		FollowingTable:   followingTable,
+		FollowingIdCol:   followingIdCol,
+		FollowingCol:     followingCol,
+		UserInfoTable:    userInfoTable,
+		UserInfoIdCol:    userInfoIdCol,
+		FollowerCountCol: followerCountCol,
+		BlockTable:       blockTable,
+		BlockIdCol:       blockIdCol,
+		BlockTargetCol:   blockTargetCol,
+		MaxCandidates:    maxCandidates,
+		QueryInfo:        queryInfo,
+		Dialect:          dialect.New(db),
+	}
+}
+
+type suggestService struct {
+	DB               *sql.DB
+	FollowingTable   string
+	FollowingIdCol   string
+	FollowingCol     string
+	UserInfoTable    string
+	UserInfoIdCol    string
+	FollowerCountCol string
+	BlockTable       string
+	BlockIdCol       string
+	BlockTargetCol   string
+	MaxCandidates    int64
+	QueryInfo        func(ids []string) ([]Info, error)
+	Dialect          dialect.Dialect
+}
+
+func (s *suggestService) Suggest(ctx context.Context, id string, limit int64) ([]Suggestion, error) {
+	if limit <= 0 || limit > s.MaxCandidates {
+		limit = s.MaxCandidates
+	}
+	params := []interface{}{id, id, id}
+	where := fmt.Sprintf("f1.%s = ? and f2.%s <> ? and not exists (select 1 from %s f3 where f3.%s = ? and f3.%s = f2.%s)",
+		s.FollowingIdCol, s.FollowingCol, s.FollowingTable, s.FollowingIdCol, s.FollowingCol, s.FollowingCol)
+	if len(s.BlockTable) > 0 {
+		params = append(params, id, id)
+		where += fmt.Sprintf(" and not exists (select 1 from %s b where (b.%s = ? and b.%s = f2.%s) or (b.%s = f2.%s and b.%s = ?))",
+			s.BlockTable, s.BlockIdCol, s.BlockTargetCol, s.FollowingCol, s.BlockIdCol, s.FollowingCol, s.BlockTargetCol)
+	}
+	// The candidates are the MaxCandidates users with the most overlap, the ties broken by id.
+	candidates := fmt.Sprintf(`select f2.%s as candidate, count(*) as score from %s f1
+		inner join %s f2 on f2.%s = f1.%s
+		where %s
+		group by f2.%s
+		order by count(*) desc, f2.%s %s`,
+		s.FollowingCol, s.FollowingTable,
+		s.FollowingTable, s.FollowingIdCol, s.FollowingCol,
+		where,
+		s.FollowingCol,
+		s.FollowingCol, s.Dialect.Limit(s.MaxCandidates))
+	query := s.Dialect.Rebind(fmt.Sprintf(`select c.candidate, c.score, coalesce(u.%s, 0) from (%s) c
+		left join %s u on u.%s = c.candidate
+		order by c.score desc, coalesce(u.%s, 0) desc, c.candidate %s`,
+		s.FollowerCountCol, candidates,
+		s.UserInfoTable, s.UserInfoIdCol,
+		s.FollowerCountCol, s.Dialect.Limit(limit)))
+	rows, err := s.DB.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	suggestions := make([]Suggestion, 0)
+	for rows.Next() {
+		var suggestion Suggestion
+		if err = rows.Scan(&suggestion.Id, &suggestion.Score, &suggestion.Followers); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if s.QueryInfo == nil || len(suggestions) == 0 {
+		return suggestions, nil
+	}
+	ids := make([]string, 0)
+	for _, suggestion := range suggestions {
+		ids = append(ids, suggestion.Id)
+	}
+	infos, err := s.QueryInfo(ids)
+	if err != nil {
+		return nil, err
+	}
+	for k := range suggestions {
+		i := paging.BinarySearch(infos, suggestions[k].Id)
+		if i >= 0 && infos[i].Id == suggestions[k].Id {
+			suggestions[k].Url = &infos[i].Url
+			suggestions[k].Name = &infos[i].Name
+		}
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,155 @@
+package suggest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// graph is a generated follow graph: follows[a][b] if a follows b, blocks[a][b] if a blocked b.
+type graph struct {
+	users   []string
+	follows map[string]map[string]bool
+	blocks  map[string]map[string]bool
+}
+
+func generate(seed int64, users int, following int, blocks int) graph {
+	r := rand.New(rand.NewSource(seed))
+	g := graph{follows: make(map[string]map[string]bool), blocks: make(map[string]map[string]bool)}
+	for i := 0; i < users; i++ {
+		id := fmt.Sprintf("u%02d", i)
+		g.users = append(g.users, id)
+		g.follows[id] = make(map[string]bool)
+		g.blocks[id] = make(map[string]bool)
+	}
+	for _, id := range g.users {
+		for len(g.follows[id]) < following {
+			if target := g.users[r.Intn(users)]; target != id {
+				g.follows[id][target] = true
+			}
+		}
+	}
+	for i := 0; i < blocks; i++ {
+		id, target := g.users[r.Intn(users)], g.users[r.Intn(users)]
+		if id != target {
+			g.blocks[id][target] = true
+		}
+	}
+	return g
+}
+
+func (g graph) followers(id string) int64 {
+	var n int64
+	for _, following := range g.follows {
+		if following[id] {
+			n++
+		}
+	}
+	return n
+}
+
+// suggest is the brute force of Suggest: the maxCandidates users with the most overlap, the ties broken by id,
+// then ranked by their overlap, their follower count and their id.
+func (g graph) suggest(id string, maxCandidates int, limit int) []Suggestion {
+	scores := make(map[string]int64)
+	for friend := range g.follows[id] {
+		for candidate := range g.follows[friend] {
+			if candidate != id && !g.follows[id][candidate] && !g.blocks[id][candidate] && !g.blocks[candidate][id] {
+				scores[candidate]++
+			}
+		}
+	}
+	suggestions := make([]Suggestion, 0)
+	for candidate, score := range scores {
+		suggestions = append(suggestions, Suggestion{Id: candidate, Score: score, Followers: g.followers(candidate)})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Id < suggestions[j].Id
+	})
+	if len(suggestions) > maxCandidates {
+		suggestions = suggestions[:maxCandidates]
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		if suggestions[i].Followers != suggestions[j].Followers {
+			return suggestions[i].Followers > suggestions[j].Followers
+		}
+		return suggestions[i].Id < suggestions[j].Id
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+func openGraph(t *testing.T, g graph) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	stmts := []string{
+		"create table following (id varchar(40), following varchar(40), primary key (id, following))",
+		"create table userinfo (id varchar(40) primary key, followercount integer default 0)",
+		"create table blocks (id varchar(40), target varchar(40), primary key (id, target))",
+	}
+	for _, stmt := range stmts {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range g.users {
+		if _, err = db.Exec("insert into userinfo(id, followercount) values (?, ?)", id, g.followers(id)); err != nil {
+			t.Fatal(err)
+		}
+		for target := range g.follows[id] {
+			if _, err = db.Exec("insert into following(id, following) values (?, ?)", id, target); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for target := range g.blocks[id] {
+			if _, err = db.Exec("insert into blocks(id, target) values (?, ?)", id, target); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return db
+}
+
+func TestSuggestGeneratedGraph(t *testing.T) {
+	g := generate(15, 60, 8, 30)
+	db := openGraph(t, g)
+	ctx := context.Background()
+	capped := 0
+	for _, maxCandidates := range []int{3, 10, 100} {
+		service := NewSuggestService(db, "following", "id", "following", "userinfo", "id", "followercount", "blocks", "id", "target", int64(maxCandidates), nil)
+		for _, id := range g.users {
+			suggestions, err := service.Suggest(ctx, id, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := g.suggest(id, maxCandidates, 3); !reflect.DeepEqual(suggestions, want) {
+				t.Errorf("Suggest(%s) with %d candidates = %+v, want %+v", id, maxCandidates, suggestions, want)
+			}
+			if maxCandidates == 3 && !reflect.DeepEqual(suggestions, g.suggest(id, 100, 3)) {
+				capped++
+			}
+		}
+	}
+	if capped == 0 {
+		t.Error("the candidate cap never changed a suggestion, the graph does not test it")
+	}
+}
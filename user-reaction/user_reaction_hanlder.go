@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/core-go/reaction/block"
 	"github.com/gorilla/mux"
 )
 
@@ -42,6 +43,29 @@ func (h *UserReactionHandler) CheckReact(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+func (h *UserReactionHandler) CheckReacts(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	author := mux.Vars(r)[h.authorField]
+	if len(author) == 0 {
+		http.Error(w, "parameter is required", http.StatusBadRequest)
+		return
+	}
+	er1 := json.NewDecoder(r.Body).Decode(&ids)
+	defer r.Body.Close()
+	if er1 != nil {
+		http.Error(w, er1.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := h.service.CheckReactions(r.Context(), author, ids)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
+
 func (h *UserReactionHandler) Unreact(w http.ResponseWriter, r *http.Request) {
 	//id := GetRequiredParam(w, r, 2)
 	//author := GetRequiredParam(w, r, 1)
@@ -71,6 +95,10 @@ func (h *UserReactionHandler) React(w http.ResponseWriter, r *http.Request) {
 	reaction := mux.Vars(r)[h.reactionField]
 	if len(id) > 0 && len(author) > 0 && len(reaction) > 0 {
 		res, err := h.service.React(r.Context(), id, author, reaction)
+		if err == block.ErrBlocked {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
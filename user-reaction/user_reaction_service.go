@@ -3,14 +3,21 @@ package user_reaction
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"strconv"
+	"time"
+
+	"github.com/core-go/reaction/block"
+	"github.com/core-go/reaction/dialect"
+	"github.com/core-go/reaction/outbox"
 )
 
 type UserReactionService interface {
 	React(ctx context.Context, id string, author string, reaction string) (int64, error)
 	Unreact(ctx context.Context, id string, author string, reaction string) (int64, error)
 	CheckReaction(ctx context.Context, id string, author string) (int64, error)
+	CheckReactions(ctx context.Context, author string, ids []string) (map[string]int64, error)
 }
 
 func NewUserReactionService(
@@ -25,6 +32,12 @@ func NewUserReactionService(
 	reactionCount string,
 	prefix string,
 	suffix string,
+	toArray func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	},
+	guard block.Guard,
+	publishers ...outbox.Publisher,
 ) UserReactionService {
 	return &userReactionService{
 		DB:                DB,
@@ -37,6 +50,10 @@ func NewUserReactionService(
 		userinfoTable:     userinfoTable,
 		infoId:            infoId,
 		reactionCount:     reactionCount,
+		toArray:           toArray,
+		guard:             guard,
+		dialect:           dialect.New(DB),
+		publishers:        publishers,
 	}
 }
 
@@ -51,10 +68,17 @@ type userReactionService struct {
 	userinfoTable     string
 	infoId            string
 	reactionCount     string
+	toArray           func(interface{}) interface {
+		driver.Valuer
+		sql.Scanner
+	}
+	guard      block.Guard
+	dialect    dialect.Dialect
+	publishers []outbox.Publisher
 }
 
 func (s *userReactionService) CheckReaction(ctx context.Context, id string, author string) (int64, error) {
-	stmt := fmt.Sprintf(`select reaction from %s where %s = $1 and %s = $2`, s.userReactionTable, s.id, s.author)
+	stmt := s.dialect.Rebind(fmt.Sprintf(`select reaction from %s where %s = ? and %s = ?`, s.userReactionTable, s.id, s.author))
 	rows, err := s.DB.QueryContext(ctx, stmt, id, author)
 	if err != nil {
 		return -1, err
@@ -68,7 +92,37 @@ func (s *userReactionService) CheckReaction(ctx context.Context, id string, auth
 	return -1, nil
 }
 
+func (s *userReactionService) CheckReactions(ctx context.Context, author string, ids []string) (map[string]int64, error) {
+	result := make(map[string]int64)
+	if len(ids) == 0 {
+		return result, nil
+	}
+	in, params := s.dialect.InArray(s.id, ids, s.toArray)
+	stmt := s.dialect.Rebind(fmt.Sprintf(`select %s, %s from %s where %s = ? and %s`, s.id, s.reaction, s.userReactionTable, s.author, in))
+	rows, err := s.DB.QueryContext(ctx, stmt, append([]interface{}{author}, params...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var reaction int64
+		err = rows.Scan(&id, &reaction)
+		if err != nil {
+			return nil, err
+		}
+		result[id] = reaction
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (s *userReactionService) React(ctx context.Context, id string, author string, reaction string) (int64, error) {
+	if err := block.Check(ctx, s.guard, id, author); err != nil {
+		return -1, err
+	}
 	userReaction, err := s.CheckReaction(ctx, id, author)
 	if err != nil {
 		return -1, err
@@ -80,11 +134,15 @@ func (s *userReactionService) React(ctx context.Context, id string, author strin
 			"l3": 0,
 		}
 		obj["l"+reaction] = 1
-		query := fmt.Sprintf("insert into %s(%s, %s, %s) values ($1, $2, $3)", s.userReactionTable, s.id, s.author, s.reaction)
-		query2 := fmt.Sprintf("insert into %s(%s, %s1%s, %s2%s,%s3%s, %s) values ($1, $2, $3, $4, 1) on conflict (%s) do update set %s1%s = %s.%s1%s + $2, %s2%s = %s.%s2%s + $3, %s3%s =%s.%s3%s + $4, %s = %s.%s + 1",
-			s.userinfoTable, s.infoId, s.prefix, s.suffix, s.prefix, s.suffix, s.prefix, s.suffix, s.reactionCount,
-			s.infoId, s.prefix, s.suffix, s.userinfoTable, s.prefix, s.suffix, s.prefix, s.suffix, s.userinfoTable, s.prefix, s.suffix, s.prefix, s.suffix, s.userinfoTable, s.prefix, s.suffix, s.reactionCount, s.userinfoTable, s.reactionCount,
-		)
+		query := s.dialect.Rebind(fmt.Sprintf("insert into %s(%s, %s, %s) values (?, ?, ?)", s.userReactionTable, s.id, s.author, s.reaction))
+		levels := []string{s.prefix + "1" + s.suffix, s.prefix + "2" + s.suffix, s.prefix + "3" + s.suffix}
+		sets := make([]string, 0)
+		for _, level := range levels {
+			sets = append(sets, fmt.Sprintf("%s = %s.%s + %s", level, s.userinfoTable, level, s.dialect.Excluded(level)))
+		}
+		sets = append(sets, fmt.Sprintf("%s = %s.%s + 1", s.reactionCount, s.userinfoTable, s.reactionCount))
+		query2 := s.dialect.Rebind(s.dialect.Upsert(s.userinfoTable,
+			[]string{s.infoId, levels[0], levels[1], levels[2], s.reactionCount}, []string{"?", "?", "?", "?", "1"}, []string{s.infoId}, sets))
 		var rowCount int64
 		rowCount = 0
 		tx, err := s.DB.BeginTx(ctx, nil)
@@ -116,17 +174,21 @@ func (s *userReactionService) React(ctx context.Context, id string, author strin
 
 		rowCount += rowaffected2
 
+		level, _ := strconv.ParseInt(reaction, 10, 64)
+		if err = outbox.Publish(ctx, tx, s.publishers, outbox.UserReacted{Id: id, Author: author, Reaction: level, Time: time.Now()}); err != nil {
+			return -1, err
+		}
 		if err = tx.Commit(); err != nil {
 			return -1, err
 		}
 		return rowCount, nil
 	} else {
 		if _reaction, _ := strconv.Atoi(reaction); userReaction != int64(_reaction) {
-			query := fmt.Sprintf("Update %s set %s = $1 where %s = $2 and %s = $3",
-				s.userReactionTable, s.reaction, s.id, s.author)
-			query2 := fmt.Sprintf("Update %s set %s%d%s = %s%d%s - 1, %s%s%s = %s%s%s + 1 where %s = $1",
+			query := s.dialect.Rebind(fmt.Sprintf("Update %s set %s = ? where %s = ? and %s = ?",
+				s.userReactionTable, s.reaction, s.id, s.author))
+			query2 := s.dialect.Rebind(fmt.Sprintf("Update %s set %s%d%s = %s%d%s - 1, %s%s%s = %s%s%s + 1 where %s = ?",
 				s.userinfoTable, s.prefix, userReaction, s.suffix, s.prefix, userReaction, s.suffix,
-				s.prefix, reaction, s.suffix, s.prefix, reaction, s.suffix, s.infoId)
+				s.prefix, reaction, s.suffix, s.prefix, reaction, s.suffix, s.infoId))
 			var rowCount int64
 			rowCount = 0
 			tx, err := s.DB.BeginTx(ctx, nil)
@@ -158,6 +220,10 @@ func (s *userReactionService) React(ctx context.Context, id string, author strin
 
 			rowCount += rowaffected2
 
+			err = outbox.Publish(ctx, tx, s.publishers, outbox.UserReacted{Id: id, Author: author, Reaction: int64(_reaction), Old: userReaction, Time: time.Now()})
+			if err != nil {
+				return -1, err
+			}
 			if err = tx.Commit(); err != nil {
 				return -1, err
 			}
@@ -168,8 +234,8 @@ func (s *userReactionService) React(ctx context.Context, id string, author strin
 }
 
 func (s *userReactionService) Unreact(ctx context.Context, id string, author string, reaction string) (int64, error) {
-	query := fmt.Sprintf("DELETE from %s WHERE %s = $1 and %s = $2 and %s = $3", s.userReactionTable, s.id, s.author, s.reaction)
-	query2 := fmt.Sprintf("UPDATE %s SET %s%s%s = %s%s%s - 1, %s = %s - 1 WHERE %s = $1 ", s.userinfoTable, s.prefix, reaction, s.suffix, s.prefix, reaction, s.suffix, s.reactionCount, s.reactionCount, s.infoId)
+	query := s.dialect.Rebind(fmt.Sprintf("DELETE from %s WHERE %s = ? and %s = ? and %s = ?", s.userReactionTable, s.id, s.author, s.reaction))
+	query2 := s.dialect.Rebind(fmt.Sprintf("UPDATE %s SET %s%s%s = %s%s%s - 1, %s = %s - 1 WHERE %s = ? ", s.userinfoTable, s.prefix, reaction, s.suffix, s.prefix, reaction, s.suffix, s.reactionCount, s.reactionCount, s.infoId))
 
 	var rowCount int64
 	rowCount = 0
@@ -204,6 +270,10 @@ func (s *userReactionService) Unreact(ctx context.Context, id string, author str
 
 	rowCount += rowaffected2
 
+	level, _ := strconv.ParseInt(reaction, 10, 64)
+	if err = outbox.Publish(ctx, tx, s.publishers, outbox.UserUnreacted{Id: id, Author: author, Reaction: level, Time: time.Now()}); err != nil {
+		return -1, err
+	}
 	if err = tx.Commit(); err != nil {
 		return -1, err
 	}